@@ -2,10 +2,10 @@ import { create } from "zustand"
 import { persist } from "zustand/middleware"
 
 import { CAxios } from "../../core/configs/cAxios"
+import { getApiError } from "../../core/types/apiError"
 
 // Types
 import {
-    CooldownLeftInSecondsRequest,
     CooldownLeftInSecondsResponse,
     LoginRequest,
     LoginResponse,
@@ -24,9 +24,7 @@ interface useUserInterface {
     // APIs
     login: (loginRequest: LoginRequest) => Promise<boolean>
     register: (registerRequest: RegisterRequest) => Promise<boolean>
-    cooldownLeftInSeconds: (
-        cooldownLeftInSecondsRequest: CooldownLeftInSecondsRequest
-    ) => Promise<CooldownLeftInSecondsResponse>
+    cooldownLeftInSeconds: () => Promise<CooldownLeftInSecondsResponse>
     logout: () => void
 
     // Move related
@@ -107,14 +105,17 @@ export const useUser = create<useUserInterface>()(
                 }
             },
             // --------------------------------------------------------------------
-            cooldownLeftInSeconds: async (
-                cooldownRequest: CooldownLeftInSecondsRequest
-            ) => {
+            cooldownLeftInSeconds: async () => {
+                const token = get().user?.token
                 try {
                     const response =
-                        await CAxios.post<CooldownLeftInSecondsResponse>(
+                        await CAxios.get<CooldownLeftInSecondsResponse>(
                             "/user/cooldown",
-                            cooldownRequest
+                            {
+                                headers: {
+                                    Authorization: `Bearer ${token}`,
+                                },
+                            }
                         )
 
                     const cooldownSeconds =
@@ -190,6 +191,13 @@ export const useUser = create<useUserInterface>()(
                         })
                     }
                 } catch (error) {
+                    // The server enforces the cooldown too, sync with it when it disagrees
+                    const apiError = getApiError(error)
+                    if (apiError?.code === "cooldown_active") {
+                        const details = apiError.details as CooldownLeftInSecondsResponse
+                        get().updateCooldown(details.cooldown_left_in_seconds)
+                        return
+                    }
                     console.error("Failed to update move date:", error)
                 }
             }
@@ -202,4 +210,4 @@ export const useUser = create<useUserInterface>()(
             }),
         }
     )
-)
\ No newline at end of file
+)
\ No newline at end of file
@@ -34,10 +34,6 @@ export type RegisterResponse = {
 
 // --------------------------------------------------------------------
 
-export type CooldownLeftInSecondsRequest = {
-    token: string
-}
-
 export type CooldownLeftInSecondsResponse = {
     cooldown_left_in_seconds: number
-}
\ No newline at end of file
+}
\ No newline at end of file
@@ -6,6 +6,8 @@ import CTabs from "./components/CTabs"
 
 // Types
 import Alert from "./types/alert"
+import { getApiError } from "./types/apiError"
+import type { ApiError, ApiErrorResponse } from "./types/apiError"
 
-export { CLogoSection, CButton, CInputField, CAlert, CTabs }
-export type { Alert }
+export { CLogoSection, CButton, CInputField, CAlert, CTabs, getApiError }
+export type { Alert, ApiError, ApiErrorResponse }
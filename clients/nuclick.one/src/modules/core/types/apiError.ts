@@ -0,0 +1,21 @@
+import { isAxiosError } from "axios"
+
+// Error envelope returned by every backend handler
+export type ApiError = {
+    code: string // Machine readable code, e.g. "invalid_credentials"
+    message: string // Human readable message
+    details?: unknown // Code specific extra data
+    request_id?: string // Correlates the error with server logs
+}
+
+export type ApiErrorResponse = {
+    error: ApiError
+}
+
+// Extracts the API error from a failed request, if the server sent one
+export const getApiError = (error: unknown): ApiError | null => {
+    if (isAxiosError<ApiErrorResponse>(error) && error.response?.data?.error) {
+        return error.response.data.error
+    }
+    return null
+}
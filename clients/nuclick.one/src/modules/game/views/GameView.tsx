@@ -90,7 +90,7 @@ const GameView: React.FC = () => {
     // Cooldown sync on first load
     useEffect(() => {
         if (isAuthenticated() && user?.token) {
-            cooldownLeftInSeconds({ token: user.token })
+            cooldownLeftInSeconds()
                 .then(() => {
                     console.log("Cooldown synced with backend.")
                 })
@@ -0,0 +1,18 @@
+// Package api holds the OpenAPI document describing every HTTP route of the server
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI 3 document, served at /api/openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
+
+// ServeSpec serves the OpenAPI document
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
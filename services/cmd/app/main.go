@@ -2,180 +2,75 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	auth_repo "services/internal/auth/repo"
-	auth_service "services/internal/auth/service"
-
-	province_repo "services/internal/province/repo"
-	province_service "services/internal/province/service"
-
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-
-	"github.com/kahlery/pkg/go/log/util"
+	"services/internal/app"
+	"services/internal/config"
+	"services/internal/core/logging"
+	"services/internal/core/metrics"
+	"services/internal/core/tracing"
 )
 
-// Clients
-var (
-	mongoClient *mongo.Client
-)
-
-// Services
-var (
-	authService     *auth_service.AuthService
-	provinceService *province_service.ProvinceService
-)
+// connectTimeout bounds connecting to the store at startup
+const connectTimeout = 10 * time.Second
 
-// Repos
-var (
-	userRepo     *auth_repo.UserRepo
-	provinceRepo *province_repo.ProvinceRepo
-)
-
-// Main --------------------------------------------------------------------
-
-func init() {
-	envType := os.Getenv("ENV")
-	if envType != "prod" {
-		if err := godotenv.Load("../../.env"); err != nil {
-			util.LogError("error loading .env"+err.Error(), "main.init()", "")
-		}
-	} else {
-		util.LogSuccess(
-			"Environment variables:"+
-				"\n"+
-				os.Getenv("DB")+
-				"\n"+
-				os.Getenv("PORT")+
-				"\n"+
-				os.Getenv("S3_BUCKET_NAME"),
-			"main.init()",
-			"",
-		)
-	}
-
-	initClients()
-	initRepos()
-	initServices()
-
-	dir, err := os.Getwd()
+func main() {
+	cfg, err := config.Load("app", os.Args[1:])
 	if err != nil {
-		util.LogError("failed to get working directory: "+err.Error(), "main.init()", "")
-	} else {
-		util.LogSuccess("working directory can be reached:", "main.init()", "")
-		fmt.Println(dir)
+		slog.Error("Invalid configuration", logging.Err(err))
+		os.Exit(2)
 	}
-}
-
-func main() {
-	mux := http.NewServeMux()
-	setupRoutes(mux)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.IsProd(), cfg.LogLevel).With("binary", "app"))
+	slog.Info("Configuration loaded", "config", cfg.String())
 
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: withCORS(mux),
-	}
+	// Stop on Ctrl+C locally and on SIGTERM from Docker/compose
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	util.LogSuccess("💣 Server starting on port "+port, "main.main()", "")
-	if err := server.ListenAndServe(); err != nil {
-		util.LogError("Server failed to start: "+err.Error(), "main.main()", "")
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("Server failed", logging.Err(err))
 		os.Exit(1)
 	}
 }
 
-// Inits --------------------------------------------------------------------
-
-func initClients() {
-	setupDBConnection()
-}
-
-func initRepos() {
-	db := mongoClient.Database("nuky_db")
-
-	userRepo = auth_repo.NewUserRepo(db.Collection("users"))
-	provinceRepo = province_repo.NewProvinceRepo(db.Collection("provinces"))
-
-	util.LogSuccess("Repositories initialized", "main.initRepos()", "")
-}
-
-func initServices() {
-	authService = auth_service.NewAuthService(userRepo)
+func run(ctx context.Context, cfg *config.Config) error {
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
 
-	startDateStr := os.Getenv("GAME_START_DATE")
-	if startDateStr == "" {
-		log.Fatal("GAME_START_DATE environment variable is required")
-	}
-
-	startDate, err := time.Parse(time.RFC3339, startDateStr)
+	tp, shutdownTracing, err := tracing.Setup(ctx, cfg.TraceExporter, "nuky-app", os.Stdout)
 	if err != nil {
-		log.Fatalf("Invalid GAME_START_DATE format: %v", err)
+		return err
 	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-	provinceService = province_service.NewProvinceService(provinceRepo, startDate)
-
-	util.LogSuccess("Services initialized", "main.initServices()", "")
-}
-
-// Setups --------------------------------------------------------------------
-
-func setupDBConnection() {
-	var err error
-	mongoClient, err = mongo.Connect(context.TODO(), options.Client().ApplyURI(os.Getenv("DB")))
-	if err != nil {
-		panic(err)
-	}
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("Failed to flush traces", logging.Err(err))
+		}
+	}()
 
-	err = mongoClient.Ping(context.TODO(), nil)
+	tel := app.Telemetry{Metrics: metrics.New(), Tracer: tp}
+	stores, err := app.OpenStores(connectCtx, cfg, tel)
 	if err != nil {
-		util.LogError("Failed to connect to MongoDB: "+err.Error(), "main.setupDBConnection()", "")
-		panic(err)
+		return err
 	}
+	slog.Info("Connected to store", "store", cfg.Store)
 
-	util.LogSuccess("Connected to MongoDB", "main.setupDBConnection()", "")
-}
-
-func setupRoutes(mux *http.ServeMux) {
-	// Public Auth routes
-	mux.HandleFunc("/api/auth/register", authService.Register)
-	mux.HandleFunc("/api/auth/login", authService.Login)
-
-	// Province routes
-	mux.HandleFunc("/api/province", provinceService.GetAllProvinces)
-	mux.HandleFunc("/api/province/top", provinceService.GetTopProvinces)
-	mux.HandleFunc("/api/province/attack", provinceService.AttackProvince)
-	mux.HandleFunc("/api/province/support", provinceService.SupportProvince)
-	mux.HandleFunc("/api/province/round", provinceService.GetCurrentRoundHandler)
-
-	// Gaming mechanics routes
-	mux.HandleFunc("/api/user/update-move-date", authService.UpdateMoveDate)
-	mux.HandleFunc("/api/user/cooldown", authService.GetCooldownLeft)
-
-	util.LogSuccess("Routes initialized", "main.setupRoutes()", "")
-}
-
-func withCORS(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	a := app.New(cfg, stores, tel)
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-		// Handle preflight
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+		if err := a.Close(closeCtx); err != nil {
+			slog.Error("Failed to close the store", logging.Err(err))
 		}
+	}()
 
-		handler.ServeHTTP(w, r)
-	})
+	return a.Run(ctx)
 }
@@ -0,0 +1,99 @@
+// Command bots plays a running deployment with bot players, to load test it or to fill a
+// lobby. Every bot signs up through the API, or logs back in when it played before under
+// the same name, and moves whenever its cooldown allows until interrupted.
+//
+//	go run ./cmd/bots -target http://localhost:8080 -bots random:50,pile-on-leader:10 -password b0tpassw0rd
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"services/internal/bot"
+	"services/internal/core/logging"
+)
+
+// requestTimeout bounds a single request of a bot
+const requestTimeout = 10 * time.Second
+
+func main() {
+	fs := flag.NewFlagSet("bots", flag.ContinueOnError)
+	target := fs.String("target", "http://localhost:8080", "base URL of the API to play")
+	squads := fs.String("bots", "random:10", "bots to play with, like random:3,defend-home:2,pile-on-leader:1")
+	password := fs.String("password", os.Getenv("BOT_PASSWORD"), "password of the bot accounts (env BOT_PASSWORD)")
+	prefix := fs.String("prefix", "bot", "prefix of the bot usernames, runs with another prefix play other accounts")
+	inviteCode := fs.String("lobby", "", "invite code of a lobby to play instead of the public game")
+	logLevel := fs.String("log-level", "info", "one of debug, info, warn, error")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintln(os.Stderr, "log-level must be one of debug, info, warn, error")
+		os.Exit(2)
+	}
+	slog.SetDefault(logging.New(os.Stderr, false, level).With("binary", "bots"))
+
+	parsed, err := bot.ParseSquads(*squads)
+	if err != nil {
+		slog.Error("Invalid bots", logging.Err(err))
+		os.Exit(2)
+	}
+	if *password == "" {
+		slog.Error("A password is required, pass -password or set BOT_PASSWORD")
+		os.Exit(2)
+	}
+
+	// Stop on Ctrl+C locally and on SIGTERM from Docker/compose
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, *target, parsed, *password, *prefix, *inviteCode); err != nil {
+		slog.Error("Bots failed", logging.Err(err))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, target string, squads []bot.Squad, password, prefix, inviteCode string) error {
+	client := bot.NewClient(target, &http.Client{Timeout: requestTimeout})
+	fleet := bot.NewFleet(client, bot.NewAPIAccounts(client, password))
+
+	var errs []error
+	deployed := 0
+	for _, squad := range squads {
+		for n := 1; n <= squad.Count; n++ {
+			b, err := fleet.Enlist(ctx, bot.PublicGame, bot.Username(prefix, squad.Strategy, n), squad.Strategy)
+			if err == nil && inviteCode != "" {
+				var lobbyID string
+				lobbyID, err = client.JoinLobby(ctx, b.Account.Token, inviteCode)
+				b.Game = bot.LobbyGame(lobbyID)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			fleet.Deploy(b)
+			deployed++
+		}
+	}
+	if deployed == 0 {
+		return errors.Join(append(errs, errors.New("no bot could be enlisted"))...)
+	}
+	if len(errs) > 0 {
+		slog.Warn("Some bots could not be enlisted", "failed", len(errs), logging.Err(errors.Join(errs...)))
+	}
+
+	slog.Info("Bots playing", "target", target, "bots", deployed, "lobby", inviteCode)
+	fleet.Run(ctx)
+	return nil
+}
@@ -2,109 +2,96 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
-	"github.com/robfig/cron/v3"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-
-	province_repo "services/internal/province/repo"
-	province_service "services/internal/province/service"
-)
-
-var (
-	mongoClient     *mongo.Client
-	provinceService *province_service.ProvinceService
-	provinceRepo    *province_repo.ProvinceRepo
+	"services/internal/app"
+	"services/internal/config"
+	"services/internal/core/logging"
+	"services/internal/core/metrics"
+	"services/internal/core/tracing"
 )
 
-func init() {
-	// Load environment variables
-	envType := os.Getenv("ENV")
-	if envType != "prod" {
-		if err := godotenv.Load("../../.env"); err != nil {
-			log.Printf("Error loading .env: %v", err)
-		}
-	}
-
-	initClients()
-	initRepos()
-	initServices()
-}
+// connectTimeout bounds connecting to the store at startup
+const connectTimeout = 10 * time.Second
 
 func main() {
-	defer mongoClient.Disconnect(context.TODO())
-
-	// Setting up to work with UTC
-	c := cron.New(cron.WithLocation(time.UTC))
-
-	_, err := c.AddFunc("00 14 * * *", func() {
-		log.Println("Daily Nuke Time!")
-
-		// Create context with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		// Execute the destroyment round
-		roundCount, err := provinceService.ExecuteDestroymentRound(ctx)
-		if err != nil {
-			log.Printf("Nuke updating error: %v", err)
-		} else {
-			log.Printf("Nuke operation successful! Round count: %d", roundCount)
-		}
-	})
-
+	// Load configuration
+	cfg, err := config.Load("cronjob", os.Args[1:])
 	if err != nil {
-		log.Fatalf("Adding Cron job error: %v", err)
+		slog.Error("Invalid configuration", logging.Err(err))
+		os.Exit(2)
 	}
 
-	c.Start()
-	log.Println("Nuke timer started. Daily nuke at 14:00 UTC")
+	slog.SetDefault(logging.New(os.Stderr, cfg.IsProd(), cfg.LogLevel).With("binary", "cronjob"))
+	slog.Info("Configuration loaded", "config", cfg.String())
 
-	// Keep the program running
-	select {}
-}
+	// Stop on Ctrl+C locally and on SIGTERM from Docker/compose
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func initClients() {
-	setupDBConnection()
-}
-
-func initRepos() {
-	db := mongoClient.Database("nuky_db")
-	provinceRepo = province_repo.NewProvinceRepo(db.Collection("provinces"))
-	log.Println("Repository initialized")
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("Nuke timer failed", logging.Err(err))
+		os.Exit(1)
+	}
 }
 
-func initServices() {
-	startDateStr := os.Getenv("GAME_START_DATE")
-	if startDateStr == "" {
-		log.Fatal("GAME_START_DATE environment variable is required")
+func run(ctx context.Context, cfg *config.Config) error {
+	// Nuking from both would run every round twice
+	if cfg.NukeInAPI {
+		return fmt.Errorf("%s is set, the API runs the nuke timer", config.KeyNukeInAPI)
 	}
 
-	startDate, err := time.Parse(time.RFC3339, startDateStr)
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	tp, shutdownTracing, err := tracing.Setup(ctx, cfg.TraceExporter, "nuky-cronjob", os.Stdout)
 	if err != nil {
-		log.Fatalf("Invalid GAME_START_DATE format: %v", err)
+		return err
 	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-	provinceService = province_service.NewProvinceService(provinceRepo, startDate)
-
-	log.Println("Service initialized")
-}
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("Failed to flush traces", logging.Err(err))
+		}
+	}()
 
-func setupDBConnection() {
-	var err error
-	mongoClient, err = mongo.Connect(context.TODO(), options.Client().ApplyURI(os.Getenv("DB")))
+	tel := app.Telemetry{Metrics: metrics.New(), Tracer: tp}
+	stores, err := app.OpenStores(connectCtx, cfg, tel)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		return err
 	}
+	slog.Info("Connected to store", "store", cfg.Store)
 
-	err = mongoClient.Ping(context.TODO(), nil)
-	if err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
-	}
+	a := app.New(cfg, stores, tel)
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := a.Close(closeCtx); err != nil {
+			slog.Error("Failed to close the store", logging.Err(err))
+		}
+	}()
+
+	// The probes stop with the timer, and the timer stops if the probes can't be served
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	healthErr := make(chan error, 1)
+	go func() {
+		defer stop()
+		healthErr <- a.RunHealth(ctx)
+	}()
+
+	timerErr := a.RunNukeTimer(ctx)
+	stop()
 
-	log.Println("Connected to MongoDB")
+	return errors.Join(timerErr, <-healthErr)
 }
@@ -0,0 +1,272 @@
+// Package app wires repositories, services and the HTTP handler from the configuration.
+//
+// Both binaries are thin wrappers around it, tests start the same App in-process on
+// memory stores.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	auth_service "services/internal/auth/service"
+	"services/internal/bot"
+	"services/internal/config"
+	"services/internal/core/cors"
+	"services/internal/core/health"
+	"services/internal/core/logging"
+	"services/internal/core/metrics"
+	"services/internal/core/requestid"
+	lobby_service "services/internal/lobby/service"
+	province_repo "services/internal/province/repo"
+	province_service "services/internal/province/service"
+	"services/internal/router"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// lobbyNukeCheck is how often the API looks for lobbies whose round is over, well below
+// the shortest round a lobby can have
+const lobbyNukeCheck = 30 * time.Second
+
+type App struct {
+	cfg    *config.Config
+	stores *Stores
+
+	AuthService     *auth_service.AuthService
+	ProvinceService *province_service.ProvinceService
+	LobbyService    *lobby_service.LobbyService
+
+	// Bots play through the handler of the API, the public game's come from the
+	// configuration, the lobbies' are seated by their owners
+	Bots *bot.Fleet
+
+	// Checker runs the readiness checks, RunNukeTimer adds the scheduler to them
+	Checker   *health.Checker
+	scheduler schedulerState
+	Metrics   *metrics.Metrics
+	tracer    trace.TracerProvider
+
+	handler http.Handler
+}
+
+// New builds the services and the HTTP handler on the given stores, reporting to tel.
+// The App owns the stores from now on, Close releases them
+func New(cfg *config.Config, stores *Stores, tel Telemetry) *App {
+	m, tp := tel.Metrics, tel.tracerProvider()
+
+	a := &App{
+		cfg:             cfg,
+		stores:          stores,
+		AuthService:     auth_service.NewAuthService(stores.Users),
+		ProvinceService: province_service.NewProvinceService(stores.Provinces, cfg.GameStartDate),
+		LobbyService:    lobby_service.NewLobbyService(stores.Lobbies, stores.Provinces, stores.LobbyProvinces),
+		Checker:         health.NewChecker(),
+		Metrics:         m,
+		tracer:          tp,
+	}
+	a.AuthService.SetMetrics(m)
+	a.AuthService.SetTracerProvider(tp)
+	a.ProvinceService.SetMetrics(m)
+	a.ProvinceService.SetTracerProvider(tp)
+	a.ProvinceService.SetCacheTTL(cfg.CacheTTL)
+	a.ProvinceService.SetRules(cfg.Rules)
+	a.ProvinceService.SetPlayers(stores.Players)
+	a.ProvinceService.SetMoveLog(stores.Moves)
+	a.ProvinceService.SetAlliances(stores.Alliances)
+	a.ProvinceService.SetMoveFlushInterval(cfg.MoveFlush)
+	a.LobbyService.SetTracerProvider(tp)
+	a.LobbyService.SetCacheTTL(cfg.CacheTTL)
+	a.LobbyService.SetRules(cfg.Rules)
+	a.AuthService.SetHomeProvinceCheck(func(ctx context.Context, provinceID string) (bool, error) {
+		_, err := stores.Provinces.GetByID(ctx, provinceID)
+		if errors.Is(err, province_repo.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+
+	// An App can't be built without a loaded configuration, the check only reports it
+	a.Checker.Add("config", func(ctx context.Context) error { return nil })
+	a.Checker.Add("store", stores.Ping)
+
+	mux := http.NewServeMux()
+	router.Setup(mux, a.AuthService, a.ProvinceService, a.LobbyService, a.Checker, m)
+	a.handler = cors.Middleware(corsPolicy(cfg), traceHandler(tp, mux, requestid.Middleware(m.InstrumentHandler(mux, router.WithJSONErrors(mux)))))
+
+	a.Bots = bot.NewFleet(bot.NewHandlerClient(a.handler), bot.NewStoreAccounts(stores.Users))
+	a.LobbyService.SetBots(a.Bots)
+
+	return a
+}
+
+// Handler is the full HTTP handler of the API, middlewares included
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// Run listens on the configured port and serves until ctx is done
+func (a *App) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", a.cfg.Addr())
+	if err != nil {
+		return err
+	}
+
+	return a.Serve(ctx, ln)
+}
+
+// Serve serves the API on ln until ctx is done, then drains in-flight requests for up
+// to the configured shutdown timeout. A clean shutdown returns nil. Buffered moves are
+// flushed while serving and once more after the last request, lobbies are nuked on their
+// schedules and bots play while serving. With NUKE_IN_API the public game is nuked here
+// too, flushing the buffered moves first, and the nuke timer stops before the last flush
+func (a *App) Serve(ctx context.Context, ln net.Listener) error {
+	flusherCtx, stopFlusher := context.WithCancel(context.WithoutCancel(ctx))
+	flusherDone := make(chan struct{})
+	go func() {
+		defer close(flusherDone)
+		a.ProvinceService.RunMoveFlusher(flusherCtx)
+	}()
+	defer func() {
+		stopFlusher()
+		<-flusherDone
+	}()
+
+	if a.cfg.NukeInAPI {
+		nukeTimerCtx, stopNukeTimer := context.WithCancel(ctx)
+		nukeTimerDone := make(chan struct{})
+		go func() {
+			defer close(nukeTimerDone)
+			if err := a.RunNukeTimer(nukeTimerCtx); err != nil {
+				slog.Error("Nuke timer failed", logging.Err(err))
+			}
+		}()
+		defer func() {
+			stopNukeTimer()
+			<-nukeTimerDone
+		}()
+	}
+
+	lobbyNukesCtx, stopLobbyNukes := context.WithCancel(ctx)
+	lobbyNukesDone := make(chan struct{})
+	go func() {
+		defer close(lobbyNukesDone)
+		a.LobbyService.RunNukes(lobbyNukesCtx, lobbyNukeCheck)
+	}()
+	defer func() {
+		stopLobbyNukes()
+		<-lobbyNukesDone
+	}()
+
+	botsCtx, stopBots := context.WithCancel(ctx)
+	botsDone := make(chan struct{})
+	go func() {
+		defer close(botsDone)
+		a.runBots(botsCtx)
+	}()
+	defer func() {
+		stopBots()
+		<-botsDone
+	}()
+
+	slog.Info("💣 Server listening", "addr", ln.Addr().String())
+
+	return a.serveHTTP(ctx, ln, a.handler)
+}
+
+// runBots deploys the configured bots of the public game and the bots seated in lobbies,
+// then runs them until ctx is done. A bot that can't be signed up is left out
+func (a *App) runBots(ctx context.Context) {
+	for _, squad := range a.cfg.Bots {
+		for n := 1; n <= squad.Count; n++ {
+			b, err := a.Bots.Enlist(ctx, bot.PublicGame, bot.Username("bot", squad.Strategy, n), squad.Strategy)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to enlist a bot", "strategy", squad.Strategy, logging.Err(err))
+				continue
+			}
+			a.Bots.Deploy(b)
+		}
+	}
+
+	if err := a.LobbyService.DeployBots(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to deploy the bots of some lobbies", logging.Err(err))
+	}
+
+	a.Bots.Run(ctx)
+}
+
+// RunHealth listens on the configured health port and serves the probes and metrics
+// alone until ctx is done, for the nuke timer which has no API
+func (a *App) RunHealth(ctx context.Context) error {
+	ln, err := net.Listen("tcp", a.cfg.HealthAddr())
+	if err != nil {
+		return fmt.Errorf("health listener: %w", err)
+	}
+
+	return a.ServeHealth(ctx, ln)
+}
+
+// ServeHealth serves the probes and metrics alone on ln until ctx is done
+func (a *App) ServeHealth(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	router.SetupOps(mux, a.Checker, a.Metrics)
+	slog.Info("Serving health probes and metrics", "addr", ln.Addr().String())
+
+	return a.serveHTTP(ctx, ln, requestid.Middleware(a.Metrics.InstrumentHandler(mux, router.WithJSONErrors(mux))))
+}
+
+func (a *App) serveHTTP(ctx context.Context, ln net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := a.cfg.ShutdownTimeout
+	slog.Info("Shutting down, waiting for in-flight requests", "addr", ln.Addr().String(), "timeout", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Server shutdown did not complete, closing remaining connections", "addr", ln.Addr().String(), logging.Err(err))
+		server.Close()
+		return errors.Join(errors.New("server shutdown did not complete"), err)
+	}
+
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.Info("Server stopped", "addr", ln.Addr().String())
+	return nil
+}
+
+// Close releases the stores
+func (a *App) Close(ctx context.Context) error {
+	return a.stores.Close(ctx)
+}
+
+// corsPolicy is the cross-origin policy of the API, scripts may read the headers
+// telling them how to correlate, retry and revalidate a request
+func corsPolicy(cfg *config.Config) cors.Policy {
+	return cors.Policy{
+		AllowedOrigins:   cfg.CORSOrigins,
+		AllowCredentials: cfg.CORSCredentials,
+		AllowedMethods:   cfg.CORSMethods,
+		AllowedHeaders:   cfg.CORSHeaders,
+		ExposedHeaders:   []string{requestid.Header, "Retry-After", "ETag"},
+		MaxAge:           cfg.CORSMaxAge,
+	}
+}
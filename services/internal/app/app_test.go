@@ -0,0 +1,548 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	auth_model "services/internal/auth/model"
+	auth_service "services/internal/auth/service"
+	"services/internal/bot"
+	"services/internal/config"
+	"services/internal/core/health"
+	"services/internal/core/logging"
+	"services/internal/core/metrics"
+	"services/internal/game"
+	province_model "services/internal/province/model"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Env:             "test",
+		Store:           config.StoreMemory,
+		GameStartDate:   time.Now().Add(-72 * time.Hour),
+		ShutdownTimeout: time.Second,
+		NukeTimeout:     time.Second,
+		CORSOrigins:     []string{"http://localhost:5173"},
+		CORSMethods:     []string{"GET", "POST", "PUT", "DELETE"},
+		CORSHeaders:     []string{"Content-Type", "Authorization"},
+		CORSMaxAge:      time.Minute,
+		Rules:           game.DefaultRules(),
+	}
+}
+
+// startApp serves a new App on a random local port, the returned func stops it and
+// reports how Serve returned
+func startApp(t *testing.T, a *App) (string, func() error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- a.Serve(ctx, ln) }()
+
+	stop := func() error {
+		// The transport may have dialed connections it never sent a request on, the
+		// server counts those as busy for seconds
+		http.DefaultClient.CloseIdleConnections()
+		cancel()
+		select {
+		case err := <-served:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Serve did not return after cancel")
+			return nil
+		}
+	}
+
+	return "http://" + ln.Addr().String(), stop
+}
+
+func postJSON(t *testing.T, url, body string, out any) int {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestApp_ServesAgainstMemoryStores(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	stores := MemoryStores(nil, []province_model.Province{
+		{ID: provinceID, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", DestroymentRound: -1},
+	})
+
+	a := New(testConfig(), stores, Telemetry{Metrics: metrics.New()})
+	baseURL, stop := startApp(t, a)
+
+	var registered auth_model.RegisterResponse
+	status := postJSON(t, baseURL+"/api/auth/register", `{"username": "nuke_lord", "email": "lord@nuky.io", "password": "hunter22"}`, &registered)
+	require.Equal(t, http.StatusCreated, status)
+	assert.NotEmpty(t, registered.Token)
+
+	var loggedIn auth_model.LoginResponse
+	status = postJSON(t, baseURL+"/api/auth/login", `{"username": "nuke_lord", "password": "hunter22"}`, &loggedIn)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, registered.User.ID, loggedIn.User.ID)
+
+	status = postJSON(t, baseURL+"/api/province/attack", `{"province_id": "`+provinceID.Hex()+`"}`, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	resp, err := http.Get(baseURL + "/api/province/" + provinceID.Hex())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("X-Request-ID"))
+
+	var got province_model.GetProvinceResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, 1, got.Province.AttackCount)
+
+	resp, err = http.Get(baseURL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	exposition, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(exposition), `nuky_province_moves_total{move="attack",province="`+provinceID.Hex()+`"} 1`)
+	assert.Contains(t, string(exposition), `nuky_http_request_duration_seconds_count{method="POST",route="/api/auth/register",status="201"} 1`)
+
+	assert.NoError(t, stop())
+	assert.NoError(t, a.Close(context.Background()))
+}
+
+func TestApp_RegistersWithExistingHomeProvince(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	stores := MemoryStores(nil, []province_model.Province{
+		{ID: provinceID, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", DestroymentRound: -1},
+	})
+
+	a := New(testConfig(), stores, Telemetry{})
+	baseURL, stop := startApp(t, a)
+
+	status := postJSON(t, baseURL+"/api/auth/register", `{"username": "nuke_lord", "email": "lord@nuky.io", "password": "hunter22", "home_province_id": "`+primitive.NewObjectID().Hex()+`"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	var registered auth_model.RegisterResponse
+	status = postJSON(t, baseURL+"/api/auth/register", `{"username": "nuke_lord", "email": "lord@nuky.io", "password": "hunter22", "home_province_id": "`+provinceID.Hex()+`"}`, &registered)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, provinceID.Hex(), registered.User.HomeProvinceID)
+
+	assert.NoError(t, stop())
+}
+
+func TestApp_BotsMoveWithTheCooldownOfAPlayer(t *testing.T) {
+	ctx := context.Background()
+	leaderID := primitive.NewObjectID()
+	stores := MemoryStores(nil, []province_model.Province{
+		{ID: leaderID, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", AttackCount: 10, DestroymentRound: -1},
+		{ID: primitive.NewObjectID(), ProvinceName: "Izmir", ProvinceColorHex: "#0000ff", DestroymentRound: -1},
+	})
+	m := metrics.New()
+	a := New(testConfig(), stores, Telemetry{Metrics: m})
+	client := bot.NewHandlerClient(a.Handler())
+
+	// Enlisting twice plays the same account
+	b, err := a.Bots.Enlist(ctx, bot.PublicGame, "bot_pile_1", bot.StrategyPileOnLeader)
+	require.NoError(t, err)
+	again, err := a.Bots.Enlist(ctx, bot.PublicGame, "bot_pile_1", bot.StrategyPileOnLeader)
+	require.NoError(t, err)
+	assert.Equal(t, b.Account, again.Account)
+
+	// Like a new player, the bot waits out a cooldown before its first move
+	wait, err := b.Move(ctx, client)
+	require.NoError(t, err)
+	assert.InDelta(t, auth_service.MoveCooldown.Seconds(), wait.Seconds(), 5)
+
+	user, err := stores.Users.GetUserByUsername(ctx, "bot_pile_1")
+	require.NoError(t, err)
+	started, err := stores.Users.StartMove(ctx, user.ID, user.LastMoveDate, time.Now().Add(-2*auth_service.MoveCooldown))
+	require.NoError(t, err)
+	require.True(t, started)
+
+	// Once it is over the bot piles on the top province through the API and starts a new one
+	wait, err = b.Move(ctx, client)
+	require.NoError(t, err)
+	assert.InDelta(t, auth_service.MoveCooldown.Seconds(), wait.Seconds(), 5)
+
+	leader, err := stores.Provinces.GetByID(ctx, leaderID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, 11, leader.AttackCount)
+
+	rr := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rr.Body.String(), `nuky_http_request_duration_seconds_count{method="POST",route="/api/user/update-move-date",status="429"} 1`)
+	assert.Contains(t, rr.Body.String(), `nuky_province_moves_total{move="attack",province="`+leaderID.Hex()+`"} 1`)
+}
+
+func TestApp_BotsSignBackInOnceTheirTokenExpired(t *testing.T) {
+	ctx := context.Background()
+	leaderID := primitive.NewObjectID()
+	stores := MemoryStores(nil, []province_model.Province{
+		{ID: leaderID, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", AttackCount: 10, DestroymentRound: -1},
+		{ID: primitive.NewObjectID(), ProvinceName: "Izmir", ProvinceColorHex: "#0000ff", DestroymentRound: -1},
+	})
+	a := New(testConfig(), stores, Telemetry{Metrics: metrics.New()})
+	client := bot.NewHandlerClient(a.Handler())
+
+	b, err := a.Bots.Enlist(ctx, bot.PublicGame, "bot_pile_1", bot.StrategyPileOnLeader)
+	require.NoError(t, err)
+
+	// The cooldown is over long after the token of the bot expired
+	user, err := stores.Users.GetUserByUsername(ctx, "bot_pile_1")
+	require.NoError(t, err)
+	started, err := stores.Users.StartMove(ctx, user.ID, user.LastMoveDate, time.Now().Add(-2*auth_service.MoveCooldown))
+	require.NoError(t, err)
+	require.True(t, started)
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userName": user.ID.Hex(),
+		"exp":      time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(os.Getenv("TOKEN_SECRET")))
+	require.NoError(t, err)
+	b.Account.Token = expired
+
+	wait, err := b.Move(ctx, client)
+	require.NoError(t, err)
+	assert.InDelta(t, auth_service.MoveCooldown.Seconds(), wait.Seconds(), 5)
+	assert.NotEqual(t, expired, b.Account.Token)
+	assert.Equal(t, user.ID.Hex(), b.Account.UserID)
+
+	leader, err := stores.Provinces.GetByID(ctx, leaderID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, 11, leader.AttackCount)
+}
+
+func TestApp_BotsAttackNextToTheirHome(t *testing.T) {
+	ctx := context.Background()
+	west, middle, east := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	stores := MemoryStores(nil, []province_model.Province{
+		{ID: west, ProvinceName: "Izmir", DestroymentRound: -1, Neighbors: []string{middle.Hex()}},
+		{ID: middle, ProvinceName: "Ankara", DestroymentRound: -1, Neighbors: []string{west.Hex(), east.Hex()}},
+		{ID: east, ProvinceName: "Kars", AttackCount: 10, DestroymentRound: -1, Neighbors: []string{middle.Hex()}},
+	})
+	cfg := testConfig()
+	cfg.Rules.Attacks.AdjacentOnly = true
+	a := New(cfg, stores, Telemetry{Metrics: metrics.New()})
+	client := bot.NewHandlerClient(a.Handler())
+
+	b, err := a.Bots.Enlist(ctx, bot.PublicGame, "bot_pile_1", bot.StrategyPileOnLeader)
+	require.NoError(t, err)
+	require.NotEmpty(t, b.Account.Home)
+	user, err := stores.Users.GetUserByUsername(ctx, "bot_pile_1")
+	require.NoError(t, err)
+	assert.Equal(t, b.Account.Home, user.HomeProvinceID)
+
+	started, err := stores.Users.StartMove(ctx, user.ID, user.LastMoveDate, time.Now().Add(-2*auth_service.MoveCooldown))
+	require.NoError(t, err)
+	require.True(t, started)
+
+	// The leader may be out of reach, the bot attacks the best ranked province next to its home
+	wait, err := b.Move(ctx, client)
+	require.NoError(t, err)
+	assert.InDelta(t, auth_service.MoveCooldown.Seconds(), wait.Seconds(), 5)
+
+	provinces, err := stores.Provinces.GetAll(ctx)
+	require.NoError(t, err)
+	attacked := 0
+	for _, p := range provinces {
+		if p.AttackCount == 11 || (p.AttackCount == 1 && p.ID != east) {
+			attacked++
+			assert.Contains(t, p.Neighbors, b.Account.Home)
+		}
+	}
+	assert.Equal(t, 1, attacked)
+}
+
+func TestApp_DeploysTheConfiguredBots(t *testing.T) {
+	cfg := testConfig()
+	cfg.Bots = []bot.Squad{{Strategy: bot.StrategyRandom, Count: 2}, {Strategy: bot.StrategyDefendHome, Count: 1}}
+	stores := MemoryStores([]auth_model.User{{ID: primitive.NewObjectID(), Username: "bot_def_1", Email: "someone@nuky.io"}}, nil)
+
+	a := New(cfg, stores, Telemetry{})
+	_, stop := startApp(t, a)
+
+	// A player who took a bot's username keeps the account
+	assert.Eventually(t, func() bool {
+		_, err := stores.Users.GetUserByUsername(context.Background(), "bot_rnd_2")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	user, err := stores.Users.GetUserByUsername(context.Background(), "bot_def_1")
+	require.NoError(t, err)
+	assert.Equal(t, "someone@nuky.io", user.Email)
+
+	assert.NoError(t, stop())
+}
+
+func TestApp_BotsSignUpThroughTheNetwork(t *testing.T) {
+	ctx := context.Background()
+	home := primitive.NewObjectID()
+	a := New(testConfig(), MemoryStores(nil, []province_model.Province{{ID: home, ProvinceName: "Ankara", DestroymentRound: -1}}), Telemetry{})
+	baseURL, stop := startApp(t, a)
+
+	client := bot.NewClient(baseURL, http.DefaultClient)
+	accounts := bot.NewAPIAccounts(client, "b0tpassw0rd")
+	signedUp, err := accounts.Enlist(ctx, bot.Username("load", bot.StrategyDefendHome, 7), home.Hex())
+	require.NoError(t, err)
+	assert.NotEmpty(t, signedUp.Token)
+	assert.Equal(t, home.Hex(), signedUp.Home)
+
+	// A later run logs back in, with the home it was given
+	loggedIn, err := accounts.Enlist(ctx, "load_def_7", "")
+	require.NoError(t, err)
+	assert.Equal(t, signedUp.UserID, loggedIn.UserID)
+	assert.Equal(t, home.Hex(), loggedIn.Home)
+
+	_, err = bot.NewAPIAccounts(client, "wr0ngpassword").Enlist(ctx, "load_def_7", "")
+	var apiErr *bot.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+
+	assert.NoError(t, stop())
+}
+
+func TestApp_ServeReturnsListenerErrors(t *testing.T) {
+	a := New(testConfig(), MemoryStores(nil, nil), Telemetry{Metrics: metrics.New()})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln.Close()
+
+	assert.Error(t, a.Serve(context.Background(), ln))
+}
+
+func TestOpenStores_Memory(t *testing.T) {
+	stores, err := OpenStores(context.Background(), testConfig(), Telemetry{})
+	require.NoError(t, err)
+
+	provinces, err := stores.Provinces.GetAll(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, provinces)
+	assert.NoError(t, stores.Close(context.Background()))
+}
+
+func TestOpenStores_SQLite(t *testing.T) {
+	cfg := testConfig()
+	cfg.Store, cfg.DB = config.StoreSQLite, filepath.Join(t.TempDir(), "nuky.db")
+
+	stores, err := OpenStores(context.Background(), cfg, Telemetry{})
+	require.NoError(t, err)
+	a := New(cfg, stores, Telemetry{})
+	baseURL, stop := startApp(t, a)
+
+	var registered auth_model.RegisterResponse
+	status := postJSON(t, baseURL+"/api/auth/register", `{"username": "nuke_lord", "email": "lord@nuky.io", "password": "hunter22"}`, &registered)
+	require.Equal(t, http.StatusCreated, status)
+
+	status = postJSON(t, baseURL+"/api/province/attack", `{"province_id": "`+primitive.NewObjectID().Hex()+`"}`, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	assert.NoError(t, stop())
+	assert.NoError(t, a.Close(context.Background()))
+
+	// The database outlives the process
+	stores, err = OpenStores(context.Background(), cfg, Telemetry{})
+	require.NoError(t, err)
+	defer stores.Close(context.Background())
+
+	user, err := stores.Users.GetUserByUsername(context.Background(), "nuke_lord")
+	require.NoError(t, err)
+	assert.Equal(t, registered.User.ID, user.ID)
+}
+
+func TestRunNukeTimer_StopsOnCancel(t *testing.T) {
+	a := New(testConfig(), MemoryStores(nil, nil), Telemetry{Metrics: metrics.New()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.RunNukeTimer(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunNukeTimer did not return after cancel")
+	}
+}
+
+func getReadiness(t *testing.T, url string) (int, health.ReadinessResponse) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var report health.ReadinessResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	return resp.StatusCode, report
+}
+
+func TestApp_Readyz(t *testing.T) {
+	a := New(testConfig(), MemoryStores(nil, nil), Telemetry{Metrics: metrics.New()})
+	baseURL, stop := startApp(t, a)
+	defer stop()
+
+	status, report := getReadiness(t, baseURL+"/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]health.CheckResult{
+		"config": {Status: health.StatusOK},
+		"store":  {Status: health.StatusOK},
+	}, report.Checks)
+}
+
+func TestNukeTimer_HealthReportsScheduler(t *testing.T) {
+	a := New(testConfig(), MemoryStores(nil, nil), Telemetry{Metrics: metrics.New()})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.ServeHealth(ctx, ln)
+
+	timerCtx, stopTimer := context.WithCancel(ctx)
+	timerDone := make(chan error, 1)
+	go func() { timerDone <- a.RunNukeTimer(timerCtx) }()
+
+	readyzURL := "http://" + ln.Addr().String() + "/readyz"
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		status, report := getReadiness(t, readyzURL)
+		assert.Equal(c, http.StatusOK, status)
+		assert.Equal(c, health.StatusOK, report.Checks["scheduler"].Status)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	stopTimer()
+	require.NoError(t, <-timerDone)
+
+	status, report := getReadiness(t, readyzURL)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusFail, report.Checks["scheduler"].Status)
+}
+
+func TestApp_LogsCarryRequestAndUser(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, true, slog.LevelInfo))
+	defer slog.SetDefault(previous)
+
+	a := New(testConfig(), MemoryStores(nil, nil), Telemetry{})
+	baseURL, stop := startApp(t, a)
+	defer stop()
+
+	var registered auth_model.RegisterResponse
+	status := postJSON(t, baseURL+"/api/auth/register", `{"username": "nuke_lord", "email": "lord@nuky.io", "password": "hunter22"}`, &registered)
+	require.Equal(t, http.StatusCreated, status)
+
+	// A fresh account is still in cooldown
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/user/update-move-date", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+registered.Token)
+	req.Header.Set("X-Request-ID", "move-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	var rejection map[string]any
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+		var record map[string]any
+		if json.Unmarshal(line, &record) == nil && record["msg"] == "Move rejected by cooldown" {
+			rejection = record
+		}
+	}
+	require.NotNil(t, rejection, buf.String())
+	assert.Equal(t, "move-1", rejection[logging.KeyRequestID])
+	assert.Equal(t, registered.User.ID.Hex(), rejection[logging.KeyUserID])
+}
+
+func TestApp_TracesRequestsAndServiceCalls(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	stores := MemoryStores(nil, []province_model.Province{
+		{ID: provinceID, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", DestroymentRound: -1},
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	a := New(testConfig(), stores, Telemetry{Tracer: tp})
+	baseURL, stop := startApp(t, a)
+	defer stop()
+
+	// The caller's trace is continued
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/province/attack", strings.NewReader(`{"province_id": "`+provinceID.Hex()+`"}`))
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = a.ProvinceService.ExecuteDestroymentRound(context.Background())
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	httpSpan, ok := spans["POST /api/province/attack"]
+	require.True(t, ok, "no span for the HTTP request")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", httpSpan.SpanContext().TraceID().String())
+
+	serviceSpan, ok := spans["ProvinceService.AttackProvince"]
+	require.True(t, ok, "no span for the service call")
+	assert.Equal(t, httpSpan.SpanContext().SpanID(), serviceSpan.Parent().SpanID())
+
+	assert.Contains(t, spans, "ProvinceService.ExecuteDestroymentRound")
+}
+
+func TestApp_AnswersPreflightFromAllowedOrigins(t *testing.T) {
+	a := New(testConfig(), MemoryStores(nil, nil), Telemetry{})
+	baseURL, stop := startApp(t, a)
+	defer stop()
+
+	req, err := http.NewRequest(http.MethodOptions, baseURL+"/api/province/attack", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "http://localhost:5173", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "60", resp.Header.Get("Access-Control-Max-Age"))
+
+	req, err = http.NewRequest(http.MethodGet, baseURL+"/api/province", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"services/internal/core/logging"
+	"services/internal/core/requestid"
+	"services/internal/core/tracing"
+
+	"github.com/robfig/cron/v3"
+)
+
+// NukeSchedule is the cron spec of the daily nuke, in UTC
+const NukeSchedule = "00 14 * * *"
+
+// schedulerState is what the scheduler readiness check reports on
+type schedulerState struct {
+	mu      sync.Mutex
+	running bool
+	next    time.Time
+}
+
+func (s *schedulerState) set(running bool, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running, s.next = running, next
+}
+
+// check fails while the scheduler isn't running or when it missed its next round
+func (s *schedulerState) check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return errors.New("scheduler not running")
+	}
+	if !s.next.IsZero() && time.Since(s.next) > time.Minute {
+		return fmt.Errorf("scheduler missed the round due at %s", s.next.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// RunNukeTimer runs a destroyment round every day at 14:00 UTC until ctx is done. It
+// then lets a running round finish within the shutdown timeout, aborting it otherwise
+func (a *App) RunNukeTimer(ctx context.Context) error {
+	// Running rounds derive their context from this one, cancelling it aborts them
+	jobsCtx, abortJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer abortJobs()
+
+	// Setting up to work with UTC
+	c := cron.New(cron.WithLocation(time.UTC))
+
+	var entryID cron.EntryID
+	entryID, err := c.AddFunc(NukeSchedule, func() {
+		defer a.scheduler.set(true, c.Entry(entryID).Next)
+
+		// Every round gets its own ID so all of its records can be correlated
+		ctx, cancel := context.WithTimeout(requestid.NewContext(jobsCtx, requestid.New()), a.cfg.NukeTimeout)
+		defer cancel()
+
+		ctx, span := tracing.Tracer(a.tracer).Start(ctx, "NukeTimer.Round")
+		defer span.End()
+
+		slog.InfoContext(ctx, "Daily Nuke Time!")
+
+		// Execute the destroyment round
+		result, err := a.ProvinceService.ExecuteDestroymentRound(ctx)
+		if err != nil {
+			tracing.Fail(span, err)
+			slog.ErrorContext(ctx, "Nuke round failed", logging.Err(err))
+		} else {
+			slog.InfoContext(ctx, "Nuke round done", logging.Round(result.Round), "nuked", len(result.Victims))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	a.Checker.Add("scheduler", a.scheduler.check)
+
+	c.Start()
+	a.scheduler.set(true, c.Entry(entryID).Next)
+	slog.Info("Nuke timer started", "schedule", NukeSchedule, "next", c.Entry(entryID).Next)
+
+	// Keep running until asked to stop
+	<-ctx.Done()
+
+	timeout := a.cfg.ShutdownTimeout
+	slog.Info("Shutting down, waiting for a running nuke round", "timeout", timeout)
+
+	// No new rounds are started once Stop is called, the context is done when running ones return
+	jobsDone := c.Stop()
+	a.scheduler.set(false, time.Time{})
+
+	select {
+	case <-jobsDone.Done():
+	case <-time.After(timeout):
+		slog.Warn("Nuke round still running after shutdown timeout, aborting it")
+		abortJobs()
+		<-jobsDone.Done()
+	}
+
+	slog.Info("Nuke timer stopped")
+	return nil
+}
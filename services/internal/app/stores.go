@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	auth_model "services/internal/auth/model"
+	auth_repo "services/internal/auth/repo"
+	auth_service "services/internal/auth/service"
+	"services/internal/config"
+	"services/internal/core/sqldb"
+	lobby_repo "services/internal/lobby/repo"
+	lobby_service "services/internal/lobby/service"
+	province_model "services/internal/province/model"
+	province_repo "services/internal/province/repo"
+	province_service "services/internal/province/service"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Stores holds the repositories the services are built on, backed by the store
+// selected in the configuration
+type Stores struct {
+	Users     auth_service.UserRepository
+	Players   province_service.PlayerRepository // the users, as the province service reads them
+	Provinces province_service.ProvinceRepository
+	Moves     province_service.MoveRepository
+	Alliances province_service.AllianceRepository
+
+	Lobbies        lobby_service.LobbyRepository
+	LobbyProvinces lobby_service.ProvinceStores
+
+	mongoClient *mongo.Client // nil unless backed by MongoDB
+	sqlDB       *sql.DB       // nil unless backed by SQLite or PostgreSQL
+}
+
+// OpenStores connects to the configured store and builds the repositories on it, the
+// database commands are reported to tel
+func OpenStores(ctx context.Context, cfg *config.Config, tel Telemetry) (*Stores, error) {
+	switch cfg.Store {
+	case config.StoreMemory:
+		return MemoryStores(nil, nil), nil
+	case config.StoreMongo:
+		return openMongoStores(ctx, cfg, tel)
+	case config.StoreSQLite:
+		return openSQLStores(ctx, sqldb.DriverSQLite, cfg.DB)
+	case config.StorePostgres:
+		return openSQLStores(ctx, sqldb.DriverPostgres, cfg.DB)
+	default:
+		return nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+}
+
+// MemoryStores builds non persistent stores seeded with the given users and provinces
+func MemoryStores(users []auth_model.User, provinces []province_model.Province) *Stores {
+	userRepo := auth_repo.NewMemoryUserRepo(users...)
+
+	return &Stores{
+		Users:     userRepo,
+		Players:   userRepo,
+		Provinces: province_repo.NewMemoryProvinceRepo(provinces...),
+		Moves:     province_repo.NewMemoryMoveRepo(),
+		Alliances: province_repo.NewMemoryAllianceRepo(),
+
+		Lobbies:        lobby_repo.NewMemoryLobbyRepo(),
+		LobbyProvinces: memoryLobbyProvinces(),
+	}
+}
+
+// memoryLobbyProvinces keeps the provinces of each lobby in a memory repo of its own
+func memoryLobbyProvinces() lobby_service.ProvinceStores {
+	var mu sync.Mutex
+	repos := map[string]*province_repo.MemoryProvinceRepo{}
+
+	return func(lobbyID string) lobby_service.ProvinceRepository {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, ok := repos[lobbyID]; !ok {
+			repos[lobbyID] = province_repo.NewMemoryProvinceRepo()
+		}
+		return repos[lobbyID]
+	}
+}
+
+func openMongoStores(ctx context.Context, cfg *config.Config, tel Telemetry) (*Stores, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.DB).SetMonitor(commandMonitor(tel)))
+	if err != nil {
+		return nil, fmt.Errorf("connect to MongoDB: %w", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.WithoutCancel(ctx))
+		return nil, fmt.Errorf("ping MongoDB: %w", err)
+	}
+
+	db := client.Database(cfg.DBName)
+	userRepo := auth_repo.NewUserRepo(db.Collection("users"))
+
+	return &Stores{
+		Users:     userRepo,
+		Players:   userRepo,
+		Provinces: province_repo.NewProvinceRepo(db.Collection("provinces")),
+		Moves:     province_repo.NewMoveRepo(db.Collection("moves")),
+		Alliances: province_repo.NewAllianceRepo(db.Collection("alliances")),
+		Lobbies:   lobby_repo.NewLobbyRepo(db.Collection("lobbies")),
+		LobbyProvinces: func(lobbyID string) lobby_service.ProvinceRepository {
+			return province_repo.NewProvinceRepo(db.Collection("lobby_provinces_" + lobbyID))
+		},
+		mongoClient: client,
+	}, nil
+}
+
+func openSQLStores(ctx context.Context, driver, dsn string) (*Stores, error) {
+	db, err := sqldb.Open(ctx, driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s database: %w", driver, err)
+	}
+
+	userRepo := auth_repo.NewSQLUserRepo(db)
+
+	return &Stores{
+		Users:     userRepo,
+		Players:   userRepo,
+		Provinces: province_repo.NewSQLProvinceRepo(db),
+		Moves:     province_repo.NewSQLMoveRepo(db),
+		Alliances: province_repo.NewSQLAllianceRepo(db),
+		Lobbies:   lobby_repo.NewSQLLobbyRepo(db),
+		LobbyProvinces: func(lobbyID string) lobby_service.ProvinceRepository {
+			return province_repo.NewSQLLobbyProvinceRepo(db, lobbyID)
+		},
+		sqlDB: db,
+	}, nil
+}
+
+// Ping tells whether the store is reachable
+func (s *Stores) Ping(ctx context.Context) error {
+	switch {
+	case s.mongoClient != nil:
+		return s.mongoClient.Ping(ctx, nil)
+	case s.sqlDB != nil:
+		return s.sqlDB.PingContext(ctx)
+	default:
+		return nil
+	}
+}
+
+// Close releases the connection behind the stores, if any
+func (s *Stores) Close(ctx context.Context) error {
+	switch {
+	case s.mongoClient != nil:
+		return s.mongoClient.Disconnect(ctx)
+	case s.sqlDB != nil:
+		return s.sqlDB.Close()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"services/internal/core/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Telemetry is where an App reports metrics and spans, nothing is reported for a nil field
+type Telemetry struct {
+	Metrics *metrics.Metrics
+	Tracer  trace.TracerProvider
+}
+
+func (t Telemetry) tracerProvider() trace.TracerProvider {
+	if t.Tracer == nil {
+		return noop.NewTracerProvider()
+	}
+
+	return t.Tracer
+}
+
+// untracedPaths are polled by probes and scrapers, tracing them would only add noise
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// traceHandler starts a span for every request served by next, named after the mux
+// pattern that matched it. An incoming W3C trace context is continued
+func traceHandler(tp trace.TracerProvider, mux *http.ServeMux, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.request",
+		otelhttp.WithTracerProvider(tp),
+		otelhttp.WithPropagators(propagation.TraceContext{}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedPaths[r.URL.Path]
+		}),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if _, pattern := mux.Handler(r); pattern != "" {
+				return pattern
+			}
+			return r.Method + " unmatched"
+		}),
+	)
+}
+
+// commandMonitor records a span and the duration of every command sent to MongoDB
+func commandMonitor(tel Telemetry) *event.CommandMonitor {
+	tracing := otelmongo.NewMonitor(otelmongo.WithTracerProvider(tel.tracerProvider()))
+
+	return &event.CommandMonitor{
+		Started: tracing.Started,
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			tracing.Succeeded(ctx, e)
+			tel.Metrics.ObserveStoreOperation(e.CommandName, e.Duration, nil)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			tracing.Failed(ctx, e)
+			tel.Metrics.ObserveStoreOperation(e.CommandName, e.Duration, errors.New(e.Failure))
+		},
+	}
+}
@@ -13,5 +13,18 @@ type User struct {
 	Email        string    `json:"email" bson:"email"`
 	LastMoveDate time.Time `json:"last_move_date" bson:"lastMoveDate"`
 
-	Password string `bson:"password"`
+	// Province the player plays for, chosen at registration, empty if none
+	HomeProvinceID string      `json:"home_province_id,omitempty" bson:"homeProvinceID,omitempty"`
+	Player         PlayerState `json:"player" bson:"player"`
+
+	Password string `json:"-" bson:"password"`
+}
+
+// PlayerState is what the game remembers about a player between moves
+type PlayerState struct {
+	Streak        int            `json:"streak" bson:"streak"`                            // consecutive rounds with a move
+	LastMoveRound int            `json:"last_move_round" bson:"lastMoveRound"`            // 0 before the first move
+	ItemUses      map[string]int `json:"item_uses,omitempty" bson:"itemUses,omitempty"`   // uses of each item in ItemRound
+	ItemRound     int            `json:"item_round,omitempty" bson:"itemRound,omitempty"` // round ItemUses counts for
+	Supported     []string       `json:"supported,omitempty" bson:"supported,omitempty"`  // provinces supported in LastMoveRound
 }
@@ -1,20 +1,19 @@
 package model
 
 // Request DTOs
+// Validation rules are declared with `validate` tags, see services/internal/core/validation
 type LoginRequest struct {
-	Username *string `json:"username"`
-	Email    *string `json:"email"`
-	Password string  `json:"password"`
+	Username *string `json:"username" validate:"required_without=Email,omitempty,max=20"`
+	Email    *string `json:"email" validate:"required_without=Username,omitempty,max=254"`
+	Password string  `json:"password" validate:"required,max=72"`
 }
 
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
+	Username string `json:"username" validate:"required,username"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,password"`
 
-type CooldownLeftInSecondsRequest struct {
-	Token string `json:"token"`
+	HomeProvinceID string `json:"home_province_id,omitempty" validate:"omitempty,objectid"`
 }
 
 // Response DTOs
@@ -31,3 +30,9 @@ type RegisterResponse struct {
 type CooldownLeftInSecondsResponse struct {
 	CooldownLeftInSeconds int `json:"cooldown_left_in_seconds"`
 }
+
+// Error details DTOs
+type LoginThrottledDetails struct {
+	Locked            bool `json:"locked"`
+	RetryAfterSeconds int  `json:"retry_after_seconds"`
+}
@@ -2,13 +2,20 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"services/internal/auth/model"
+	"services/internal/core/logging"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrNotFound is returned by every user repo implementation when a user doesn't exist
+var ErrNotFound = errors.New("user not found")
+
 type UserRepo struct {
 	collection *mongo.Collection
 }
@@ -24,7 +31,7 @@ func (ur *UserRepo) GetUserByID(ctx context.Context, id primitive.ObjectID) (*mo
 	filter := bson.M{"_id": id}
 
 	if err := ur.collection.FindOne(ctx, filter).Decode(&user); err != nil {
-		return nil, err
+		return nil, ur.fail(ctx, "GetUserByID", notFound(err))
 	}
 
 	return user, nil
@@ -41,7 +48,24 @@ func (ur *UserRepo) PutUser(ctx context.Context, user model.User) error {
 	}
 
 	_, err := ur.collection.UpdateOne(ctx, filter, update)
-	return err
+	return ur.fail(ctx, "PutUser", err)
+}
+
+// StartMove moves the last move date of a user from last to now and tells whether this
+// call did, of two moves started together only one does
+func (ur *UserRepo) StartMove(ctx context.Context, id primitive.ObjectID, last, now time.Time) (bool, error) {
+	result, err := ur.collection.UpdateOne(ctx, bson.M{"_id": id, "lastMoveDate": last}, bson.M{"$set": bson.M{"lastMoveDate": now}})
+	if err != nil {
+		return false, ur.fail(ctx, "StartMove", err)
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// PutPlayerState replaces the game state of a player
+func (ur *UserRepo) PutPlayerState(ctx context.Context, id primitive.ObjectID, state model.PlayerState) error {
+	_, err := ur.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"player": state}})
+	return ur.fail(ctx, "PutPlayerState", err)
 }
 
 func (ur *UserRepo) CreateUser(ctx context.Context, user model.User) (primitive.ObjectID, error) {
@@ -51,7 +75,7 @@ func (ur *UserRepo) CreateUser(ctx context.Context, user model.User) (primitive.
 
 	result, err := ur.collection.InsertOne(ctx, user)
 	if err != nil {
-		return primitive.NilObjectID, err
+		return primitive.NilObjectID, ur.fail(ctx, "CreateUser", err)
 	}
 
 	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
@@ -66,7 +90,7 @@ func (ur *UserRepo) GetUserByEmail(ctx context.Context, email string) (*model.Us
 	filter := bson.M{"email": email}
 
 	if err := ur.collection.FindOne(ctx, filter).Decode(&user); err != nil {
-		return nil, err
+		return nil, ur.fail(ctx, "GetUserByEmail", notFound(err))
 	}
 
 	return &user, nil
@@ -77,8 +101,26 @@ func (ur *UserRepo) GetUserByUsername(ctx context.Context, username string) (*mo
 	filter := bson.M{"username": username}
 
 	if err := ur.collection.FindOne(ctx, filter).Decode(&user); err != nil {
-		return nil, err
+		return nil, ur.fail(ctx, "GetUserByUsername", notFound(err))
 	}
 
 	return &user, nil
 }
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err or a missing user is returned as is
+func (ur *UserRepo) fail(ctx context.Context, operation string, err error) error {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		slog.ErrorContext(ctx, "User store operation failed", "operation", operation, logging.Err(err))
+	}
+	return err
+}
+
+// notFound maps the driver's "no documents" error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"services/internal/auth/model"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUserRepo is an in-process UserRepo for tests and local runs without MongoDB
+type MemoryUserRepo struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]model.User
+}
+
+// NewMemoryUserRepo creates an in-memory user repository seeded with the given users
+func NewMemoryUserRepo(users ...model.User) *MemoryUserRepo {
+	mur := &MemoryUserRepo{
+		users: make(map[primitive.ObjectID]model.User),
+	}
+
+	for _, user := range users {
+		if user.ID.IsZero() {
+			user.ID = primitive.NewObjectID()
+		}
+		mur.users[user.ID] = user
+	}
+
+	return mur
+}
+
+func (mur *MemoryUserRepo) GetUserByID(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
+	mur.mu.RLock()
+	defer mur.mu.RUnlock()
+
+	user, ok := mur.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (mur *MemoryUserRepo) PutUser(ctx context.Context, user model.User) error {
+	mur.mu.Lock()
+	defer mur.mu.Unlock()
+
+	stored, ok := mur.users[user.ID]
+	if !ok {
+		return nil // Same as an UpdateOne matching nothing
+	}
+
+	// Only the fields the Mongo repo sets are updated
+	stored.Email = user.Email
+	stored.Password = user.Password
+	stored.LastMoveDate = user.LastMoveDate
+	mur.users[user.ID] = stored
+
+	return nil
+}
+
+func (mur *MemoryUserRepo) StartMove(ctx context.Context, id primitive.ObjectID, last, now time.Time) (bool, error) {
+	mur.mu.Lock()
+	defer mur.mu.Unlock()
+
+	stored, ok := mur.users[id]
+	if !ok || !stored.LastMoveDate.Equal(last) {
+		return false, nil
+	}
+
+	stored.LastMoveDate = now
+	mur.users[id] = stored
+
+	return true, nil
+}
+
+func (mur *MemoryUserRepo) PutPlayerState(ctx context.Context, id primitive.ObjectID, state model.PlayerState) error {
+	mur.mu.Lock()
+	defer mur.mu.Unlock()
+
+	stored, ok := mur.users[id]
+	if !ok {
+		return nil
+	}
+
+	stored.Player = state
+	mur.users[id] = stored
+
+	return nil
+}
+
+func (mur *MemoryUserRepo) CreateUser(ctx context.Context, user model.User) (primitive.ObjectID, error) {
+	mur.mu.Lock()
+	defer mur.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+
+	mur.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (mur *MemoryUserRepo) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return mur.find(func(user model.User) bool { return user.Email == email })
+}
+
+func (mur *MemoryUserRepo) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return mur.find(func(user model.User) bool { return user.Username == username })
+}
+
+func (mur *MemoryUserRepo) find(match func(model.User) bool) (*model.User, error) {
+	mur.mu.RLock()
+	defer mur.mu.RUnlock()
+
+	for _, user := range mur.users {
+		if match(user) {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"services/internal/auth/model"
+	"services/internal/core/logging"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLUserRepo is a UserRepo on the users table of a SQLite or PostgreSQL database
+type SQLUserRepo struct {
+	db *sql.DB
+}
+
+// NewSQLUserRepo creates a user repository on a database opened with sqldb.Open
+func NewSQLUserRepo(db *sql.DB) *SQLUserRepo {
+	return &SQLUserRepo{
+		db: db,
+	}
+}
+
+const selectUser = `SELECT id, username, email, password, last_move_date, home_province_id, streak, last_move_round, item_round, item_uses, supported FROM users`
+
+func (sur *SQLUserRepo) GetUserByID(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
+	user, err := scanUser(sur.db.QueryRowContext(ctx, selectUser+` WHERE id = $1`, id.Hex()))
+	return user, sur.fail(ctx, "GetUserByID", err)
+}
+
+func (sur *SQLUserRepo) PutUser(ctx context.Context, user model.User) error {
+	_, err := sur.db.ExecContext(ctx,
+		`UPDATE users SET email = $1, password = $2, last_move_date = $3 WHERE id = $4`,
+		user.Email, user.Password, user.LastMoveDate.UTC(), user.ID.Hex())
+	return sur.fail(ctx, "PutUser", err)
+}
+
+// StartMove moves the last move date of a user from last to now and tells whether this
+// call did, of two moves started together only one does
+func (sur *SQLUserRepo) StartMove(ctx context.Context, id primitive.ObjectID, last, now time.Time) (bool, error) {
+	result, err := sur.db.ExecContext(ctx, `UPDATE users SET last_move_date = $1 WHERE id = $2 AND last_move_date = $3`, now.UTC(), id.Hex(), last.UTC())
+	if err != nil {
+		return false, sur.fail(ctx, "StartMove", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, sur.fail(ctx, "StartMove", err)
+	}
+
+	return affected > 0, nil
+}
+
+// PutPlayerState replaces the game state of a player
+func (sur *SQLUserRepo) PutPlayerState(ctx context.Context, id primitive.ObjectID, state model.PlayerState) error {
+	itemUses, err := json.Marshal(state.ItemUses)
+	if err != nil {
+		return err
+	}
+	supported, err := json.Marshal(state.Supported)
+	if err != nil {
+		return err
+	}
+
+	_, err = sur.db.ExecContext(ctx,
+		`UPDATE users SET streak = $1, last_move_round = $2, item_round = $3, item_uses = $4, supported = $5 WHERE id = $6`,
+		state.Streak, state.LastMoveRound, state.ItemRound, string(itemUses), string(supported), id.Hex())
+	return sur.fail(ctx, "PutPlayerState", err)
+}
+
+func (sur *SQLUserRepo) CreateUser(ctx context.Context, user model.User) (primitive.ObjectID, error) {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+
+	_, err := sur.db.ExecContext(ctx,
+		`INSERT INTO users (id, username, email, password, last_move_date, home_province_id) VALUES ($1, $2, $3, $4, $5, $6)`,
+		user.ID.Hex(), user.Username, user.Email, user.Password, user.LastMoveDate.UTC(), user.HomeProvinceID)
+	if err != nil {
+		return primitive.NilObjectID, sur.fail(ctx, "CreateUser", err)
+	}
+
+	return user.ID, nil
+}
+
+func (sur *SQLUserRepo) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	user, err := scanUser(sur.db.QueryRowContext(ctx, selectUser+` WHERE email = $1`, email))
+	return user, sur.fail(ctx, "GetUserByEmail", err)
+}
+
+func (sur *SQLUserRepo) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	user, err := scanUser(sur.db.QueryRowContext(ctx, selectUser+` WHERE username = $1`, username))
+	return user, sur.fail(ctx, "GetUserByUsername", err)
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err or a missing user is returned as is
+func (sur *SQLUserRepo) fail(ctx context.Context, operation string, err error) error {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		slog.ErrorContext(ctx, "User store operation failed", "operation", operation, logging.Err(err))
+	}
+	return err
+}
+
+// scanUser reads a row selected with selectUser, ErrNotFound if there is none
+func scanUser(row *sql.Row) (*model.User, error) {
+	var user model.User
+	var id, itemUses, supported string
+	if err := row.Scan(&id, &user.Username, &user.Email, &user.Password, &user.LastMoveDate, &user.HomeProvinceID,
+		&user.Player.Streak, &user.Player.LastMoveRound, &user.Player.ItemRound, &itemUses, &supported); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = objectID
+
+	if err := json.Unmarshal([]byte(itemUses), &user.Player.ItemUses); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(supported), &user.Player.Supported); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/auth/model"
+	"services/internal/core/sqldb/sqldbtest"
+)
+
+func TestSQLUserRepo(t *testing.T) {
+	sqldbtest.Run(t, testSQLUserRepo)
+}
+
+func testSQLUserRepo(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	sur := NewSQLUserRepo(db)
+	lastMove := time.Date(2025, 5, 1, 14, 0, 0, 0, time.FixedZone("TRT", 3*60*60))
+
+	homeID := primitive.NewObjectID().Hex()
+	id, err := sur.CreateUser(ctx, model.User{Username: "nuke_lord", Email: "lord@nuky.io", Password: "hash", LastMoveDate: lastMove, HomeProvinceID: homeID})
+	require.NoError(t, err)
+	assert.False(t, id.IsZero())
+
+	user, err := sur.GetUserByUsername(ctx, "nuke_lord")
+	require.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "lord@nuky.io", user.Email)
+	assert.True(t, lastMove.Equal(user.LastMoveDate))
+	assert.Equal(t, homeID, user.HomeProvinceID)
+	assert.Empty(t, user.Player.ItemUses)
+
+	user.LastMoveDate = lastMove.Add(time.Hour)
+	user.Email = "lord@nuclick.one"
+	require.NoError(t, sur.PutUser(ctx, *user))
+
+	user, err = sur.GetUserByID(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, lastMove.Add(time.Hour).Equal(user.LastMoveDate))
+
+	state := model.PlayerState{Streak: 3, LastMoveRound: 12, ItemRound: 12, ItemUses: map[string]int{"shield": 1}, Supported: []string{homeID}}
+	require.NoError(t, sur.PutPlayerState(ctx, id, state))
+
+	user, err = sur.GetUserByEmail(ctx, "lord@nuclick.one")
+	require.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, state, user.Player)
+
+	_, err = sur.GetUserByID(ctx, primitive.NewObjectID())
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = sur.GetUserByEmail(ctx, "lord@nuky.io")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A move only starts from the last move date it was read with
+	now := time.Now()
+	started, err := sur.StartMove(ctx, id, lastMove.Add(time.Hour), now)
+	require.NoError(t, err)
+	assert.True(t, started)
+	started, err = sur.StartMove(ctx, id, lastMove.Add(time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, started)
+
+	user, err = sur.GetUserByID(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(user.LastMoveDate))
+	started, err = sur.StartMove(ctx, id, user.LastMoveDate, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	// Usernames are unique
+	_, err = sur.CreateUser(ctx, model.User{Username: "nuke_lord", Email: "other@nuky.io", Password: "hash"})
+	assert.Error(t, err)
+}
@@ -2,76 +2,139 @@ package service
 
 import (
 	// Standart
-	"encoding/json"
+	"context"
 	"errors"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	// Internal
 	"services/internal/auth/model"
-	"services/internal/auth/repo"
+	"services/internal/core/logging"
+	"services/internal/core/metrics"
+	"services/internal/core/response"
+	"services/internal/core/tracing"
+	"services/internal/core/validation"
 
 	// Third
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/kahlery/pkg/go/auth/token"
-	"github.com/kahlery/pkg/go/log/util"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// UserRepository is the storage the auth service needs, implemented by repo.UserRepo
+// (MongoDB) and repo.MemoryUserRepo. Implementations log their own failures with the
+// context they are given
+type UserRepository interface {
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (*model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	CreateUser(ctx context.Context, user model.User) (primitive.ObjectID, error)
+	StartMove(ctx context.Context, id primitive.ObjectID, last, now time.Time) (bool, error)
+}
+
+// ErrUserNotFound is returned for moves of a user that doesn't exist
+var ErrUserNotFound = errors.New("user not found")
+
+// MoveCooldown is how long a player waits between two moves
+const MoveCooldown = time.Hour
+
 type AuthService struct {
-	userRepo *repo.UserRepo
+	userRepo     UserRepository
+	loginLimiter *LoginLimiter
+	metrics      *metrics.Metrics
+	tracer       trace.Tracer
+
+	// Tells whether a home province exists, nil accepts any
+	homeProvinceExists func(ctx context.Context, provinceID string) (bool, error)
 }
 
-func NewAuthService(userRepo *repo.UserRepo) *AuthService {
+func NewAuthService(userRepo UserRepository) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
+		userRepo:     userRepo,
+		loginLimiter: NewLoginLimiter(DefaultAccountLoginPolicy, DefaultIPLoginPolicy, time.Now),
+		tracer:       tracing.Tracer(nil),
 	}
 }
 
+// SetLoginLimiter replaces the default login limiter, mainly for tests with a fake clock
+func (as *AuthService) SetLoginLimiter(loginLimiter *LoginLimiter) {
+	as.loginLimiter = loginLimiter
+}
+
+// SetHomeProvinceCheck makes registration reject home provinces exists reports as
+// missing, any well formed ID is accepted by default
+func (as *AuthService) SetHomeProvinceCheck(exists func(ctx context.Context, provinceID string) (bool, error)) {
+	as.homeProvinceExists = exists
+}
+
+// SetMetrics records cooldown rejections on m, nothing is recorded by default
+func (as *AuthService) SetMetrics(m *metrics.Metrics) {
+	as.metrics = m
+}
+
+// SetTracerProvider records spans on tp, nothing is recorded by default
+func (as *AuthService) SetTracerProvider(tp trace.TracerProvider) {
+	as.tracer = tracing.Tracer(tp)
+}
+
 // Services --------------------------------------------------------------------
 
 // RegisterHandler handles user registration
 func (as AuthService) Register(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	ctx, span := as.tracer.Start(r.Context(), "AuthService.Register")
+	defer span.End()
 
-	// Parse request body
+	// Parse and validate request body
 	var req model.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.LogError("Failed to decode request body: "+err.Error(), "AuthService.RegisterHandler", "")
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	// Validate request
-	if req.Username == "" || req.Email == "" || req.Password == "" {
-		http.Error(w, "Username, email, and password are required", http.StatusBadRequest)
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
 		return
 	}
 
 	// Check if user already exists by email
-	_, err := as.userRepo.GetUserByEmail(r.Context(), req.Email)
+	_, err := as.userRepo.GetUserByEmail(ctx, req.Email)
 	if err == nil {
-		http.Error(w, "Email already registered", http.StatusConflict)
+		response.Error(w, r, http.StatusConflict, response.CodeEmailTaken, "Email already registered")
 		return
 	}
 
 	// Check if username is taken
-	_, err = as.userRepo.GetUserByUsername(r.Context(), req.Username)
+	_, err = as.userRepo.GetUserByUsername(ctx, req.Username)
 	if err == nil {
-		http.Error(w, "Username already taken", http.StatusConflict)
+		response.Error(w, r, http.StatusConflict, response.CodeUsernameTaken, "Username already taken")
 		return
 	}
 
+	if req.HomeProvinceID != "" && as.homeProvinceExists != nil {
+		exists, err := as.homeProvinceExists(ctx, req.HomeProvinceID)
+		if err != nil {
+			tracing.Fail(span, err)
+			response.Internal(w, r, "Failed to check the home province")
+			return
+		}
+		if !exists {
+			validation.WriteError(w, r, validation.FieldErrors{{
+				Field:   "home_province_id",
+				Rule:    "exists",
+				Message: "must be an existing province",
+			}})
+			return
+		}
+	}
+
 	// Hash password
 	hashedPassword, err := token.HashPassword(req.Password)
 	if err != nil {
-		util.LogError("Failed to hash password: "+err.Error(), "AuthService.RegisterHandler", "")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "Failed to hash password", logging.Err(err))
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Internal server error")
 		return
 	}
 
@@ -81,89 +144,103 @@ func (as AuthService) Register(w http.ResponseWriter, r *http.Request) {
 		Email:        req.Email,
 		Password:     hashedPassword,
 		LastMoveDate: time.Now(),
+
+		HomeProvinceID: req.HomeProvinceID,
 	}
 
 	// Save user to database
-	id, err := as.userRepo.CreateUser(r.Context(), user)
+	id, err := as.userRepo.CreateUser(ctx, user)
 	if err != nil {
-		util.LogError("Failed to create user: "+err.Error(), "AuthService.RegisterHandler", "")
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to create user")
 		return
 	}
 
 	// Set ID for response
 	user.ID = id
 	user.Password = ""
+	ctx = logging.With(ctx, logging.UserID(id.Hex()))
+	span.SetAttributes(attribute.String(tracing.KeyUserID, id.Hex()))
+	slog.InfoContext(ctx, "User registered")
 
 	// Generate JWT token
 	jwtToken, err := token.GenerateToken(user.ID.Hex())
 	if err != nil {
-		util.LogError("Failed to generate token: "+err.Error(), "AuthService.RegisterHandler", "")
-		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "Failed to generate token", logging.Err(err))
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to generate authentication token")
 		return
 	}
 
 	// Send response
-	response := model.RegisterResponse{
+	response.JSON(w, http.StatusCreated, model.RegisterResponse{
 		Token: jwtToken,
 		User:  user,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	})
 }
 
 // --------------------------------------------------------------------
 
 // LoginHandler handles user login
 func (as AuthService) Login(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	ctx, span := as.tracer.Start(r.Context(), "AuthService.Login")
+	defer span.End()
+
+	// Parse and validate request body
+	var req model.LoginRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
 		return
 	}
 
-	// Parse request body
-	var req model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.LogError("Failed to decode request body: "+err.Error(), "AuthService.LoginHandler", "")
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	// Throttle by the identifier sent, before the store is asked, so throttled and locked
+	// out attempts cost no lookup
+	var account string
+	if req.Email != nil && *req.Email != "" {
+		account = "email:" + strings.ToLower(*req.Email)
+	} else if req.Username != nil && *req.Username != "" {
+		account = "username:" + *req.Username
+	} else {
+		response.Error(w, r, http.StatusBadRequest, response.CodeInvalidRequest, "Email/username and password are required")
 		return
 	}
 
-	// Validate request
-	if (req.Email == nil && req.Username == nil) || req.Password == "" {
-		http.Error(w, "Email/username and password are required", http.StatusBadRequest)
+	ip := clientIP(r)
+	if decision := as.loginLimiter.Check(account, ip); !decision.Allowed {
+		slog.WarnContext(ctx, "Login throttled", "account", account, "ip", ip, "locked", decision.Locked)
+		writeLoginThrottled(w, r, decision)
 		return
 	}
 
+	// Try to find user by email first, then by username
 	var user *model.User
 	var err error
-
-	// Try to find user by email first, then by username
 	if req.Email != nil && *req.Email != "" {
-		user, err = as.userRepo.GetUserByEmail(r.Context(), *req.Email)
-	} else if req.Username != nil && *req.Username != "" {
-		user, err = as.userRepo.GetUserByUsername(r.Context(), *req.Username)
+		user, err = as.userRepo.GetUserByEmail(ctx, *req.Email)
+	} else {
+		user, err = as.userRepo.GetUserByUsername(ctx, *req.Username)
 	}
 
+	// The attempt already counts as a failure, only a success takes it back
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		response.Error(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials, "Invalid email or password")
 		return
 	}
 
 	// Verify password
 	if err := token.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		response.Error(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials, "Invalid email or password")
 		return
 	}
 
+	as.loginLimiter.Succeed(account, ip)
+
 	// Generate JWT token
 	jwtToken, err := token.GenerateToken(user.ID.Hex())
 	if err != nil {
-		util.LogError("Failed to generate token: "+err.Error(), "AuthService.LoginHandler", "")
-		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		slog.ErrorContext(logging.With(ctx, logging.UserID(user.ID.Hex())), "Failed to generate token", logging.Err(err))
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to generate authentication token")
 		return
 	}
 
@@ -171,80 +248,147 @@ func (as AuthService) Login(w http.ResponseWriter, r *http.Request) {
 	user.Password = ""
 
 	// Send response
-	response := model.LoginResponse{
+	response.JSON(w, http.StatusOK, model.LoginResponse{
 		Token: jwtToken,
 		User:  *user,
+	})
+}
+
+// writeLoginThrottled answers a login attempt rejected by the limiter
+func writeLoginThrottled(w http.ResponseWriter, r *http.Request, decision LoginDecision) {
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	code, message := response.CodeLoginThrottled, "Too many failed login attempts, try again later"
+	if decision.Locked {
+		code, message = response.CodeAccountLocked, "Account temporarily locked after too many failed login attempts"
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	response.ErrorWithDetails(w, r, http.StatusTooManyRequests, code, message, model.LoginThrottledDetails{
+		Locked:            decision.Locked,
+		RetryAfterSeconds: retryAfter,
+	})
+}
+
+// clientIP returns the IP of the direct peer, proxy headers are ignored since they can be forged
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return host
 }
 
 // --------------------------------------------------------------------
 
 // POST /api/user/update-move-date
 func (as AuthService) UpdateMoveDate(w http.ResponseWriter, r *http.Request) {
+	ctx, span := as.tracer.Start(r.Context(), "AuthService.UpdateMoveDate")
+	defer span.End()
+
 	userID, err := ExtractUserIDFromRequest(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
+	ctx = logging.With(ctx, logging.UserID(userID))
+	span.SetAttributes(attribute.String(tracing.KeyUserID, userID))
+
 	objID, _ := primitive.ObjectIDFromHex(userID)
-	user, err := as.userRepo.GetUserByID(r.Context(), objID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	remaining, err := as.StartMove(ctx, objID)
+	if errors.Is(err, ErrUserNotFound) {
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "User not found")
 		return
 	}
-
-	user.LastMoveDate = time.Now()
-	err = as.userRepo.PutUser(r.Context(), *user)
 	if err != nil {
-		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to update move date")
+		return
+	}
+	if remaining > 0 {
+		WriteCooldownActive(w, r, remaining)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// POST /api/user/cooldown
-func (as AuthService) GetCooldownLeft(w http.ResponseWriter, r *http.Request) {
-	// Parse request body
-	var req model.CooldownLeftInSecondsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
+// StartMove starts the cooldown of a move of the user, or returns what is left of the
+// running one. The cooldown is enforced here too, not only by the client. Of two moves
+// started together only one moves the date on, the other one waits like a late one
+func (as AuthService) StartMove(ctx context.Context, userID primitive.ObjectID) (time.Duration, error) {
+	user, err := as.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, ErrUserNotFound
 	}
 
-	userID, err := ExtractUserIDFromTokenString(req.Token)
+	now := time.Now()
+	remaining := cooldownLeft(user, now)
+	if remaining == 0 {
+		started, err := as.userRepo.StartMove(ctx, userID, user.LastMoveDate, now)
+		if err != nil {
+			return 0, err
+		}
+		if !started {
+			remaining = MoveCooldown
+		}
+	}
+	if remaining > 0 {
+		as.metrics.CooldownRejected()
+		slog.InfoContext(ctx, "Move rejected by cooldown", "cooldown_left", remaining)
+	}
+
+	return remaining, nil
+}
+
+// GET /api/user/cooldown
+func (as AuthService) GetCooldownLeft(w http.ResponseWriter, r *http.Request) {
+	ctx, span := as.tracer.Start(r.Context(), "AuthService.GetCooldownLeft")
+	defer span.End()
+
+	userID, err := ExtractUserIDFromRequest(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
+	ctx = logging.With(ctx, logging.UserID(userID))
+	span.SetAttributes(attribute.String(tracing.KeyUserID, userID))
+
 	objID, _ := primitive.ObjectIDFromHex(userID)
-	user, err := as.userRepo.GetUserByID(r.Context(), objID)
+	user, err := as.userRepo.GetUserByID(ctx, objID)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "User not found")
 		return
 	}
 
-	// Calculate Cooldown
-	now := time.Now()
-	elapsed := now.Sub(user.LastMoveDate)
-	cooldownDuration := time.Hour // 3600s cooldown
+	response.JSON(w, http.StatusOK, model.CooldownLeftInSecondsResponse{
+		CooldownLeftInSeconds: int(cooldownLeft(user, time.Now()).Seconds()),
+	})
+}
 
-	remaining := cooldownDuration - elapsed
-	if remaining < 0 {
-		remaining = 0
-	}
+// WriteCooldownActive answers a move sent before the cooldown of its player was over
+func WriteCooldownActive(w http.ResponseWriter, r *http.Request, remaining time.Duration) {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	response.ErrorWithDetails(w, r, http.StatusTooManyRequests, response.CodeCooldownActive, "Cooldown is not over yet", model.CooldownLeftInSecondsResponse{
+		CooldownLeftInSeconds: seconds,
+	})
+}
 
-	resp := model.CooldownLeftInSecondsResponse{
-		CooldownLeftInSeconds: int(remaining.Seconds()),
+// cooldownLeft returns how long the user still has to wait before moving again
+func cooldownLeft(user *model.User, now time.Time) time.Duration {
+	remaining := MoveCooldown - now.Sub(user.LastMoveDate)
+	if remaining < 0 {
+		return 0
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return remaining
 }
 
 // --------------------------------------------------------------------
@@ -289,7 +433,8 @@ func ExtractUserIDFromTokenString(tokenString string) (string, error) {
 		return "", errors.New("invalid token claims")
 	}
 
-	userID, ok := claims["user_id"].(string)
+	// token.GenerateToken stores the user ID under the "userName" claim
+	userID, ok := claims["userName"].(string)
 	if !ok || userID == "" {
 		return "", errors.New("userID not found in token")
 	}
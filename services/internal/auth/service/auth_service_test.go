@@ -1 +1,211 @@
 package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kahlery/pkg/go/auth/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/auth/model"
+	"services/internal/auth/repo"
+	"services/internal/core/response"
+)
+
+// newTestAuthService serves a single player, zart with the password s3cretpass, and
+// throttles logins with the test limiter
+func newTestAuthService(t *testing.T) (*AuthService, *fakeClock) {
+	t.Helper()
+
+	hashedPassword, err := token.HashPassword("s3cretpass")
+	require.NoError(t, err)
+
+	as := NewAuthService(repo.NewMemoryUserRepo(model.User{
+		ID:       primitive.NewObjectID(),
+		Username: "zart",
+		Email:    "zart@nuclick.one",
+		Password: hashedPassword,
+	}))
+	limiter, clock := newTestLimiter()
+	as.SetLoginLimiter(limiter)
+
+	return as, clock
+}
+
+func loginAs(as *AuthService, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username": "`+username+`", "password": "`+password+`"}`))
+	req.RemoteAddr = "10.0.0.1:4242"
+
+	rr := httptest.NewRecorder()
+	as.Login(rr, req)
+	return rr
+}
+
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) response.ErrorBody {
+	t.Helper()
+
+	var body response.ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	return body.Error
+}
+
+func TestLogin_ThrottlesFailures(t *testing.T) {
+	as, clock := newTestAuthService(t)
+
+	for i := 0; i < 3; i++ {
+		rr := loginAs(as, "zart", "wrongpass")
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, response.CodeInvalidCredentials, decodeError(t, rr).Code)
+	}
+
+	// Even the right password waits out the backoff
+	rr := loginAs(as, "zart", "s3cretpass")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	body := decodeError(t, rr)
+	assert.Equal(t, response.CodeLoginThrottled, body.Code)
+	assert.Equal(t, map[string]any{"locked": false, "retry_after_seconds": float64(1)}, body.Details)
+
+	clock.Advance(time.Second)
+	require.Equal(t, http.StatusOK, loginAs(as, "zart", "s3cretpass").Code)
+
+	// The success reset the account, the free attempts are back
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, loginAs(as, "zart", "wrongpass").Code)
+	}
+}
+
+func TestLogin_LocksOut(t *testing.T) {
+	as, clock := newTestAuthService(t)
+
+	for i := 0; i < 6; i++ {
+		rr := loginAs(as, "zart", "wrongpass")
+		if rr.Code == http.StatusTooManyRequests {
+			clock.Advance(time.Duration(decodeError(t, rr).Details.(map[string]any)["retry_after_seconds"].(float64)) * time.Second)
+			rr = loginAs(as, "zart", "wrongpass")
+		}
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	rr := loginAs(as, "zart", "s3cretpass")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "600", rr.Header().Get("Retry-After"))
+	assert.Equal(t, response.CodeAccountLocked, decodeError(t, rr).Code)
+}
+
+func TestLogin_ParallelGuessesAreThrottled(t *testing.T) {
+	as, _ := newTestAuthService(t)
+
+	var mu sync.Mutex
+	codes := map[int]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := loginAs(as, "zart", "wrongpass")
+
+			mu.Lock()
+			codes[rr.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// The free attempts and the one after them are checked, the rest is turned away
+	assert.Equal(t, map[int]int{http.StatusUnauthorized: 3, http.StatusTooManyRequests: 17}, codes)
+}
+
+// countingUsers counts the lookups of the users it serves
+type countingUsers struct {
+	UserRepository
+	lookups atomic.Int32
+}
+
+func (cu *countingUsers) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	cu.lookups.Add(1)
+	return cu.UserRepository.GetUserByUsername(ctx, username)
+}
+
+func TestLogin_ThrottledAttemptsSkipTheStore(t *testing.T) {
+	as, _ := newTestAuthService(t)
+	users := &countingUsers{UserRepository: as.userRepo}
+	as.userRepo = users
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusUnauthorized, loginAs(as, "zart", "wrongpass").Code)
+	}
+	require.Equal(t, int32(3), users.lookups.Load())
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusTooManyRequests, loginAs(as, "zart", "wrongpass").Code)
+	}
+	assert.Equal(t, int32(3), users.lookups.Load())
+}
+
+func startMoveAs(as *AuthService, bearer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/update-move-date", nil)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+
+	rr := httptest.NewRecorder()
+	as.UpdateMoveDate(rr, req)
+	return rr
+}
+
+func TestUpdateMoveDate_EnforcesTheCooldown(t *testing.T) {
+	player := model.User{ID: primitive.NewObjectID(), Username: "zart", LastMoveDate: time.Now().Add(-2 * MoveCooldown)}
+	users := repo.NewMemoryUserRepo(player)
+	as := NewAuthService(users)
+	bearer, err := token.GenerateToken(player.ID.Hex())
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusNoContent, startMoveAs(as, bearer).Code)
+
+	rr := startMoveAs(as, bearer)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	body := decodeError(t, rr)
+	assert.Equal(t, response.CodeCooldownActive, body.Code)
+	seconds := body.Details.(map[string]any)["cooldown_left_in_seconds"].(float64)
+	assert.InDelta(t, MoveCooldown.Seconds(), seconds, 5)
+	assert.Equal(t, strconv.Itoa(int(seconds)), rr.Header().Get("Retry-After"))
+
+	// The rejected move didn't start a new cooldown
+	saved, err := users.GetUserByID(context.Background(), player.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), saved.LastMoveDate, 5*time.Second)
+}
+
+func TestUpdateMoveDate_ParallelStartsMoveOnce(t *testing.T) {
+	player := model.User{ID: primitive.NewObjectID(), Username: "zart", LastMoveDate: time.Now().Add(-2 * MoveCooldown)}
+	as := NewAuthService(repo.NewMemoryUserRepo(player))
+	bearer, err := token.GenerateToken(player.ID.Hex())
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	codes := map[int]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := startMoveAs(as, bearer)
+
+			mu.Lock()
+			codes[rr.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[int]int{http.StatusNoContent: 1, http.StatusTooManyRequests: 9}, codes)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginPolicy describes how failed login attempts are throttled for a single key
+// (an account or a client IP).
+type LoginPolicy struct {
+	FreeAttempts     int           // failures allowed before backoff kicks in
+	BaseDelay        time.Duration // first backoff delay, doubled on every further failure
+	MaxDelay         time.Duration // upper bound for the backoff delay
+	LockoutThreshold int           // failures that lock the key out completely
+	LockoutDuration  time.Duration // how long a lockout lasts
+	Window           time.Duration // failures older than this are forgotten
+}
+
+// DefaultAccountLoginPolicy is applied per account (the email or username logged in with)
+var DefaultAccountLoginPolicy = LoginPolicy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         30 * time.Second,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	Window:           15 * time.Minute,
+}
+
+// DefaultIPLoginPolicy is applied per client IP, it is looser than the account
+// policy since many players may share one address
+var DefaultIPLoginPolicy = LoginPolicy{
+	FreeAttempts:     10,
+	BaseDelay:        time.Second,
+	MaxDelay:         30 * time.Second,
+	LockoutThreshold: 50,
+	LockoutDuration:  15 * time.Minute,
+	Window:           15 * time.Minute,
+}
+
+// LoginDecision is the result of checking a login attempt against the limiter
+type LoginDecision struct {
+	Allowed    bool
+	Locked     bool          // true if the attempt is blocked by a lockout, not just a backoff
+	RetryAfter time.Duration // how long the caller has to wait before trying again
+}
+
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+}
+
+// maxTrackedLoginKeys triggers a sweep of stale entries so the maps can't grow forever
+const maxTrackedLoginKeys = 10000
+
+// LoginLimiter tracks failed login attempts per account and per client IP and
+// applies exponential backoff followed by a temporary lockout.
+type LoginLimiter struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	account  LoginPolicy
+	ip       LoginPolicy
+	accounts map[string]*loginFailures
+	ips      map[string]*loginFailures
+}
+
+// NewLoginLimiter creates a limiter, now is the clock used for every decision (time.Now if nil)
+func NewLoginLimiter(account, ip LoginPolicy, now func() time.Time) *LoginLimiter {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &LoginLimiter{
+		now:      now,
+		account:  account,
+		ip:       ip,
+		accounts: make(map[string]*loginFailures),
+		ips:      make(map[string]*loginFailures),
+	}
+}
+
+// Check tells whether a login attempt for the given account and IP may proceed. An
+// allowed attempt is counted as a failure right away, so parallel guesses can't all pass
+// before the first one fails, Succeed takes it back
+func (ll *LoginLimiter) Check(account, ip string) LoginDecision {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	now := ll.now()
+	accountDecision := decide(ll.accounts, account, ll.account, now)
+	ipDecision := decide(ll.ips, ip, ll.ip, now)
+
+	// The stricter of both decisions wins
+	if !accountDecision.Allowed && (ipDecision.Allowed || accountDecision.RetryAfter >= ipDecision.RetryAfter) {
+		return accountDecision
+	}
+	if !ipDecision.Allowed {
+		return ipDecision
+	}
+
+	record(ll.accounts, account, ll.account, now)
+	record(ll.ips, ip, ll.ip, now)
+
+	if len(ll.accounts)+len(ll.ips) > maxTrackedLoginKeys {
+		sweep(ll.accounts, ll.account, now)
+		sweep(ll.ips, ll.ip, now)
+	}
+
+	return LoginDecision{Allowed: true}
+}
+
+// Succeed resets the failure counter of the account and takes back the attempt Check
+// counted for the IP. The rest of the IP counter is kept on purpose, otherwise a single
+// valid account would let an attacker reset it at will.
+func (ll *LoginLimiter) Succeed(account, ip string) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	delete(ll.accounts, account)
+	if entry, ok := ll.ips[ip]; ok {
+		if entry.count--; entry.count <= 0 {
+			delete(ll.ips, ip)
+		}
+	}
+}
+
+// --------------------------------------------------------------------
+
+func decide(entries map[string]*loginFailures, key string, policy LoginPolicy, now time.Time) LoginDecision {
+	entry, ok := entries[key]
+	if !ok || key == "" {
+		return LoginDecision{Allowed: true}
+	}
+
+	if expired(entry, policy, now) {
+		delete(entries, key)
+		return LoginDecision{Allowed: true}
+	}
+
+	if policy.LockoutThreshold > 0 && entry.count >= policy.LockoutThreshold {
+		return LoginDecision{
+			Locked:     true,
+			RetryAfter: entry.lastFailure.Add(policy.LockoutDuration).Sub(now),
+		}
+	}
+
+	if entry.count <= policy.FreeAttempts {
+		return LoginDecision{Allowed: true}
+	}
+
+	retryAt := entry.lastFailure.Add(backoff(entry.count-policy.FreeAttempts, policy))
+	if now.Before(retryAt) {
+		return LoginDecision{RetryAfter: retryAt.Sub(now)}
+	}
+
+	return LoginDecision{Allowed: true}
+}
+
+func record(entries map[string]*loginFailures, key string, policy LoginPolicy, now time.Time) {
+	if key == "" {
+		return
+	}
+
+	entry, ok := entries[key]
+	if !ok || expired(entry, policy, now) {
+		entry = &loginFailures{}
+		entries[key] = entry
+	}
+
+	entry.count++
+	entry.lastFailure = now
+}
+
+func sweep(entries map[string]*loginFailures, policy LoginPolicy, now time.Time) {
+	for key, entry := range entries {
+		if expired(entry, policy, now) {
+			delete(entries, key)
+		}
+	}
+}
+
+// expired reports whether the failures of an entry can be forgotten, either because
+// the window passed or because the lockout it caused is over
+func expired(entry *loginFailures, policy LoginPolicy, now time.Time) bool {
+	if policy.LockoutThreshold > 0 && entry.count >= policy.LockoutThreshold {
+		return !now.Before(entry.lastFailure.Add(policy.LockoutDuration))
+	}
+
+	return policy.Window > 0 && now.Sub(entry.lastFailure) > policy.Window
+}
+
+// backoff returns BaseDelay * 2^(n-1), capped at MaxDelay
+func backoff(n int, policy LoginPolicy) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < n; i++ {
+		delay *= 2
+		if policy.MaxDelay > 0 && delay >= policy.MaxDelay {
+			return policy.MaxDelay
+		}
+	}
+
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock for the limiter
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter() (*LoginLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)}
+	policy := LoginPolicy{
+		FreeAttempts:     2,
+		BaseDelay:        time.Second,
+		MaxDelay:         4 * time.Second,
+		LockoutThreshold: 6,
+		LockoutDuration:  10 * time.Minute,
+		Window:           5 * time.Minute,
+	}
+
+	return NewLoginLimiter(policy, DefaultIPLoginPolicy, clock.Now), clock
+}
+
+// attempt makes a login attempt that fails, waiting out any backoff before it
+func attempt(t *testing.T, limiter *LoginLimiter, clock *fakeClock, account, ip string) {
+	t.Helper()
+
+	decision := limiter.Check(account, ip)
+	if !decision.Allowed && !decision.Locked {
+		clock.Advance(decision.RetryAfter)
+		decision = limiter.Check(account, ip)
+	}
+	require.True(t, decision.Allowed)
+}
+
+func TestLoginLimiter_FreeAttempts(t *testing.T) {
+	limiter, clock := newTestLimiter()
+
+	attempt(t, limiter, clock, "user:1", "10.0.0.1")
+	attempt(t, limiter, clock, "user:1", "10.0.0.1")
+
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+}
+
+func TestLoginLimiter_ExponentialBackoff(t *testing.T) {
+	limiter, clock := newTestLimiter()
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+	}
+
+	decision := limiter.Check("user:1", "10.0.0.1")
+	assert.False(t, decision.Allowed)
+	assert.False(t, decision.Locked)
+	assert.Equal(t, time.Second, decision.RetryAfter)
+
+	clock.Advance(time.Second)
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+	assert.Equal(t, 2*time.Second, limiter.Check("user:1", "10.0.0.1").RetryAfter)
+
+	clock.Advance(2 * time.Second)
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+	assert.Equal(t, 4*time.Second, limiter.Check("user:1", "10.0.0.1").RetryAfter)
+
+	// Other accounts are not affected
+	assert.True(t, limiter.Check("user:2", "10.0.0.2").Allowed)
+}
+
+func TestLoginLimiter_Lockout(t *testing.T) {
+	limiter, clock := newTestLimiter()
+
+	for i := 0; i < 6; i++ {
+		attempt(t, limiter, clock, "user:1", "10.0.0.1")
+	}
+
+	decision := limiter.Check("user:1", "10.0.0.1")
+	assert.False(t, decision.Allowed)
+	assert.True(t, decision.Locked)
+	assert.Equal(t, 10*time.Minute, decision.RetryAfter)
+
+	// The lockout outlives the failure window
+	clock.Advance(9 * time.Minute)
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Locked)
+
+	clock.Advance(time.Minute)
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+}
+
+func TestLoginLimiter_SucceedResetsAccount(t *testing.T) {
+	limiter, clock := newTestLimiter()
+
+	for i := 0; i < 3; i++ {
+		attempt(t, limiter, clock, "user:1", "10.0.0.1")
+	}
+	assert.False(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+
+	limiter.Succeed("user:1", "10.0.0.1")
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+}
+
+func TestLoginLimiter_SucceedTakesBackTheIPAttempt(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	// Many players logging in successfully from one address never trip the IP limit
+	for i := 0; i < DefaultIPLoginPolicy.LockoutThreshold+1; i++ {
+		account := "user:" + strconv.Itoa(i)
+		require.True(t, limiter.Check(account, "10.0.0.1").Allowed)
+		limiter.Succeed(account, "10.0.0.1")
+	}
+
+	assert.True(t, limiter.Check("user:fresh", "10.0.0.1").Allowed)
+}
+
+func TestLoginLimiter_WindowExpires(t *testing.T) {
+	limiter, clock := newTestLimiter()
+
+	for i := 0; i < 3; i++ {
+		attempt(t, limiter, clock, "user:1", "10.0.0.1")
+	}
+
+	clock.Advance(6 * time.Minute)
+	attempt(t, limiter, clock, "user:1", "10.0.0.1")
+
+	// Old failures were forgotten, so this is the first one again
+	assert.True(t, limiter.Check("user:1", "10.0.0.1").Allowed)
+}
+
+func TestLoginLimiter_PerIP(t *testing.T) {
+	limiter, clock := newTestLimiter()
+
+	// Spraying many accounts from one IP trips the IP limit
+	for i := 0; i < DefaultIPLoginPolicy.LockoutThreshold; i++ {
+		attempt(t, limiter, clock, "user:"+strconv.Itoa(i), "10.0.0.1")
+	}
+
+	decision := limiter.Check("user:fresh", "10.0.0.1")
+	assert.False(t, decision.Allowed)
+	assert.True(t, decision.Locked)
+
+	assert.True(t, limiter.Check("user:fresh", "10.0.0.2").Allowed)
+}
+
+func TestLoginLimiter_ParallelAttemptsAreCounted(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.Check("user:1", "10.0.0.1").Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only the free attempts and the one after them get through, the backoff holds off the rest
+	assert.Equal(t, int32(3), allowed.Load())
+}
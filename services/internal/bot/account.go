@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	auth_model "services/internal/auth/model"
+	auth_repo "services/internal/auth/repo"
+	"services/internal/core/response"
+
+	"github.com/kahlery/pkg/go/auth/token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Account is the player a bot plays as
+type Account struct {
+	UserID string
+	Token  string
+	Home   string // home province in the public game, empty if the account has none
+}
+
+// Accounts signs bots up with home as their home province, or back in under the
+// username they played with before, keeping the home they were given then
+type Accounts interface {
+	Enlist(ctx context.Context, username, home string) (Account, error)
+}
+
+// UserRepository is the user storage StoreAccounts keeps bots in, implemented by the
+// auth repos
+type UserRepository interface {
+	GetUserByUsername(ctx context.Context, username string) (*auth_model.User, error)
+	CreateUser(ctx context.Context, user auth_model.User) (primitive.ObjectID, error)
+}
+
+// shortNames keep the usernames of bots within 20 characters
+var shortNames = map[string]string{
+	StrategyRandom:       "rnd",
+	StrategyDefendHome:   "def",
+	StrategyPileOnLeader: "pile",
+}
+
+// Username is the username of the nth bot of a strategy, like bot_pile_3 for the prefix bot
+func Username(prefix, strategy string, n int) string {
+	return fmt.Sprintf("%s_%s_%d", prefix, shortNames[strategy], n)
+}
+
+// Email is the address bots sign up with, on a domain that can't receive mail
+func Email(username string) string {
+	return username + "@bots.invalid"
+}
+
+// StoreAccounts keeps the accounts of bots next to the players' ones, for bots playing in
+// the API itself. Nobody knows their passwords, bots only play with the tokens they get
+type StoreAccounts struct {
+	users UserRepository
+}
+
+// NewStoreAccounts keeps the accounts of bots in users
+func NewStoreAccounts(users UserRepository) *StoreAccounts {
+	return &StoreAccounts{
+		users: users,
+	}
+}
+
+// Enlist returns the account of a bot, created the way a player signing up would be. A
+// player who took the username first keeps it, the bot isn't enlisted
+func (sa *StoreAccounts) Enlist(ctx context.Context, username, home string) (Account, error) {
+	user, err := sa.users.GetUserByUsername(ctx, username)
+	if errors.Is(err, auth_repo.ErrNotFound) {
+		user, err = sa.create(ctx, username, home)
+	}
+	if err != nil {
+		return Account{}, err
+	}
+	if user.Email != Email(username) {
+		return Account{}, fmt.Errorf("username %s is taken by a player", username)
+	}
+
+	jwtToken, err := token.GenerateToken(user.ID.Hex())
+	if err != nil {
+		return Account{}, err
+	}
+
+	return Account{UserID: user.ID.Hex(), Token: jwtToken, Home: user.HomeProvinceID}, nil
+}
+
+func (sa *StoreAccounts) create(ctx context.Context, username, home string) (*auth_model.User, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := token.HashPassword(hex.EncodeToString(password))
+	if err != nil {
+		return nil, err
+	}
+
+	// Like every new player, a bot waits out a cooldown before its first move
+	user := auth_model.User{
+		Username:       username,
+		Email:          Email(username),
+		Password:       hashedPassword,
+		LastMoveDate:   time.Now(),
+		HomeProvinceID: home,
+	}
+	if user.ID, err = sa.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// APIAccounts signs bots up through the API with a shared password, for bots playing a
+// deployment from the outside
+type APIAccounts struct {
+	client   *Client
+	password string
+}
+
+// NewAPIAccounts signs bots up with client, password must pass the API's password rules
+func NewAPIAccounts(client *Client, password string) *APIAccounts {
+	return &APIAccounts{
+		client:   client,
+		password: password,
+	}
+}
+
+// Enlist registers a bot, or logs it in when its username is taken by an earlier run
+func (aa *APIAccounts) Enlist(ctx context.Context, username, home string) (Account, error) {
+	account, err := aa.client.Register(ctx, auth_model.RegisterRequest{
+		Username:       username,
+		Email:          Email(username),
+		Password:       aa.password,
+		HomeProvinceID: home,
+	})
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.Code == response.CodeUsernameTaken || apiErr.Code == response.CodeEmailTaken) {
+		return aa.client.Login(ctx, username, aa.password)
+	}
+
+	return account, err
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"services/internal/core/response"
+	province_model "services/internal/province/model"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// retryDelay is how long a bot waits after a move it couldn't start
+const retryDelay = time.Minute
+
+// idleDelay is how long a bot with nothing left to play waits before looking again
+const idleDelay = 15 * time.Minute
+
+// tokenMargin is how long before its token expires a bot signs back in, a move must not
+// outlast it
+const tokenMargin = time.Minute
+
+// ErrGameOver is returned for a move in a lobby that is over, the bot has nothing left to play
+var ErrGameOver = errors.New("the game is over")
+
+// Bot is a bot player of a single game
+type Bot struct {
+	Name     string
+	Account  Account
+	Game     string // path prefix of the game's routes, PublicGame or LobbyGame
+	Strategy string // one of the Strategy* strategies
+
+	strategy Strategy
+	accounts Accounts // signs the bot back in once its token expired, nil if it can't
+	home     string   // the account's home, or one adopted once it is lost
+	rng      *rand.Rand
+}
+
+// NewBot creates a bot playing game as account by the strategy called strategy
+func NewBot(name string, account Account, game, strategy string) (*Bot, error) {
+	s, err := NewStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bot{
+		Name:     name,
+		Account:  account,
+		Game:     game,
+		Strategy: strategy,
+		strategy: s,
+		home:     account.Home,
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}, nil
+}
+
+// Move plays a single move through client and tells how long to wait before the next
+// one, what is left of the cooldown the server holds the bot to. A bot without a living
+// home adopts a random living province first. In the public game it only attacks the
+// provinces next to the home of its account, the ones it may attack on any rules
+func (b *Bot) Move(ctx context.Context, client *Client) (time.Duration, error) {
+	// Tokens expire long before a cooldown is over, the bot signs back in for a new one
+	if b.accounts != nil && expiresSoon(b.Account.Token, time.Now()) {
+		if err := b.signIn(ctx); err != nil {
+			return retryDelay, err
+		}
+	}
+
+	view, err := client.Board(ctx, b.Account.Token, b.Game)
+	if err != nil {
+		return retryDelay, err
+	}
+
+	if !slices.ContainsFunc(view.Provinces, func(p province_model.Province) bool { return p.ID.Hex() == b.home }) {
+		b.home = ""
+		if len(view.Provinces) > 0 {
+			b.home = view.Provinces[b.rng.IntN(len(view.Provinces))].ID.Hex()
+		}
+	}
+	view.Home = b.home
+	if b.Game == PublicGame {
+		view.Reach = reach(view.Provinces, b.Account.Home)
+	}
+
+	move, ok := b.strategy.Pick(view, b.rng)
+	if !ok {
+		return idleDelay, nil
+	}
+
+	// A lobby move starts its cooldown itself, a public one is started first
+	if b.Game == PublicGame {
+		if err := client.StartMove(ctx, b.Account.Token); err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Code == response.CodeCooldownActive {
+				return apiErr.RetryAfter, nil
+			}
+			return retryDelay, err
+		}
+	}
+
+	// A rejected public move waits out the cooldown it started like a played one, a
+	// rejected lobby move took no cooldown and is tried again after retryDelay
+	playErr := client.Play(ctx, b.Account.Token, b.Game, move)
+	var apiErr *APIError
+	if errors.As(playErr, &apiErr) {
+		switch apiErr.Code {
+		case response.CodeLobbyFinished:
+			return 0, ErrGameOver
+		case response.CodeCooldownActive:
+			return apiErr.RetryAfter, nil
+		}
+	}
+
+	left, err := client.CooldownLeft(ctx, b.Account.Token, b.Game)
+	if err != nil {
+		return retryDelay, errors.Join(playErr, err)
+	}
+	if playErr != nil && left == 0 {
+		left = retryDelay
+	}
+
+	return left, playErr
+}
+
+// reach lists the living provinces next to home, the ones a game allowing only adjacent
+// attacks lets a player with that home attack. It is nil on a map without neighbors, any
+// province can be attacked there
+func reach(provinces []province_model.Province, home string) []string {
+	if !slices.ContainsFunc(provinces, func(p province_model.Province) bool { return len(p.Neighbors) > 0 }) {
+		return nil
+	}
+
+	reachable := []string{}
+	for _, p := range provinces {
+		if home != "" && slices.Contains(p.Neighbors, home) {
+			reachable = append(reachable, p.ID.Hex())
+		}
+	}
+
+	return reachable
+}
+
+// signIn replaces the account of b by a fresh one, with a new token
+func (b *Bot) signIn(ctx context.Context) error {
+	account, err := b.accounts.Enlist(ctx, b.Name, b.Account.Home)
+	if err != nil {
+		return err
+	}
+
+	b.Account = account
+	return nil
+}
+
+// expiresSoon tells whether token expires within tokenMargin from now. A token that can't
+// be read is renewed too
+func expiresSoon(token string, now time.Time) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return true
+	}
+
+	return !claims.VerifyExpiresAt(now.Add(tokenMargin).Unix(), true)
+}
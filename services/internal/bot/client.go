@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	auth_model "services/internal/auth/model"
+	"services/internal/core/response"
+	"services/internal/game"
+	lobby_model "services/internal/lobby/model"
+	province_model "services/internal/province/model"
+)
+
+// PublicGame is the path prefix of the public game's routes
+const PublicGame = "/api"
+
+// LobbyGame returns the path prefix of the routes of a lobby's game
+func LobbyGame(lobbyID string) string {
+	return "/api/lobbies/" + lobbyID
+}
+
+// APIError is a request the API answered with an error
+type APIError struct {
+	Status     int
+	Code       string // one of the response.Code* codes
+	Message    string
+	RetryAfter time.Duration // from the Retry-After header, 0 if there was none
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Client sends the requests of bots to the API
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient sends requests to the API at baseURL, like https://api.nuclick.one
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    httpClient,
+	}
+}
+
+// NewHandlerClient serves requests in-process with h, the full handler of the API, so
+// they take the same path as the ones coming from the network
+func NewHandlerClient(h http.Handler) *Client {
+	return NewClient("http://bots.internal", &http.Client{Transport: handlerTransport{h}})
+}
+
+// handlerTransport answers requests with a handler instead of sending them
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (ht handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	served := req.Clone(req.Context())
+	served.RemoteAddr = "127.0.0.1:0"
+	served.RequestURI = req.URL.RequestURI()
+	if served.Body == nil {
+		served.Body = http.NoBody
+	}
+
+	recorder := httptest.NewRecorder()
+	ht.handler.ServeHTTP(recorder, served)
+	return recorder.Result(), nil
+}
+
+// Board reads the living provinces of a game and its standings
+func (c *Client) Board(ctx context.Context, token, gamePath string) (View, error) {
+	var provinces province_model.GetAllProvinceResponse
+	if err := c.do(ctx, http.MethodGet, gamePath+"/province", token, nil, &provinces); err != nil {
+		return View{}, err
+	}
+
+	var top province_model.GetTopProvincesResponse
+	if err := c.do(ctx, http.MethodGet, gamePath+"/province/top", token, nil, &top); err != nil {
+		return View{}, err
+	}
+
+	view := View{Top: top.Provinces}
+	for _, p := range provinces.ProvinceList {
+		if p.DestroymentRound == -1 {
+			view.Provinces = append(view.Provinces, p)
+		}
+	}
+
+	return view, nil
+}
+
+// StartMove starts the cooldown of a move, an APIError with response.CodeCooldownActive
+// while the last one isn't over
+func (c *Client) StartMove(ctx context.Context, token string) error {
+	return c.do(ctx, http.MethodPost, "/api/user/update-move-date", token, nil, nil)
+}
+
+// CooldownLeft reads how long the player has to wait before its next move in a game
+func (c *Client) CooldownLeft(ctx context.Context, token, gamePath string) (time.Duration, error) {
+	var cooldown auth_model.CooldownLeftInSecondsResponse
+	if err := c.do(ctx, http.MethodGet, gamePath+"/user/cooldown", token, nil, &cooldown); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(cooldown.CooldownLeftInSeconds) * time.Second, nil
+}
+
+// Play attacks or supports a province of a game
+func (c *Client) Play(ctx context.Context, token, gamePath string, move Move) error {
+	if move.Kind == game.MoveAttack {
+		return c.do(ctx, http.MethodPost, gamePath+"/province/attack", token, province_model.AttackProvinceRequest{ProvinceID: move.ProvinceID}, nil)
+	}
+
+	return c.do(ctx, http.MethodPost, gamePath+"/province/support", token, province_model.SupportProvinceRequest{ProvinceID: move.ProvinceID}, nil)
+}
+
+// Register signs a new player up
+func (c *Client) Register(ctx context.Context, req auth_model.RegisterRequest) (Account, error) {
+	var registered auth_model.RegisterResponse
+	if err := c.do(ctx, http.MethodPost, "/api/auth/register", "", req, &registered); err != nil {
+		return Account{}, err
+	}
+
+	return Account{UserID: registered.User.ID.Hex(), Token: registered.Token, Home: registered.User.HomeProvinceID}, nil
+}
+
+// Login logs a player in by username
+func (c *Client) Login(ctx context.Context, username, password string) (Account, error) {
+	var loggedIn auth_model.LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/auth/login", "", auth_model.LoginRequest{Username: &username, Password: password}, &loggedIn); err != nil {
+		return Account{}, err
+	}
+
+	return Account{UserID: loggedIn.User.ID.Hex(), Token: loggedIn.Token, Home: loggedIn.User.HomeProvinceID}, nil
+}
+
+// JoinLobby joins the lobby an invite code joins and returns its ID
+func (c *Client) JoinLobby(ctx context.Context, token, inviteCode string) (string, error) {
+	var joined lobby_model.LobbyResponse
+	if err := c.do(ctx, http.MethodPost, "/api/lobbies/join", token, lobby_model.JoinLobbyRequest{InviteCode: inviteCode}, &joined); err != nil {
+		return "", err
+	}
+
+	return joined.Lobby.ID.Hex(), nil
+}
+
+// do sends a request with body as JSON and decodes a successful answer into out, nil
+// body and out send and read nothing
+func (c *Client) do(ctx context.Context, method, path, token string, body, out any) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &payload)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var failed response.ErrorResponse
+		json.NewDecoder(res.Body).Decode(&failed)
+
+		apiErr := &APIError{Status: res.StatusCode, Code: failed.Error.Code, Message: failed.Error.Message}
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"services/internal/core/logging"
+)
+
+// firstMoveSpread spreads the first moves of bots deployed together
+const firstMoveSpread = 30 * time.Second
+
+// Squad is a number of bots playing by the same strategy
+type Squad struct {
+	Strategy string
+	Count    int
+}
+
+// ParseSquads reads squads written like random:3,pile-on-leader:1, an empty spec has none
+func ParseSquads(spec string) ([]Squad, error) {
+	var squads []Squad
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		strategy, count, _ := strings.Cut(item, ":")
+		if _, err := NewStrategy(strategy); err != nil {
+			return nil, err
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("bots of %s must be counted like %s:3, got %q", strategy, strategy, item)
+		}
+
+		squads = append(squads, Squad{Strategy: strategy, Count: n})
+	}
+
+	return squads, nil
+}
+
+// FormatSquads writes squads the way ParseSquads reads them
+func FormatSquads(squads []Squad) string {
+	items := make([]string, len(squads))
+	for i, squad := range squads {
+		items[i] = squad.Strategy + ":" + strconv.Itoa(squad.Count)
+	}
+	return strings.Join(items, ",")
+}
+
+// Fleet runs bots, each on its own schedule, as long as it runs. Bots play through client
+// and are signed up with accounts
+type Fleet struct {
+	client   *Client
+	accounts Accounts
+
+	mu      sync.Mutex
+	ctx     context.Context // set while Run runs
+	pending []*Bot          // deployed before Run
+	stopped bool            // set once Run's ctx is done, no bot is started anymore
+	wg      sync.WaitGroup
+}
+
+// NewFleet creates a fleet playing through client, with bots signed up with accounts
+func NewFleet(client *Client, accounts Accounts) *Fleet {
+	return &Fleet{
+		client:   client,
+		accounts: accounts,
+	}
+}
+
+// Enlist signs up a bot to play game by strategy, or back in under a username it played
+// with before. It doesn't play before it is deployed
+func (f *Fleet) Enlist(ctx context.Context, game, username, strategy string) (*Bot, error) {
+	if _, err := NewStrategy(strategy); err != nil {
+		return nil, err
+	}
+
+	// A bot of the public game calls a random living province home, the one its attacks
+	// start from when only adjacent provinces can be attacked
+	var home string
+	if game == PublicGame {
+		view, err := f.client.Board(ctx, "", PublicGame)
+		if err != nil {
+			return nil, err
+		}
+		if len(view.Provinces) > 0 {
+			home = view.Provinces[rand.IntN(len(view.Provinces))].ID.Hex()
+		}
+	}
+
+	account, err := f.accounts.Enlist(ctx, username, home)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := NewBot(username, account, game, strategy)
+	if err != nil {
+		return nil, err
+	}
+	b.accounts = f.accounts
+
+	return b, nil
+}
+
+// Deploy starts bots playing, right away while the fleet runs, otherwise once it does
+func (f *Fleet) Deploy(bots ...*Bot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ctx == nil {
+		f.pending = append(f.pending, bots...)
+		return
+	}
+	for _, b := range bots {
+		f.start(b)
+	}
+}
+
+// Run plays the deployed bots until ctx is done, then waits for the moves in flight
+func (f *Fleet) Run(ctx context.Context) {
+	f.mu.Lock()
+	f.ctx = ctx
+	for _, b := range f.pending {
+		f.start(b)
+	}
+	f.pending = nil
+	f.mu.Unlock()
+
+	<-ctx.Done()
+
+	// Bots deployed from now on are dropped, none is started while waiting
+	f.mu.Lock()
+	f.stopped = true
+	f.mu.Unlock()
+	f.wg.Wait()
+}
+
+// start plays b in a goroutine of its own unless the fleet stopped, f.mu must be held
+func (f *Fleet) start(b *Bot) {
+	if f.stopped {
+		return
+	}
+
+	ctx := f.ctx
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.play(ctx, b)
+	}()
+}
+
+// play moves b whenever it may until ctx is done. Each wait is stretched by up to a tenth
+// so bots deployed together drift apart
+func (f *Fleet) play(ctx context.Context, b *Bot) {
+	ctx = logging.With(ctx, logging.UserID(b.Account.UserID))
+	slog.DebugContext(ctx, "Bot deployed", "bot", b.Name, "strategy", b.Strategy, "game", b.Game)
+
+	wait := time.Duration(b.rng.Int64N(int64(firstMoveSpread)))
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		next, err := b.Move(ctx, f.client)
+		if errors.Is(err, ErrGameOver) {
+			slog.InfoContext(ctx, "Bot retired, its game is over", "bot", b.Name, "game", b.Game)
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Bot move failed", "bot", b.Name, "game", b.Game, logging.Err(err))
+		}
+
+		wait = next + time.Duration(b.rng.Int64N(int64(next/10)+1))
+	}
+}
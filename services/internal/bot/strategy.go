@@ -0,0 +1,124 @@
+// Package bot plays the game with server-side bot players, for sparse lobbies, local runs
+// and load tests.
+//
+// A bot plays over HTTP like the web client does. It reads the board, starts its move
+// with POST /api/user/update-move-date, which holds it to the same cooldown as anyone,
+// then attacks or supports a province. In a lobby the move starts the lobby's own
+// cooldown itself. In the API the requests go in-process through the full handler,
+// cmd/bots sends them to a running deployment.
+package bot
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"services/internal/game"
+	"services/internal/province/model"
+)
+
+// Strategies a bot can play by
+const (
+	StrategyRandom       = "random"         // attacks or supports any living province in its reach
+	StrategyDefendHome   = "defend-home"    // supports its home province
+	StrategyPileOnLeader = "pile-on-leader" // attacks the best ranked province in its reach
+)
+
+// Strategies lists every strategy, in the order they are documented
+var Strategies = []string{StrategyRandom, StrategyDefendHome, StrategyPileOnLeader}
+
+// View is what a bot sees of its game before a move
+type View struct {
+	Provinces []model.Province       // living provinces
+	Top       []model.RankedProvince // top ranked living provinces, the nuke's first pick first
+	Home      string                 // ID of the living province the bot calls home, empty once none is left
+	Reach     []string               // IDs of the provinces the bot may attack, nil if it may attack any
+}
+
+// attackable filters the provinces the bot may attack out of provinces
+func (v View) attackable(provinces []model.Province) []model.Province {
+	if v.Reach == nil {
+		return provinces
+	}
+
+	var reachable []model.Province
+	for _, p := range provinces {
+		if slices.Contains(v.Reach, p.ID.Hex()) {
+			reachable = append(reachable, p)
+		}
+	}
+	return reachable
+}
+
+// Move is what a strategy plays next
+type Move struct {
+	Kind       string // game.MoveAttack or game.MoveSupport
+	ProvinceID string
+}
+
+// Strategy picks the next move of a bot from what it sees, false when there is nothing
+// left to play
+type Strategy interface {
+	Pick(view View, rng *rand.Rand) (Move, bool)
+}
+
+// NewStrategy returns the strategy called name
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case StrategyRandom:
+		return randomStrategy{}, nil
+	case StrategyDefendHome:
+		return defendHomeStrategy{}, nil
+	case StrategyPileOnLeader:
+		return pileOnLeaderStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown bot strategy %q, want one of %s", name, strings.Join(Strategies, ", "))
+	}
+}
+
+type randomStrategy struct{}
+
+// randomStrategy supports when no province is in its reach
+func (randomStrategy) Pick(view View, rng *rand.Rand) (Move, bool) {
+	if len(view.Provinces) == 0 {
+		return Move{}, false
+	}
+
+	kind, targets := game.MoveAttack, view.attackable(view.Provinces)
+	if rng.IntN(2) == 0 || len(targets) == 0 {
+		kind, targets = game.MoveSupport, view.Provinces
+	}
+
+	return Move{Kind: kind, ProvinceID: targets[rng.IntN(len(targets))].ID.Hex()}, true
+}
+
+type defendHomeStrategy struct{}
+
+func (defendHomeStrategy) Pick(view View, rng *rand.Rand) (Move, bool) {
+	if view.Home == "" {
+		return Move{}, false
+	}
+
+	return Move{Kind: game.MoveSupport, ProvinceID: view.Home}, true
+}
+
+// pileOnLeaderStrategy plays like the crowd finishing off the province the nuke is about
+// to take. A bot of it attacks the best ranked province it can reach, at random while
+// none is ranked, and has nothing to play while none is in its reach
+type pileOnLeaderStrategy struct{}
+
+func (pileOnLeaderStrategy) Pick(view View, rng *rand.Rand) (Move, bool) {
+	for _, ranked := range view.Top {
+		if view.Reach == nil || slices.Contains(view.Reach, ranked.ID.Hex()) {
+			return Move{Kind: game.MoveAttack, ProvinceID: ranked.ID.Hex()}, true
+		}
+	}
+
+	targets := view.attackable(view.Provinces)
+	if len(targets) == 0 {
+		return Move{}, false
+	}
+
+	return Move{Kind: game.MoveAttack, ProvinceID: targets[rng.IntN(len(targets))].ID.Hex()}, true
+}
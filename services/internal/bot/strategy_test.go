@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/game"
+	"services/internal/province/model"
+)
+
+func TestStrategies_PickTheirMoves(t *testing.T) {
+	leader := model.Province{ID: primitive.NewObjectID(), ProvinceName: "Ankara", DestroymentRound: -1}
+	home := model.Province{ID: primitive.NewObjectID(), ProvinceName: "Izmir", DestroymentRound: -1}
+	view := View{
+		Provinces: []model.Province{leader, home},
+		Top:       []model.RankedProvince{{Province: leader, Score: 12}, {Province: home, Score: 3}},
+		Home:      home.ID.Hex(),
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	testCases := []struct {
+		strategy string
+		view     View
+		want     Move
+		ok       bool
+	}{
+		{StrategyDefendHome, view, Move{Kind: game.MoveSupport, ProvinceID: home.ID.Hex()}, true},
+		{StrategyDefendHome, View{}, Move{}, false},
+		{StrategyPileOnLeader, view, Move{Kind: game.MoveAttack, ProvinceID: leader.ID.Hex()}, true},
+		{StrategyPileOnLeader, View{Provinces: []model.Province{home}}, Move{Kind: game.MoveAttack, ProvinceID: home.ID.Hex()}, true},
+		{StrategyRandom, View{}, Move{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			strategy, err := NewStrategy(tc.strategy)
+			require.NoError(t, err)
+
+			move, ok := strategy.Pick(tc.view, rng)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, move)
+		})
+	}
+
+	_, err := NewStrategy("sniper")
+	assert.ErrorContains(t, err, "sniper")
+}
+
+func TestStrategies_RandomPlaysEveryMoveOnLivingProvinces(t *testing.T) {
+	provinces := []model.Province{{ID: primitive.NewObjectID()}, {ID: primitive.NewObjectID()}}
+	strategy, err := NewStrategy(StrategyRandom)
+	require.NoError(t, err)
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	kinds := map[string]int{}
+	for range 100 {
+		move, ok := strategy.Pick(View{Provinces: provinces}, rng)
+		require.True(t, ok)
+		assert.Contains(t, []string{provinces[0].ID.Hex(), provinces[1].ID.Hex()}, move.ProvinceID)
+		kinds[move.Kind]++
+	}
+
+	assert.Positive(t, kinds[game.MoveAttack])
+	assert.Positive(t, kinds[game.MoveSupport])
+}
+
+func TestStrategies_AttackOnlyInReach(t *testing.T) {
+	leader := model.Province{ID: primitive.NewObjectID(), ProvinceName: "Kars", DestroymentRound: -1}
+	neighbor := model.Province{ID: primitive.NewObjectID(), ProvinceName: "Ankara", DestroymentRound: -1}
+	view := View{
+		Provinces: []model.Province{leader, neighbor},
+		Top:       []model.RankedProvince{{Province: leader, Score: 12}, {Province: neighbor, Score: 3}},
+		Reach:     []string{neighbor.ID.Hex()},
+	}
+	rng := rand.New(rand.NewPCG(5, 6))
+
+	pileOn, err := NewStrategy(StrategyPileOnLeader)
+	require.NoError(t, err)
+	move, ok := pileOn.Pick(view, rng)
+	require.True(t, ok)
+	assert.Equal(t, Move{Kind: game.MoveAttack, ProvinceID: neighbor.ID.Hex()}, move)
+
+	random, err := NewStrategy(StrategyRandom)
+	require.NoError(t, err)
+	for range 100 {
+		move, ok := random.Pick(view, rng)
+		require.True(t, ok)
+		if move.Kind == game.MoveAttack {
+			assert.Equal(t, neighbor.ID.Hex(), move.ProvinceID)
+		}
+	}
+
+	// Out of reach of everything, a bot only supports
+	view.Reach = []string{}
+	_, ok = pileOn.Pick(view, rng)
+	assert.False(t, ok)
+	move, ok = random.Pick(view, rng)
+	require.True(t, ok)
+	assert.Equal(t, game.MoveSupport, move.Kind)
+}
+
+func TestParseSquads(t *testing.T) {
+	squads, err := ParseSquads(" random:3,defend-home:2 ,pile-on-leader:1,")
+	require.NoError(t, err)
+	assert.Equal(t, []Squad{{StrategyRandom, 3}, {StrategyDefendHome, 2}, {StrategyPileOnLeader, 1}}, squads)
+	assert.Equal(t, "random:3,defend-home:2,pile-on-leader:1", FormatSquads(squads))
+
+	squads, err = ParseSquads("")
+	require.NoError(t, err)
+	assert.Empty(t, squads)
+
+	for _, spec := range []string{"sniper:1", "random", "random:0", "random:many"} {
+		_, err := ParseSquads(spec)
+		assert.Error(t, err, spec)
+	}
+}
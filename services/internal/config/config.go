@@ -0,0 +1,460 @@
+// Package config loads the settings shared by cmd/app and cmd/cronjob.
+//
+// Every setting has an environment variable name. Values are layered, later ones win:
+// defaults, the optional dotenv file, the process environment, command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"services/internal/bot"
+	"services/internal/game"
+)
+
+// Environment variable names
+const (
+	KeyEnv             = "ENV"
+	KeyLogLevel        = "LOG_LEVEL"
+	KeyPort            = "PORT"
+	KeyHealthPort      = "HEALTH_PORT"
+	KeyStore           = "STORE"
+	KeyDB              = "DB"
+	KeyDBName          = "DB_NAME"
+	KeyGameStartDate   = "GAME_START_DATE"
+	KeyS3BucketName    = "S3_BUCKET_NAME"
+	KeyShutdownTimeout = "SHUTDOWN_TIMEOUT"
+	KeyNukeTimeout     = "NUKE_TIMEOUT"
+	KeyCacheTTL        = "CACHE_TTL"
+	KeyMoveFlush       = "MOVE_FLUSH_INTERVAL"
+	KeyNukeInAPI       = "NUKE_IN_API"
+	KeyGameRules       = "GAME_RULES"
+	KeyBots            = "BOTS"
+	KeyTraceExporter   = "TRACE_EXPORTER"
+	KeyCORSOrigins     = "CORS_ALLOWED_ORIGINS"
+	KeyCORSCredentials = "CORS_ALLOW_CREDENTIALS"
+	KeyCORSMethods     = "CORS_ALLOWED_METHODS"
+	KeyCORSHeaders     = "CORS_ALLOWED_HEADERS"
+	KeyCORSMaxAge      = "CORS_MAX_AGE"
+	KeyConfigFile      = "CONFIG_FILE"
+)
+
+const EnvProd = "prod"
+
+// Storage backends selectable with STORE
+const (
+	StoreMongo    = "mongo"
+	StoreSQLite   = "sqlite"   // DB is a file path, for local runs and small deployments
+	StorePostgres = "postgres" // DB is a postgres:// URL
+	StoreMemory   = "memory"   // non persistent, for tests and local runs without a database
+)
+
+// Span exporters selectable with TRACE_EXPORTER. The OTLP endpoint and headers are read
+// by the exporter itself from the standard OTEL_EXPORTER_OTLP_* variables
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+var defaults = map[string]string{
+	KeyEnv:             "dev",
+	KeyLogLevel:        "info",
+	KeyPort:            "8080",
+	KeyHealthPort:      "8081",
+	KeyStore:           StoreMongo,
+	KeyDBName:          "nuky_db",
+	KeyShutdownTimeout: "15s",
+	KeyNukeTimeout:     "10s",
+	KeyCacheTTL:        "2s",
+	KeyMoveFlush:       "0s",
+	KeyNukeInAPI:       "false",
+	KeyTraceExporter:   TraceExporterNone,
+	KeyCORSOrigins:     "*",
+	KeyCORSCredentials: "false",
+	KeyCORSMethods:     "GET,POST,PUT,DELETE",
+	KeyCORSHeaders:     "Content-Type,Authorization,X-Request-ID,If-None-Match,traceparent",
+	KeyCORSMaxAge:      "10m",
+}
+
+// flagNames maps every setting to its command line flag
+var flagNames = map[string]string{
+	KeyEnv:             "env",
+	KeyLogLevel:        "log-level",
+	KeyPort:            "port",
+	KeyHealthPort:      "health-port",
+	KeyStore:           "store",
+	KeyDB:              "db",
+	KeyDBName:          "db-name",
+	KeyGameStartDate:   "game-start-date",
+	KeyS3BucketName:    "s3-bucket-name",
+	KeyShutdownTimeout: "shutdown-timeout",
+	KeyNukeTimeout:     "nuke-timeout",
+	KeyCacheTTL:        "cache-ttl",
+	KeyMoveFlush:       "move-flush-interval",
+	KeyNukeInAPI:       "nuke-in-api",
+	KeyGameRules:       "game-rules",
+	KeyBots:            "bots",
+	KeyTraceExporter:   "trace-exporter",
+	KeyCORSOrigins:     "cors-allowed-origins",
+	KeyCORSCredentials: "cors-allow-credentials",
+	KeyCORSMethods:     "cors-allowed-methods",
+	KeyCORSHeaders:     "cors-allowed-headers",
+	KeyCORSMaxAge:      "cors-max-age",
+}
+
+type Config struct {
+	Env             string
+	LogLevel        slog.Level // records are JSON in production, text otherwise
+	Port            int
+	HealthPort      int    // probes of the nuke timer, the API serves them on Port
+	Store           string // one of the Store* backends
+	DB              string // connection string of the store, may contain credentials
+	DBName          string
+	GameStartDate   time.Time
+	S3BucketName    string
+	ShutdownTimeout time.Duration // how long in-flight work may take after SIGTERM
+	NukeTimeout     time.Duration // how long a single nuke round may take
+	CacheTTL        time.Duration // how long province reads are served from memory, 0 to not cache
+	MoveFlush       time.Duration // how often buffered moves are written in bulk, 0 writes every move at once
+	NukeInAPI       bool          // the API runs the nuke timer of the public game instead of the cronjob, for a single API instance
+	GameRules       string        // JSON file the rules are read from, empty to play by game.DefaultRules
+	Rules           game.Rules
+	Bots            []bot.Squad // bots of the public game, every API instance runs them, the cooldown still holds each to one move
+	TraceExporter   string      // one of the TraceExporter* exporters
+
+	// Cross-origin callers of the API. Lists are comma separated, "*" allows any
+	// origin but not together with credentials
+	CORSOrigins     []string
+	CORSCredentials bool
+	CORSMethods     []string
+	CORSHeaders     []string
+	CORSMaxAge      time.Duration // how long browsers may cache a preflight, 0 to not ask
+
+	File string // dotenv file the values were read from, empty if none
+}
+
+// IsProd tells whether the binary runs in production
+func (c *Config) IsProd() bool {
+	return c.Env == EnvProd
+}
+
+// Addr returns the address the HTTP server listens on
+func (c *Config) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// HealthAddr returns the address the nuke timer serves its probes on
+func (c *Config) HealthAddr() string {
+	return ":" + strconv.Itoa(c.HealthPort)
+}
+
+// String prints the configuration with credentials redacted, safe for logs
+func (c *Config) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s=%s\n", KeyEnv, c.Env)
+	fmt.Fprintf(&b, "%s=%s\n", KeyLogLevel, c.LogLevel)
+	fmt.Fprintf(&b, "%s=%d\n", KeyPort, c.Port)
+	fmt.Fprintf(&b, "%s=%d\n", KeyHealthPort, c.HealthPort)
+	fmt.Fprintf(&b, "%s=%s\n", KeyStore, c.Store)
+	fmt.Fprintf(&b, "%s=%s\n", KeyDB, redactURI(c.DB))
+	fmt.Fprintf(&b, "%s=%s\n", KeyDBName, c.DBName)
+	fmt.Fprintf(&b, "%s=%s\n", KeyGameStartDate, c.GameStartDate.Format(time.RFC3339))
+	fmt.Fprintf(&b, "%s=%s\n", KeyS3BucketName, c.S3BucketName)
+	fmt.Fprintf(&b, "%s=%s\n", KeyShutdownTimeout, c.ShutdownTimeout)
+	fmt.Fprintf(&b, "%s=%s\n", KeyNukeTimeout, c.NukeTimeout)
+	fmt.Fprintf(&b, "%s=%s\n", KeyCacheTTL, c.CacheTTL)
+	fmt.Fprintf(&b, "%s=%s\n", KeyMoveFlush, c.MoveFlush)
+	fmt.Fprintf(&b, "%s=%t\n", KeyNukeInAPI, c.NukeInAPI)
+	fmt.Fprintf(&b, "%s=%s\n", KeyGameRules, c.GameRules)
+	fmt.Fprintf(&b, "%s=%s\n", KeyBots, bot.FormatSquads(c.Bots))
+	fmt.Fprintf(&b, "%s=%s\n", KeyTraceExporter, c.TraceExporter)
+	fmt.Fprintf(&b, "%s=%s\n", KeyCORSOrigins, strings.Join(c.CORSOrigins, ","))
+	fmt.Fprintf(&b, "%s=%t\n", KeyCORSCredentials, c.CORSCredentials)
+	fmt.Fprintf(&b, "%s=%s\n", KeyCORSMethods, strings.Join(c.CORSMethods, ","))
+	fmt.Fprintf(&b, "%s=%s\n", KeyCORSHeaders, strings.Join(c.CORSHeaders, ","))
+	fmt.Fprintf(&b, "%s=%s", KeyCORSMaxAge, c.CORSMaxAge)
+	if c.File != "" {
+		fmt.Fprintf(&b, "\n%s=%s", KeyConfigFile, c.File)
+	}
+	return b.String()
+}
+
+// Load reads the configuration for the binary called name, args are the command line
+// arguments without the program name. Every invalid setting is reported at once.
+func Load(name string, args []string) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "dotenv file to read settings from (env "+KeyConfigFile+")")
+	flagValues := make(map[string]*string, len(flagNames))
+	for key, flagName := range flagNames {
+		flagValues[key] = fs.String(flagName, "", "overrides "+key)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for key, value := range defaults {
+		values[key] = value
+	}
+
+	// Optional dotenv file
+	file, err := resolveFile(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		fileValues, err := godotenv.Read(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file %s: %w", file, err)
+		}
+		for key := range flagNames {
+			if value, ok := fileValues[key]; ok {
+				values[key] = value
+			}
+		}
+	}
+
+	// Environment
+	for key := range flagNames {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			values[key] = value
+		}
+	}
+
+	// Flags, only the ones actually passed
+	fs.Visit(func(f *flag.Flag) {
+		for key, flagName := range flagNames {
+			if f.Name == flagName {
+				values[key] = *flagValues[key]
+			}
+		}
+	})
+
+	cfg, err := parse(values)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.File = file
+	return cfg, nil
+}
+
+// --------------------------------------------------------------------
+
+func parse(values map[string]string) (*Config, error) {
+	var errs []error
+	cfg := &Config{
+		Env:           values[KeyEnv],
+		Store:         values[KeyStore],
+		TraceExporter: values[KeyTraceExporter],
+		DB:            values[KeyDB],
+		DBName:        values[KeyDBName],
+		S3BucketName:  values[KeyS3BucketName],
+	}
+
+	switch cfg.Store {
+	case StoreMongo, StoreSQLite, StorePostgres, StoreMemory:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s, %s, %s, got %q", KeyStore, StoreMongo, StoreSQLite, StorePostgres, StoreMemory, cfg.Store))
+	}
+
+	switch cfg.TraceExporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s, %s, got %q", KeyTraceExporter, TraceExporterNone, TraceExporterStdout, TraceExporterOTLP, cfg.TraceExporter))
+	}
+
+	switch cfg.Store {
+	case StoreMongo, StoreSQLite, StorePostgres:
+		if cfg.DB == "" {
+			errs = append(errs, fmt.Errorf("%s is required by the %s store", KeyDB, cfg.Store))
+		} else if cfg.Store == StoreMongo && !hasScheme(cfg.DB, "mongodb", "mongodb+srv") {
+			errs = append(errs, fmt.Errorf("%s must be a mongodb:// or mongodb+srv:// connection string", KeyDB))
+		} else if cfg.Store == StorePostgres && !hasScheme(cfg.DB, "postgres", "postgresql") {
+			errs = append(errs, fmt.Errorf("%s must be a postgres:// or postgresql:// connection string", KeyDB))
+		}
+	}
+
+	if cfg.DBName == "" {
+		errs = append(errs, fmt.Errorf("%s must not be empty", KeyDBName))
+	}
+
+	if err := cfg.LogLevel.UnmarshalText([]byte(values[KeyLogLevel])); err != nil {
+		errs = append(errs, fmt.Errorf("%s must be one of debug, info, warn, error, got %q", KeyLogLevel, values[KeyLogLevel]))
+	}
+
+	cfg.Port = parsePort(values, KeyPort, &errs)
+	cfg.HealthPort = parsePort(values, KeyHealthPort, &errs)
+
+	var err error
+
+	if values[KeyGameStartDate] == "" {
+		errs = append(errs, fmt.Errorf("%s is required", KeyGameStartDate))
+	} else if cfg.GameStartDate, err = time.Parse(time.RFC3339, values[KeyGameStartDate]); err != nil {
+		errs = append(errs, fmt.Errorf("%s must be an RFC 3339 date like 2025-05-01T14:00:00Z, got %q", KeyGameStartDate, values[KeyGameStartDate]))
+	}
+
+	cfg.ShutdownTimeout = parseDuration(values, KeyShutdownTimeout, &errs)
+	cfg.NukeTimeout = parseDuration(values, KeyNukeTimeout, &errs)
+
+	if cfg.CacheTTL, err = time.ParseDuration(values[KeyCacheTTL]); err != nil || cfg.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("%s must be a duration like 2s, got %q", KeyCacheTTL, values[KeyCacheTTL]))
+	}
+
+	if cfg.MoveFlush, err = time.ParseDuration(values[KeyMoveFlush]); err != nil || cfg.MoveFlush < 0 {
+		errs = append(errs, fmt.Errorf("%s must be a duration like 100ms, got %q", KeyMoveFlush, values[KeyMoveFlush]))
+	}
+
+	// Buffered moves live in the memory of the API, only a nuke run by the same process
+	// can flush them before it ranks the provinces
+	if cfg.NukeInAPI, err = strconv.ParseBool(values[KeyNukeInAPI]); err != nil {
+		errs = append(errs, fmt.Errorf("%s must be true or false, got %q", KeyNukeInAPI, values[KeyNukeInAPI]))
+	} else if cfg.MoveFlush > 0 && !cfg.NukeInAPI {
+		errs = append(errs, fmt.Errorf("%s needs %s, the cronjob can't flush the moves the API buffers before its nuke", KeyMoveFlush, KeyNukeInAPI))
+	}
+
+	cfg.GameRules = values[KeyGameRules]
+	if cfg.Rules, err = game.LoadRules(cfg.GameRules); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", KeyGameRules, err))
+	}
+
+	if cfg.Bots, err = bot.ParseSquads(values[KeyBots]); err != nil {
+		errs = append(errs, fmt.Errorf("%s must list bots like random:3,pile-on-leader:1: %w", KeyBots, err))
+	}
+
+	cfg.CORSOrigins = parseList(values[KeyCORSOrigins])
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			errs = append(errs, fmt.Errorf("%s must list origins like https://nuclick.one, got %q", KeyCORSOrigins, origin))
+		}
+	}
+
+	if cfg.CORSCredentials, err = strconv.ParseBool(values[KeyCORSCredentials]); err != nil {
+		errs = append(errs, fmt.Errorf("%s must be true or false, got %q", KeyCORSCredentials, values[KeyCORSCredentials]))
+	} else if cfg.CORSCredentials && slices.Contains(cfg.CORSOrigins, "*") {
+		errs = append(errs, fmt.Errorf("%s can't be enabled while %s allows any origin", KeyCORSCredentials, KeyCORSOrigins))
+	}
+
+	cfg.CORSMethods = parseList(strings.ToUpper(values[KeyCORSMethods]))
+	if len(cfg.CORSMethods) == 0 {
+		errs = append(errs, fmt.Errorf("%s must not be empty", KeyCORSMethods))
+	}
+	cfg.CORSHeaders = parseList(values[KeyCORSHeaders])
+
+	if cfg.CORSMaxAge, err = time.ParseDuration(values[KeyCORSMaxAge]); err != nil || cfg.CORSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("%s must be a duration like 10m, got %q", KeyCORSMaxAge, values[KeyCORSMaxAge]))
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return cfg, nil
+}
+
+func parsePort(values map[string]string, key string, errs *[]error) int {
+	port, err := strconv.Atoi(values[key])
+	if err != nil || port < 1 || port > 65535 {
+		*errs = append(*errs, fmt.Errorf("%s must be a port number, got %q", key, values[key]))
+	}
+	return port
+}
+
+func parseDuration(values map[string]string, key string, errs *[]error) time.Duration {
+	duration, err := time.ParseDuration(values[key])
+	if err != nil || duration <= 0 {
+		*errs = append(*errs, fmt.Errorf("%s must be a positive duration like 30s, got %q", key, values[key]))
+	}
+	return duration
+}
+
+// parseList splits a comma separated list, dropping empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// resolveFile returns the dotenv file to read. An explicit file must exist, otherwise
+// outside production the closest .env between the working directory and the module
+// root (services/) is used if any, so `go run` works from any package directory.
+func resolveFile(flagValue string) (string, error) {
+	explicit := flagValue
+	if explicit == "" {
+		explicit = os.Getenv(KeyConfigFile)
+	}
+
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", fmt.Errorf("config file: %w", err)
+		}
+		return explicit, nil
+	}
+
+	if os.Getenv(KeyEnv) == EnvProd {
+		return "", nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", nil
+	}
+
+	for {
+		candidate := filepath.Join(dir, ".env")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+
+		// Stop at the module root
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// hasScheme tells whether uri parses as a URL with one of the given schemes
+func hasScheme(uri string, schemes ...string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && slices.Contains(schemes, u.Scheme)
+}
+
+// redactURI hides the password of a connection string
+func redactURI(uri string) string {
+	if uri == "" {
+		return ""
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "<redacted>"
+	}
+
+	return u.Redacted()
+}
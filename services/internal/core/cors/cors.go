@@ -0,0 +1,88 @@
+// Package cors answers cross-origin requests from the origins allowed by a Policy
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AnyOrigin in AllowedOrigins allows every origin, it can't be combined with credentials
+const AnyOrigin = "*"
+
+// Policy is what cross-origin callers are allowed to do
+type Policy struct {
+	AllowedOrigins   []string // exact origins like https://nuclick.one, or AnyOrigin
+	AllowCredentials bool     // let browsers send cookies and Authorization headers
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string      // response headers scripts may read
+	MaxAge           time.Duration // how long browsers may cache a preflight, 0 leaves it to them
+}
+
+// Middleware adds the CORS headers allowed by p to the responses of next and answers
+// preflight requests itself. Requests from other origins are served without them, so
+// browsers refuse to hand the response to the calling script
+func Middleware(p Policy, next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(p.AllowedOrigins, AnyOrigin)
+	methods := strings.Join(p.AllowedMethods, ", ")
+	headers := strings.Join(p.AllowedHeaders, ", ")
+	exposed := strings.Join(p.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(p.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// The response depends on the origin unless every origin gets the same one
+		if !anyOrigin || p.AllowCredentials {
+			w.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !anyOrigin && !slices.Contains(p.AllowedOrigins, origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if anyOrigin && !p.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", AnyOrigin)
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		if headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		if p.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
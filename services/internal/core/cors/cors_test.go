@@ -0,0 +1,106 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+})
+
+func serve(p Policy, method, origin string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/provinces", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	Middleware(p, ok).ServeHTTP(w, r)
+	return w
+}
+
+var allowList = Policy{
+	AllowedOrigins:   []string{"https://nuclick.one", "http://localhost:5173"},
+	AllowCredentials: true,
+	AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+	AllowedHeaders:   []string{"Content-Type", "Authorization"},
+	ExposedHeaders:   []string{"X-Request-ID", "Retry-After"},
+	MaxAge:           10 * time.Minute,
+}
+
+func TestMiddleware_AllowedOrigin(t *testing.T) {
+	w := serve(allowList, http.MethodGet, "https://nuclick.one")
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "https://nuclick.one", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "X-Request-ID, Retry-After", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, []string{"Origin"}, w.Header().Values("Vary"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestMiddleware_OtherOrigin(t *testing.T) {
+	w := serve(allowList, http.MethodGet, "https://evil.example")
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, []string{"Origin"}, w.Header().Values("Vary"))
+}
+
+func TestMiddleware_SameOrigin(t *testing.T) {
+	w := serve(allowList, http.MethodGet, "")
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestMiddleware_Preflight(t *testing.T) {
+	w := serve(allowList, http.MethodOptions, "http://localhost:5173",
+		"Access-Control-Request-Method", "DELETE",
+		"Access-Control-Request-Headers", "authorization")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "http://localhost:5173", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+}
+
+func TestMiddleware_PreflightFromOtherOrigin(t *testing.T) {
+	w := serve(allowList, http.MethodOptions, "https://evil.example", "Access-Control-Request-Method", "POST")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestMiddleware_PlainOptionsReachesHandler(t *testing.T) {
+	w := serve(allowList, http.MethodOptions, "https://nuclick.one")
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestMiddleware_AnyOrigin(t *testing.T) {
+	p := Policy{AllowedOrigins: []string{AnyOrigin}, AllowedMethods: []string{"GET", "POST"}}
+
+	w := serve(p, http.MethodGet, "https://anywhere.example")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Values("Vary"))
+
+	w = serve(p, http.MethodOptions, "https://anywhere.example", "Access-Control-Request-Method", "POST")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+}
@@ -0,0 +1,154 @@
+// Package health answers the liveness, readiness and version probes of both binaries
+package health
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"services/internal/core/response"
+)
+
+// Check statuses
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// DefaultCheckTimeout bounds a single readiness check
+const DefaultCheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable, a nil error means it is
+type Check func(ctx context.Context) error
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type VersionResponse struct {
+	Version      string `json:"version"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified"`
+	GoVersion    string `json:"go_version"`
+}
+
+// Checker holds the named readiness checks of a binary
+type Checker struct {
+	mu      sync.RWMutex
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks:  make(map[string]Check),
+		timeout: DefaultCheckTimeout,
+	}
+}
+
+// Add registers a readiness check, a check added under the same name replaces it
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Run runs every check concurrently, the binary is ready when all of them pass
+func (c *Checker) Run(ctx context.Context) ReadinessResponse {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	report := ReadinessResponse{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			result := CheckResult{Status: StatusOK}
+			if err := check(ctx); err != nil {
+				result = CheckResult{Status: StatusFail, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// GET /healthz, answers as long as the process serves requests
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, HealthResponse{Status: StatusOK})
+}
+
+// GET /readyz, 503 when any check fails
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	response.JSON(w, status, report)
+}
+
+// GET /version
+func Version(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, ReadVersion())
+}
+
+// ReadVersion returns the build info embedded by the Go toolchain
+var ReadVersion = sync.OnceValue(func() VersionResponse {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return VersionResponse{Version: "unknown"}
+	}
+
+	version := VersionResponse{
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version.Revision = setting.Value
+		case "vcs.time":
+			version.RevisionTime = setting.Value
+		case "vcs.modified":
+			version.Modified = setting.Value == "true"
+		}
+	}
+
+	return version
+})
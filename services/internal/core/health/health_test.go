@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readyz(t *testing.T, c *Checker) (int, ReadinessResponse) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	c.Readyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var body ReadinessResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	return rr.Code, body
+}
+
+func TestReadyz_AllChecksPass(t *testing.T) {
+	c := NewChecker()
+	c.Add("store", func(ctx context.Context) error { return nil })
+	c.Add("config", func(ctx context.Context) error { return nil })
+
+	status, body := readyz(t, c)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusOK, body.Status)
+	assert.Equal(t, map[string]CheckResult{"store": {Status: StatusOK}, "config": {Status: StatusOK}}, body.Checks)
+}
+
+func TestReadyz_FailingCheck(t *testing.T) {
+	c := NewChecker()
+	c.Add("store", func(ctx context.Context) error { return errors.New("connection refused") })
+	c.Add("config", func(ctx context.Context) error { return nil })
+
+	status, body := readyz(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusFail, body.Status)
+	assert.Equal(t, CheckResult{Status: StatusFail, Error: "connection refused"}, body.Checks["store"])
+	assert.Equal(t, StatusOK, body.Checks["config"].Status)
+}
+
+func TestReadyz_CheckTimesOut(t *testing.T) {
+	c := NewChecker()
+	c.timeout = 10 * time.Millisecond
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	status, body := readyz(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusFail, body.Checks["slow"].Status)
+}
+
+func TestHealthz(t *testing.T) {
+	c := NewChecker()
+	c.Add("store", func(ctx context.Context) error { return errors.New("down") })
+
+	// Liveness doesn't depend on the readiness checks
+	rr := httptest.NewRecorder()
+	c.Healthz(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, rr.Body.String())
+}
+
+func TestVersion(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Version(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var body VersionResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.NotEmpty(t, body.Version)
+	assert.NotEmpty(t, body.GoVersion)
+}
@@ -0,0 +1,101 @@
+// Package logging builds the slog logger shared by both binaries.
+//
+// Records logged with a context carry its request ID, its trace and every attribute added
+// to it with With, so a repo logging with the request context is correlated with the request.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"services/internal/core/requestid"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys, used the same way in every package
+const (
+	KeyRequestID  = "request_id"
+	KeyUserID     = "user_id"
+	KeyProvinceID = "province_id"
+	KeyRound      = "round"
+	KeyLobbyID    = "lobby_id"
+	KeyError      = "error"
+	KeyTraceID    = "trace_id"
+	KeySpanID     = "span_id"
+)
+
+type contextKey struct{}
+
+// New returns a logger writing JSON records when json is set, text records otherwise
+func New(w io.Writer, json bool, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if json {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// With returns a copy of ctx carrying attrs, they are added to every record logged with it
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+func UserID(id string) slog.Attr {
+	return slog.String(KeyUserID, id)
+}
+
+func ProvinceID(id string) slog.Attr {
+	return slog.String(KeyProvinceID, id)
+}
+
+func Round(round int) slog.Attr {
+	return slog.Int(KeyRound, round)
+}
+
+func LobbyID(id string) slog.Attr {
+	return slog.String(KeyLobbyID, id)
+}
+
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// contextHandler adds the request ID, the current span and the attributes carried by the context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		record.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String(KeyTraceID, span.TraceID().String()), slog.String(KeySpanID, span.SpanID().String()))
+	}
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
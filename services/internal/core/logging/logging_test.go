@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"services/internal/core/requestid"
+)
+
+func TestNew_AddsContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, true, slog.LevelInfo)
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	ctx = With(ctx, UserID("u1"))
+	ctx = With(ctx, ProvinceID("p1"))
+
+	logger.With("component", "test").ErrorContext(ctx, "update failed", Err(errors.New("boom")))
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "update failed", record["msg"])
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "req-1", record[KeyRequestID])
+	assert.Equal(t, "u1", record[KeyUserID])
+	assert.Equal(t, "p1", record[KeyProvinceID])
+	assert.Equal(t, "boom", record[KeyError])
+	assert.Equal(t, "test", record["component"])
+}
+
+func TestNew_TextAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, false, slog.LevelWarn)
+
+	logger.InfoContext(context.Background(), "hidden")
+	logger.WarnContext(With(context.Background(), Round(3)), "shown")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "msg=shown round=3")
+}
+
+func TestWith_DoesNotLeakBetweenContexts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, true, slog.LevelInfo)
+
+	parent := With(context.Background(), UserID("u1"))
+	_ = With(parent, ProvinceID("p1"))
+
+	logger.InfoContext(parent, "parent only")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "u1", record[KeyUserID])
+	assert.NotContains(t, record, KeyProvinceID)
+}
@@ -0,0 +1,232 @@
+// Package metrics exposes the Prometheus metrics of both binaries on their own registry.
+//
+// Every recording method is a no-op on a nil *Metrics, services built without metrics
+// (mostly in tests) don't need to check.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "nuky"
+
+// Province moves
+const (
+	MoveAttack  = "attack"
+	MoveSupport = "support"
+)
+
+// Outcomes of a nuke round or a store operation
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeAborted = "aborted" // cancelled or timed out
+)
+
+// unmatchedRoute labels requests no route matched, so unknown paths can't blow up cardinality
+const unmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	httpRequestDuration    *prometheus.HistogramVec
+	provinceMoves          *prometheus.CounterVec
+	cooldownRejections     prometheus.Counter
+	nukeRoundDuration      *prometheus.HistogramVec
+	storeOperationDuration *prometheus.HistogramVec
+	cacheLookups           *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route pattern, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+
+		provinceMoves: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "province_moves_total",
+			Help:      "Attacks and supports applied to a province.",
+		}, []string{"province", "move"}),
+
+		cooldownRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cooldown_rejections_total",
+			Help:      "Moves rejected because the player's cooldown wasn't over.",
+		}),
+
+		nukeRoundDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "nuke_round_duration_seconds",
+			Help:      "Duration of nuke rounds by outcome.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"outcome"}),
+
+		storeOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Duration of database commands by command name and outcome.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "outcome"}),
+
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Lookups of the in-process response cache by cache and result.",
+		}, []string{"cache", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequestDuration,
+		m.provinceMoves,
+		m.cooldownRejections,
+		m.nukeRoundDuration,
+		m.storeOperationDuration,
+		m.cacheLookups,
+	)
+	m.handler = promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+
+	return m
+}
+
+// Registry returns the registry every metric is registered on
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// GET /metrics, in the Prometheus text format
+func (m *Metrics) Serve(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
+
+// Recording --------------------------------------------------------------------
+
+// ProvinceMove counts an attack or support applied to a province
+func (m *Metrics) ProvinceMove(provinceID, move string) {
+	if m == nil {
+		return
+	}
+
+	m.provinceMoves.WithLabelValues(provinceID, move).Inc()
+}
+
+// CooldownRejected counts a move rejected by the cooldown
+func (m *Metrics) CooldownRejected() {
+	if m == nil {
+		return
+	}
+
+	m.cooldownRejections.Inc()
+}
+
+// ObserveNukeRound records how long a nuke round took and how it ended
+func (m *Metrics) ObserveNukeRound(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.nukeRoundDuration.WithLabelValues(Outcome(err)).Observe(duration.Seconds())
+}
+
+// ObserveStoreOperation records how long a database command took and how it ended
+func (m *Metrics) ObserveStoreOperation(operation string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.storeOperationDuration.WithLabelValues(operation, Outcome(err)).Observe(duration.Seconds())
+}
+
+// CacheLookup counts a lookup of the named cache, served from it or not
+func (m *Metrics) CacheLookup(cache string, hit bool) {
+	if m == nil {
+		return
+	}
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// Outcome labels the result of an operation by its error
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeAborted
+	default:
+		return OutcomeError
+	}
+}
+
+// HTTP --------------------------------------------------------------------
+
+// InstrumentHandler records the duration and status of every request served by next,
+// labelled with the path of the mux pattern that matched the request
+func (m *Metrics) InstrumentHandler(mux *http.ServeMux, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		if _, pattern := mux.Handler(r); pattern != "" {
+			// Patterns are "METHOD /path", the method has its own label
+			_, path, _ := strings.Cut(pattern, " ")
+			route = path
+		}
+
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sr, r)
+
+		m.httpRequestDuration.
+			WithLabelValues(route, r.Method, strconv.Itoa(sr.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestCount returns how many requests were observed with the given labels
+func requestCount(t *testing.T, m *Metrics, route, method, status string) uint64 {
+	t.Helper()
+
+	families, err := m.Registry().Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "nuky_http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["route"] == route && labels["method"] == method && labels["status"] == status {
+				return metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestInstrumentHandler_LabelsByRoutePattern(t *testing.T) {
+	m := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/province/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("POST /api/province/attack", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.InstrumentHandler(mux, mux)
+
+	for _, target := range []string{"/api/province/a", "/api/province/b", "/nothing/here"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/province/attack", nil))
+
+	// Path values don't end up in labels
+	assert.Equal(t, 3, testutil.CollectAndCount(m.httpRequestDuration))
+	assert.Equal(t, uint64(2), requestCount(t, m, "/api/province/{id}", http.MethodGet, "200"))
+	assert.Equal(t, uint64(1), requestCount(t, m, "/api/province/attack", http.MethodPost, "404"))
+	assert.Equal(t, uint64(1), requestCount(t, m, unmatchedRoute, http.MethodGet, "404"))
+}
+
+func TestRecording(t *testing.T) {
+	m := New()
+
+	m.ProvinceMove("p1", MoveAttack)
+	m.ProvinceMove("p1", MoveAttack)
+	m.ProvinceMove("p1", MoveSupport)
+	m.CooldownRejected()
+	m.ObserveNukeRound(time.Second, nil)
+	m.ObserveNukeRound(time.Second, fmt.Errorf("round: %w", context.DeadlineExceeded))
+	m.ObserveStoreOperation("find", time.Millisecond, errors.New("connection reset"))
+	m.CacheLookup("provinces", true)
+	m.CacheLookup("provinces", false)
+	m.CacheLookup("provinces", true)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.provinceMoves.WithLabelValues("p1", MoveAttack)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.provinceMoves.WithLabelValues("p1", MoveSupport)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.cooldownRejections))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.nukeRoundDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.storeOperationDuration))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("provinces", "hit")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("provinces", "miss")))
+}
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, OutcomeSuccess, Outcome(nil))
+	assert.Equal(t, OutcomeAborted, Outcome(context.Canceled))
+	assert.Equal(t, OutcomeAborted, Outcome(fmt.Errorf("reset: %w", context.DeadlineExceeded)))
+	assert.Equal(t, OutcomeError, Outcome(errors.New("boom")))
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ProvinceMove("p1", MoveAttack)
+		m.CooldownRejected()
+		m.ObserveNukeRound(time.Second, nil)
+		m.ObserveStoreOperation("find", time.Millisecond, nil)
+		m.CacheLookup("provinces", true)
+	})
+
+	next := http.NotFoundHandler()
+	assert.NotNil(t, m.InstrumentHandler(http.NewServeMux(), next))
+}
@@ -0,0 +1,126 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"services/internal/core/requestid"
+)
+
+// Machine readable error codes, the client branches on these instead of messages
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodePayloadTooLarge    = "payload_too_large"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeEmailTaken         = "email_taken"
+	CodeUsernameTaken      = "username_taken"
+	CodeLoginThrottled     = "login_throttled"
+	CodeAccountLocked      = "account_locked"
+	CodeCooldownActive     = "cooldown_active"
+	CodeItemUnavailable    = "item_unavailable"
+	CodeNotAdjacent        = "not_adjacent"
+	CodeForbidden          = "forbidden"
+	CodeAllianceConflict   = "alliance_conflict"
+	CodeAllyAttack         = "ally_attack"
+	CodeLobbyFull          = "lobby_full"
+	CodeLobbyFinished      = "lobby_finished"
+	CodeInternal           = "internal_error"
+)
+
+// ErrorBody is the uniform error envelope returned by every handler
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// JSON writes v as a JSON body with the given status code
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ETag returns a strong entity tag for a response body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Cached writes an already encoded JSON body with its entity tag. Clients revalidate on
+// every use and get a bodyless 304 when the If-None-Match tag they send still matches
+func Cached(w http.ResponseWriter, r *http.Request, body []byte, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// matchesETag tells whether an If-None-Match header matches etag, weakly as RFC 9110 asks
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Error writes an error envelope without details
+func Error(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	ErrorWithDetails(w, r, status, code, message, nil)
+}
+
+// ErrorWithDetails writes an error envelope, details is encoded as is
+func ErrorWithDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details any) {
+	JSON(w, status, ErrorResponse{
+		Error: ErrorBody{
+			Code:      code,
+			Message:   message,
+			Details:   details,
+			RequestID: requestID(r),
+		},
+	})
+}
+
+// MethodNotAllowed answers a request made with the wrong method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+// Internal answers with a generic internal error, the real cause should be logged by the caller
+func Internal(w http.ResponseWriter, r *http.Request, message string) {
+	Error(w, r, http.StatusInternalServerError, CodeInternal, message)
+}
+
+func requestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	if id := requestid.FromContext(r.Context()); id != "" {
+		return id
+	}
+
+	return r.Header.Get(requestid.Header)
+}
@@ -0,0 +1,99 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"services/internal/core/requestid"
+)
+
+func TestErrorWithDetails_Envelope(t *testing.T) {
+	handler := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ErrorWithDetails(w, r, http.StatusTooManyRequests, CodeLoginThrottled, "Slow down", map[string]int{"retry_after_seconds": 3})
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	req.Header.Set(requestid.Header, "req-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "req-123", rr.Header().Get(requestid.Header))
+
+	var body struct {
+		Error struct {
+			Code      string         `json:"code"`
+			Message   string         `json:"message"`
+			Details   map[string]int `json:"details"`
+			RequestID string         `json:"request_id"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, CodeLoginThrottled, body.Error.Code)
+	assert.Equal(t, "Slow down", body.Error.Message)
+	assert.Equal(t, 3, body.Error.Details["retry_after_seconds"])
+	assert.Equal(t, "req-123", body.Error.RequestID)
+}
+
+func TestError_GeneratesRequestIDAndOmitsDetails(t *testing.T) {
+	handler := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusNotFound, CodeNotFound, "User not found")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/cooldown", nil)
+	req.Header.Set(requestid.Header, "not a valid id!")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	generated := rr.Header().Get(requestid.Header)
+	assert.NotEmpty(t, generated)
+	assert.NotEqual(t, "not a valid id!", generated)
+
+	var body map[string]map[string]any
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, generated, body["error"]["request_id"])
+	assert.NotContains(t, body["error"], "details")
+}
+
+func TestCached_ETag(t *testing.T) {
+	body := []byte(`{"provinceList":[]}`)
+	etag := ETag(body)
+	assert.Equal(t, etag, ETag([]byte(`{"provinceList":[]}`)))
+	assert.NotEqual(t, etag, ETag([]byte(`{"provinceList":null}`)))
+
+	cases := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{"no validator", "", http.StatusOK},
+		{"stale tag", `"0123"`, http.StatusOK},
+		{"matching tag", etag, http.StatusNotModified},
+		{"weak matching tag in a list", `"0123", W/` + etag, http.StatusNotModified},
+		{"any tag", "*", http.StatusNotModified},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/province", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			Cached(rr, req, body, etag)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, etag, rr.Header().Get("ETag"))
+			assert.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+			if tc.status == http.StatusOK {
+				assert.Equal(t, string(body), rr.Body.String())
+			} else {
+				assert.Empty(t, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+-- Tables of the first SQL store. IDs are the hex form of the ObjectIDs the Mongo store
+-- uses, times are stored in UTC. A database made before schema_version was kept has
+-- these tables already
+CREATE TABLE IF NOT EXISTS users (
+    id             TEXT PRIMARY KEY,
+    username       TEXT NOT NULL UNIQUE,
+    email          TEXT NOT NULL UNIQUE,
+    password       TEXT NOT NULL,
+    last_move_date TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS provinces (
+    id                 TEXT PRIMARY KEY,
+    province_name      TEXT NOT NULL,
+    province_color_hex TEXT NOT NULL,
+    attack_count       INTEGER NOT NULL DEFAULT 0,
+    support_count      INTEGER NOT NULL DEFAULT 0,
+    destroyment_round  INTEGER NOT NULL DEFAULT -1
+);
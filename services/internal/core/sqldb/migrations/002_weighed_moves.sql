@@ -0,0 +1,20 @@
+-- Home provinces, streaks and items of the players, and the record of every move
+ALTER TABLE users ADD COLUMN home_province_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN streak INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN last_move_round INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN item_round INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN item_uses TEXT NOT NULL DEFAULT '{}'; -- JSON object of uses by item
+
+CREATE TABLE moves (
+    id          TEXT PRIMARY KEY,
+    province_id TEXT NOT NULL,
+    user_id     TEXT NOT NULL DEFAULT '', -- empty for anonymous moves
+    kind        TEXT NOT NULL,
+    item        TEXT NOT NULL DEFAULT '',
+    weight      INTEGER NOT NULL,
+    modifiers   TEXT NOT NULL DEFAULT '', -- comma separated
+    round       INTEGER NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moves_by_round ON moves (round, created_at);
@@ -0,0 +1 @@
+ALTER TABLE provinces ADD COLUMN shielded BOOLEAN NOT NULL DEFAULT FALSE; -- skipped by the next nuke
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN supported TEXT NOT NULL DEFAULT '[]'; -- JSON array of province IDs
+ALTER TABLE provinces ADD COLUMN neighbors TEXT NOT NULL DEFAULT ''; -- comma separated province IDs
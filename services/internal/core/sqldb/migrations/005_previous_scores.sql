@@ -0,0 +1 @@
+ALTER TABLE provinces ADD COLUMN previous_score DOUBLE PRECISION NOT NULL DEFAULT 0; -- score the last round ended with
@@ -0,0 +1,2 @@
+ALTER TABLE provinces ADD COLUMN carried_attacks DOUBLE PRECISION NOT NULL DEFAULT 0; -- decayed attacks of past rounds
+ALTER TABLE provinces ADD COLUMN carried_supports DOUBLE PRECISION NOT NULL DEFAULT 0; -- decayed supports of past rounds
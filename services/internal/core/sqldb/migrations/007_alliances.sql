@@ -0,0 +1,8 @@
+CREATE TABLE alliances (
+    id           TEXT PRIMARY KEY,
+    name         TEXT NOT NULL,
+    pact         TEXT NOT NULL DEFAULT '',
+    province_ids TEXT NOT NULL, -- comma separated member province IDs
+    founder_id   TEXT NOT NULL,
+    created_at   TIMESTAMP NOT NULL
+);
@@ -0,0 +1,16 @@
+-- The provinces of a lobby are rows of the provinces table carrying its ID
+ALTER TABLE provinces ADD COLUMN lobby_id TEXT NOT NULL DEFAULT ''; -- private lobby, empty for the public game
+
+CREATE INDEX provinces_by_lobby ON provinces (lobby_id);
+
+CREATE TABLE lobbies (
+    id                 TEXT PRIMARY KEY,
+    name               TEXT NOT NULL,
+    invite_code        TEXT NOT NULL UNIQUE,
+    owner_id           TEXT NOT NULL,
+    player_ids         TEXT NOT NULL, -- comma separated user IDs, the owner first
+    max_players        INTEGER NOT NULL,
+    nuke_every_minutes INTEGER NOT NULL,
+    started_at         TIMESTAMP NOT NULL,
+    next_nuke_at       TIMESTAMP NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE lobbies ADD COLUMN bots TEXT NOT NULL DEFAULT ''; -- comma separated user_id:username:strategy of the seated bots
@@ -0,0 +1 @@
+ALTER TABLE lobbies ADD COLUMN finished_at TIMESTAMP; -- set once a single province is left
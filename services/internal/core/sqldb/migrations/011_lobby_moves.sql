@@ -0,0 +1,6 @@
+CREATE TABLE lobby_moves (
+    lobby_id TEXT NOT NULL,
+    user_id  TEXT NOT NULL,
+    moved_at TIMESTAMP NOT NULL, -- last move of the player in the lobby
+    PRIMARY KEY (lobby_id, user_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE alliances ADD COLUMN invited_ids TEXT NOT NULL DEFAULT ''; -- comma separated province IDs invited to join
@@ -0,0 +1,116 @@
+// Package sqldb opens the SQL store, SQLite for local runs and small deployments,
+// PostgreSQL in production, and migrates its tables.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Database/sql driver names
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "pgx"
+)
+
+// migrations are the changes to the tables, shared by SQLite and PostgreSQL. Each file is
+// named after its version and runs once, in version order
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open connects with the given driver, dsn is a file path for SQLite (":memory:" for a
+// throwaway database) and a postgres:// URL for PostgreSQL. The tables are migrated to
+// the latest version
+func Open(ctx context.Context, driver, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == DriverSQLite {
+		// SQLite takes one writer at a time and an in-memory database lives as long as
+		// its connection, a single one avoids both busy errors and vanishing tables
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrate runs the migrations newer than the version in schema_version, each in a
+// transaction of its own that records its version. A database without schema_version
+// is at version 0, the first migration leaves the tables of the first SQL store as they
+// are. Of two instances migrating together one fails on the version it also recorded
+func migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_version: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema_version: %w", err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files { // sorted by name, so by version
+		name := strings.TrimPrefix(file, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s: no version: %w", name, err)
+		}
+		if version <= current {
+			continue
+		}
+
+		statements, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := apply(ctx, db, version, string(statements)); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// apply runs the statements of a migration and records its version, both or neither
+func apply(ctx context.Context, db *sql.DB, version int, statements string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES ($1)`, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
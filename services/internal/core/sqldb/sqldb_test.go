@@ -0,0 +1,55 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_MigratesAFirstVersionDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nuky.db")
+
+	// A database of the first SQL store, made before schema_version was kept
+	first, err := migrations.ReadFile("migrations/001_users_and_provinces.sql")
+	require.NoError(t, err)
+	old, err := sql.Open(DriverSQLite, path)
+	require.NoError(t, err)
+	_, err = old.ExecContext(ctx, string(first))
+	require.NoError(t, err)
+	_, err = old.ExecContext(ctx, `INSERT INTO provinces (id, province_name, province_color_hex) VALUES ('a', 'Ankara', '#ff0000')`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	db, err := Open(ctx, DriverSQLite, path)
+	require.NoError(t, err)
+
+	var name, lobbyID string
+	var shielded bool
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT province_name, shielded, lobby_id FROM provinces WHERE id = 'a'`).Scan(&name, &shielded, &lobbyID))
+	assert.Equal(t, "Ankara", name)
+	assert.False(t, shielded)
+	assert.Empty(t, lobbyID)
+	_, err = db.ExecContext(ctx, `INSERT INTO lobby_moves (lobby_id, user_id, moved_at) VALUES ('l', 'u', $1)`, time.Now().UTC())
+	require.NoError(t, err)
+
+	files, err := migrations.ReadDir("migrations")
+	require.NoError(t, err)
+	var version int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_version`).Scan(&version))
+	assert.Equal(t, len(files), version)
+	require.NoError(t, db.Close())
+
+	// A migrated database is opened as it is
+	db, err = Open(ctx, DriverSQLite, path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	var applied int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_version`).Scan(&applied))
+	assert.Equal(t, len(files), applied)
+}
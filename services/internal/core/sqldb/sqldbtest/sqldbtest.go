@@ -0,0 +1,82 @@
+// Package sqldbtest opens throwaway SQL stores for the tests of the SQL repos. They run
+// on SQLite in memory, and on PostgreSQL too when PostgresDSNEnv names a database.
+package sqldbtest
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/core/sqldb"
+)
+
+// PostgresDSNEnv names the environment variable with the DSN of a PostgreSQL database the
+// tests may create schemas in, the PostgreSQL tests are skipped while it is unset
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// Run runs test on a fresh SQLite store and on a fresh PostgreSQL one, each a subtest
+// named after its driver
+func Run(t *testing.T, test func(t *testing.T, db *sql.DB)) {
+	for _, driver := range []string{sqldb.DriverSQLite, sqldb.DriverPostgres} {
+		t.Run(driver, func(t *testing.T) {
+			test(t, Open(t, driver))
+		})
+	}
+}
+
+// Open opens a fresh store with driver, closed when the test ends. A PostgreSQL store
+// lives in a schema of its own that is dropped then
+func Open(t *testing.T, driver string) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	dsn := ":memory:"
+	if driver == sqldb.DriverPostgres {
+		dsn = postgresSchema(t)
+	}
+
+	db, err := sqldb.Open(ctx, driver, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// postgresSchema creates a schema in the database of PostgresDSNEnv and returns a DSN
+// working in it
+func postgresSchema(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skip(PostgresDSNEnv + " is not set")
+	}
+
+	admin, err := sql.Open(sqldb.DriverPostgres, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "test_" + primitive.NewObjectID().Hex()
+	_, err = admin.ExecContext(ctx, `CREATE SCHEMA `+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.ExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`) })
+
+	// search_path is a runtime parameter of every connection the DSN opens
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,79 @@
+// Package tracing builds the OpenTelemetry tracer provider of both binaries and the
+// helpers services use to record spans
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"services/internal/config"
+	"services/internal/core/health"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Span attribute keys, matching the log attribute keys
+const (
+	KeyUserID     = "nuky.user_id"
+	KeyProvinceID = "nuky.province_id"
+	KeyRound      = "nuky.round"
+	KeyLobbyID    = "nuky.lobby_id"
+)
+
+// Setup returns the tracer provider for the given exporter and a func flushing the
+// spans left and stopping it. Spans are written to stdout for the stdout exporter
+func Setup(ctx context.Context, exporter, serviceName string, stdout io.Writer) (trace.TracerProvider, func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+
+	switch exporter {
+	case config.TraceExporterNone:
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case config.TraceExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+	case config.TraceExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("trace exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(health.ReadVersion().Version),
+		)),
+	)
+
+	return tp, tp.Shutdown, nil
+}
+
+// Tracer returns the tracer services record their spans with, tp may be nil
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+
+	return tp.Tracer("services")
+}
+
+// Fail marks span failed with err, a nil err is ignored
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
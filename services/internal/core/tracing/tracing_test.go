@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"services/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup_None(t *testing.T) {
+	tp, shutdown, err := Setup(context.Background(), config.TraceExporterNone, "test", nil)
+	require.NoError(t, err)
+
+	_, span := Tracer(tp).Start(context.Background(), "ignored")
+	span.End()
+	assert.False(t, span.SpanContext().IsValid())
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestSetup_Stdout(t *testing.T) {
+	var buf bytes.Buffer
+	tp, shutdown, err := Setup(context.Background(), config.TraceExporterStdout, "nuky-test", &buf)
+	require.NoError(t, err)
+
+	_, span := Tracer(tp).Start(context.Background(), "NukeTimer.Round")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, buf.String(), `"Name":"NukeTimer.Round"`)
+	assert.Contains(t, buf.String(), "nuky-test")
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, _, err := Setup(context.Background(), "jaeger", "test", nil)
+	assert.Error(t, err)
+}
+
+func TestFail(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := Tracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	Fail(ok, nil)
+	ok.End()
+
+	_, failed := tracer.Start(context.Background(), "failed")
+	Fail(failed, errors.New("boom"))
+	failed.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "boom", spans[1].Status().Description)
+}
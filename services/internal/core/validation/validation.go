@@ -0,0 +1,230 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/core/response"
+)
+
+// MaxBodyBytes is the largest request body accepted by DecodeJSON
+const MaxBodyBytes = 16 << 10
+
+// Password policy limits, bcrypt ignores everything after 72 bytes
+const (
+	PasswordMinLength = 8
+	PasswordMaxLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,20}$`)
+
+// FieldError describes why a single field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is returned when one or more fields are invalid
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, len(fe))
+	for i, e := range fe {
+		messages[i] = e.Field + ": " + e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ErrBodyTooLarge is returned when the request body exceeds MaxBodyBytes
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// MalformedError is returned when the body isn't a single valid JSON object of the expected shape
+type MalformedError struct {
+	Message string
+	Field   string
+}
+
+func (me *MalformedError) Error() string {
+	return me.Message
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields with their JSON names, the client doesn't know the Go ones
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return isStrongPassword(fl.Field().String())
+	})
+	v.RegisterValidation("objectid", func(fl validator.FieldLevel) bool {
+		return primitive.IsValidObjectID(fl.Field().String())
+	})
+
+	return v
+}
+
+// Struct validates v against its `validate` struct tags and returns FieldErrors on failure
+func Struct(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	fieldErrors := make(FieldErrors, 0, len(validationErrors))
+	for _, e := range validationErrors {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   e.Field(),
+			Rule:    e.Tag(),
+			Message: message(e),
+		})
+	}
+
+	return fieldErrors
+}
+
+// DecodeJSON reads a size limited JSON body into dst, rejecting unknown fields and
+// trailing data, then validates dst
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return ErrBodyTooLarge
+		}
+		return &MalformedError{Message: "Request body must contain a single JSON object"}
+	}
+
+	return Struct(dst)
+}
+
+// WriteError answers a request whose body failed DecodeJSON
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors FieldErrors
+	var malformedError *MalformedError
+
+	switch {
+	case errors.As(err, &fieldErrors):
+		response.ErrorWithDetails(w, r, http.StatusBadRequest, response.CodeValidationFailed, "Request validation failed", fieldErrors)
+	case errors.Is(err, ErrBodyTooLarge):
+		response.Error(w, r, http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", MaxBodyBytes))
+	case errors.As(err, &malformedError) && malformedError.Field != "":
+		response.ErrorWithDetails(w, r, http.StatusBadRequest, response.CodeInvalidRequest, malformedError.Message, FieldErrors{{
+			Field:   malformedError.Field,
+			Rule:    "json",
+			Message: malformedError.Message,
+		}})
+	default:
+		response.Error(w, r, http.StatusBadRequest, response.CodeInvalidRequest, "Invalid request body")
+	}
+}
+
+// --------------------------------------------------------------------
+
+func decodeError(err error) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return ErrBodyTooLarge
+	case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+		return &MalformedError{Message: "Request body contains malformed JSON"}
+	case errors.As(err, &typeError):
+		return &MalformedError{Message: fmt.Sprintf("Field %q must be of type %s", typeError.Field, typeError.Type), Field: typeError.Field}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &MalformedError{Message: fmt.Sprintf("Unknown field %q", field), Field: field}
+	case errors.Is(err, io.EOF):
+		return &MalformedError{Message: "Request body must not be empty"}
+	default:
+		return &MalformedError{Message: "Invalid request body"}
+	}
+}
+
+func message(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "max":
+		return "must be at most " + e.Param() + unit(e)
+	case "min":
+		return "must be at least " + e.Param() + unit(e)
+	case "username":
+		return "must be 3-20 characters of letters, digits or underscores"
+	case "password":
+		return fmt.Sprintf("must be %d-%d characters and contain at least one letter and one digit", PasswordMinLength, PasswordMaxLength)
+	case "objectid":
+		return "must be a valid 24 character hex ID"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(e.Param(), " ", ", ")
+	default:
+		return "is invalid"
+	}
+}
+
+// unit is what the bound of a min or max rule counts, nothing for numbers
+func unit(e validator.FieldError) string {
+	switch e.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return " items"
+	case reflect.String:
+		return " characters"
+	default:
+		return ""
+	}
+}
+
+func isStrongPassword(password string) bool {
+	if len(password) < PasswordMinLength || len(password) > PasswordMaxLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+
+	return hasLetter && hasDigit
+}
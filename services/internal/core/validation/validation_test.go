@@ -0,0 +1,117 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	auth_model "services/internal/auth/model"
+	province_model "services/internal/province/model"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func fieldsOf(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	fieldErrors, ok := err.(FieldErrors)
+	if !assert.True(t, ok, "expected FieldErrors, got %v", err) {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, e := range fieldErrors {
+		fields[e.Field] = e.Rule
+	}
+	return fields
+}
+
+func TestStruct_RegisterRequest(t *testing.T) {
+	valid := auth_model.RegisterRequest{Username: "nuke_lord", Email: "lord@nuky.io", Password: "hunter22"}
+	assert.NoError(t, Struct(valid))
+
+	err := Struct(auth_model.RegisterRequest{Username: "a b", Email: "not-an-email", Password: "short"})
+	assert.Equal(t, map[string]string{
+		"username": "username",
+		"email":    "email",
+		"password": "password",
+	}, fieldsOf(t, err))
+
+	err = Struct(auth_model.RegisterRequest{Username: strings.Repeat("a", 21), Email: "lord@nuky.io", Password: "onlyletters"})
+	assert.Equal(t, map[string]string{"username": "username", "password": "password"}, fieldsOf(t, err))
+}
+
+func TestStruct_LoginRequest(t *testing.T) {
+	assert.NoError(t, Struct(auth_model.LoginRequest{Email: ptr("lord@nuky.io"), Password: "x"}))
+	assert.NoError(t, Struct(auth_model.LoginRequest{Username: ptr("nuke_lord"), Password: "x"}))
+
+	err := Struct(auth_model.LoginRequest{})
+	assert.Equal(t, map[string]string{
+		"username": "required_without",
+		"email":    "required_without",
+		"password": "required",
+	}, fieldsOf(t, err))
+}
+
+func TestStruct_ProvinceRequests(t *testing.T) {
+	assert.NoError(t, Struct(province_model.AttackProvinceRequest{ProvinceID: "6630f1d2c3b4a5968778695a"}))
+
+	err := Struct(province_model.SupportProvinceRequest{ProvinceID: "invalidid"})
+	assert.Equal(t, map[string]string{"province_id": "objectid"}, fieldsOf(t, err))
+}
+
+func decode(body string) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest(http.MethodPost, "/api/province/attack", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	var dst province_model.AttackProvinceRequest
+	err := DecodeJSON(rr, req, &dst)
+	if err != nil {
+		WriteError(rr, req, err)
+	}
+	return rr, err
+}
+
+func TestDecodeJSON(t *testing.T) {
+	rr, err := decode(`{"province_id": "6630f1d2c3b4a5968778695a"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown field", `{"province_id": "6630f1d2c3b4a5968778695a", "weight": 100}`, http.StatusBadRequest, "invalid_request"},
+		{"malformed", `{"province_id": `, http.StatusBadRequest, "invalid_request"},
+		{"trailing data", `{"province_id": "6630f1d2c3b4a5968778695a"} {}`, http.StatusBadRequest, "invalid_request"},
+		{"wrong type", `{"province_id": 5}`, http.StatusBadRequest, "invalid_request"},
+		{"empty", ``, http.StatusBadRequest, "invalid_request"},
+		{"invalid id", `{"province_id": "invalidid"}`, http.StatusBadRequest, "validation_failed"},
+		{"too large", `{"province_id": "` + strings.Repeat("a", MaxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "payload_too_large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, err := decode(tt.body)
+			assert.Error(t, err)
+			assert.Equal(t, tt.status, rr.Code)
+
+			var body struct {
+				Error struct {
+					Code    string       `json:"code"`
+					Details []FieldError `json:"details"`
+				} `json:"error"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tt.code, body.Error.Code)
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Package game holds the tunable rules of a game, read from an optional JSON file so
+// organizers can change them without a release.
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Move kinds
+const (
+	MoveAttack  = "attack"
+	MoveSupport = "support"
+)
+
+// Modifiers a move's weight can be changed by, recorded with the move. Items record
+// their own name
+const (
+	ModifierStreak = "streak"
+	ModifierFallen = "fallen"
+	ModifierAlly   = "ally"
+)
+
+// Items of DefaultItems
+const (
+	ItemDoubleStrike = "double_strike"
+	ItemShield       = "shield"
+)
+
+// Rules are the rules of a game, the zero value isn't valid, start from DefaultRules
+type Rules struct {
+	Moves     MoveRules     `json:"moves"`
+	Shields   ShieldRules   `json:"shields"`
+	Attacks   AttackRules   `json:"attacks"`
+	Nukes     NukeRules     `json:"nukes"`
+	Scoring   ScoringRules  `json:"scoring"`
+	Carry     CarryRules    `json:"carry"`
+	Alliances AllianceRules `json:"alliances"`
+}
+
+// MoveRules decide how much a single move counts
+type MoveRules struct {
+	BaseWeight int `json:"base_weight"` // weight of a move without modifiers
+
+	// A player moving in consecutive rounds gets Bonus for every Rounds rounds of the
+	// streak, up to Max
+	Streak StreakRule `json:"streak"`
+
+	// Subtracted from the moves of a player whose home province was nuked, a move
+	// never weighs less than 1
+	FallenPenalty int `json:"fallen_penalty"`
+
+	// Multiplies the supports of a province allied to the player's home province
+	AllyMultiplier int `json:"ally_multiplier"`
+
+	// Items a player may use on a move, by name
+	Items map[string]Item `json:"items"`
+}
+
+type StreakRule struct {
+	Rounds int `json:"rounds"`
+	Bonus  int `json:"bonus"`
+	Max    int `json:"max"`
+}
+
+// Item multiplies the weight of a move, a limited number of times per round
+type Item struct {
+	Moves      []string `json:"moves"` // move kinds it can be used on
+	Multiplier int      `json:"multiplier"`
+	PerRound   int      `json:"per_round"`
+}
+
+// ShieldRules decide when a province's community shields it from the next nuke
+type ShieldRules struct {
+	// Supports a province needs within a round to be skipped by the nuke ending it,
+	// 0 turns shields off
+	SupportThreshold int `json:"support_threshold"`
+}
+
+// AttackRules decide which provinces a player may attack
+type AttackRules struct {
+	// Only provinces adjacent to the player's home province or to a province they
+	// supported this round, by the neighbor lists of the provinces. Anonymous players
+	// can't attack at all then
+	AdjacentOnly bool `json:"adjacent_only"`
+}
+
+// NukeRules decide how many provinces a round nukes, tuning how long a game lasts
+type NukeRules struct {
+	PerRound int `json:"per_round"` // fixed number of provinces nuked every round
+
+	// When above 0, a round nukes one province for every LivingPerNuke living ones,
+	// rounded up, instead of PerRound
+	LivingPerNuke int `json:"living_per_nuke"`
+}
+
+// Count tells how many provinces a round nukes out of living ones. The last living
+// province is never nuked, it wins the game
+func (n NukeRules) Count(living int) int {
+	count := n.PerRound
+	if n.LivingPerNuke > 0 {
+		count = (living + n.LivingPerNuke - 1) / n.LivingPerNuke
+	}
+
+	return max(min(count, living-1), 0)
+}
+
+// AllianceRules bound the alliances provinces may form
+type AllianceRules struct {
+	MaxMembers int `json:"max_members"` // provinces in one alliance, 0 for no limit
+}
+
+// CarryRules decide how much pressure a province keeps from one round to the next
+type CarryRules struct {
+	// Share of a province's attacks and supports, carried ones included, that counts
+	// again in the next round, 0 to 1. 0 wipes the counts at every nuke
+	Decay float64 `json:"decay"`
+}
+
+// DefaultRules are the rules of the global game. A move without modifiers counts 1, and
+// streak bonuses, items and shields are off, a rules file turns them on. DefaultItems
+// are the items such a file can list
+func DefaultRules() Rules {
+	return Rules{
+		Moves: MoveRules{
+			BaseWeight:     1,
+			Streak:         StreakRule{Rounds: 3, Max: 2},
+			FallenPenalty:  0,
+			AllyMultiplier: 2,
+		},
+		Nukes:     NukeRules{PerRound: 1},
+		Scoring:   ScoringRules{Rule: ScoringDifference, Decay: 0.5},
+		Alliances: AllianceRules{MaxMembers: 5},
+	}
+}
+
+// DefaultItems are a double strike for attacks and a shield for supports, each doubling
+// a move once per round
+func DefaultItems() map[string]Item {
+	return map[string]Item{
+		ItemDoubleStrike: {Moves: []string{MoveAttack}, Multiplier: 2, PerRound: 1},
+		ItemShield:       {Moves: []string{MoveSupport}, Multiplier: 2, PerRound: 1},
+	}
+}
+
+// LoadRules reads rules from a JSON file on top of DefaultRules, an empty path returns
+// the defaults
+func LoadRules(path string) (Rules, error) {
+	rules := DefaultRules()
+	if path == "" {
+		return rules, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return Rules{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := rules.Validate(); err != nil {
+		return Rules{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// Validate reports every rule that can't be played with
+func (r Rules) Validate() error {
+	var errs []error
+	m := r.Moves
+
+	if m.BaseWeight < 1 {
+		errs = append(errs, errors.New("moves.base_weight must be at least 1"))
+	}
+	if m.Streak.Rounds < 1 || m.Streak.Bonus < 0 || m.Streak.Max < 0 {
+		errs = append(errs, errors.New("moves.streak needs rounds of at least 1 and a non negative bonus and max"))
+	}
+	if m.FallenPenalty < 0 {
+		errs = append(errs, errors.New("moves.fallen_penalty must not be negative"))
+	}
+	if m.AllyMultiplier < 1 {
+		errs = append(errs, errors.New("moves.ally_multiplier must be at least 1"))
+	}
+
+	for name, item := range m.Items {
+		if len(item.Moves) == 0 || slices.ContainsFunc(item.Moves, func(kind string) bool { return kind != MoveAttack && kind != MoveSupport }) {
+			errs = append(errs, fmt.Errorf("moves.items.%s.moves must list %s and/or %s", name, MoveAttack, MoveSupport))
+		}
+		if item.Multiplier < 1 || item.PerRound < 1 {
+			errs = append(errs, fmt.Errorf("moves.items.%s needs a multiplier and per_round of at least 1", name))
+		}
+	}
+
+	if r.Nukes.LivingPerNuke < 0 {
+		errs = append(errs, errors.New("nukes.living_per_nuke must not be negative"))
+	} else if r.Nukes.LivingPerNuke == 0 && r.Nukes.PerRound < 1 {
+		errs = append(errs, errors.New("nukes.per_round must be at least 1 unless nukes.living_per_nuke is set"))
+	}
+
+	if err := r.Scoring.validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if r.Shields.SupportThreshold < 0 {
+		errs = append(errs, errors.New("shields.support_threshold must not be negative"))
+	}
+
+	if r.Alliances.MaxMembers < 0 || r.Alliances.MaxMembers == 1 {
+		errs = append(errs, errors.New("alliances.max_members must be at least 2, or 0 for no limit"))
+	}
+
+	if r.Carry.Decay < 0 || r.Carry.Decay > 1 {
+		errs = append(errs, fmt.Errorf("carry.decay must be between 0 and 1, got %g", r.Carry.Decay))
+	}
+
+	return errors.Join(errs...)
+}
+
+// --------------------------------------------------------------------
+
+// Errors of Weigh, the move is rejected
+var (
+	ErrUnknownItem      = errors.New("unknown item")
+	ErrItemNotForMove   = errors.New("item can't be used on this move")
+	ErrItemUsedUp       = errors.New("item already used up this round")
+	ErrItemNeedsAccount = errors.New("items need a logged in player")
+)
+
+// Player is what a move's weight depends on
+type Player struct {
+	Known     bool           // false for anonymous moves, which get the base weight
+	Streak    int            // consecutive rounds moved, this one included
+	Fallen    bool           // home province nuked
+	ItemsUsed map[string]int // uses of each item this round
+	Ally      bool           // the move is on a province allied to the player's home province
+}
+
+// Weight is how much a move counts and why
+type Weight struct {
+	Value     int
+	Modifiers []string
+}
+
+// Weigh tells how much a move of the given kind counts for player, using item if not
+// empty
+func (m MoveRules) Weigh(kind, item string, player Player) (Weight, error) {
+	w := Weight{Value: m.BaseWeight, Modifiers: []string{}}
+
+	if item != "" {
+		rule, ok := m.Items[item]
+		switch {
+		case !ok:
+			return Weight{}, ErrUnknownItem
+		case !player.Known:
+			return Weight{}, ErrItemNeedsAccount
+		case !slices.Contains(rule.Moves, kind):
+			return Weight{}, ErrItemNotForMove
+		case player.ItemsUsed[item] >= rule.PerRound:
+			return Weight{}, ErrItemUsedUp
+		}
+	}
+
+	if !player.Known {
+		return w, nil
+	}
+
+	if bonus := min(player.Streak/m.Streak.Rounds*m.Streak.Bonus, m.Streak.Max); bonus > 0 {
+		w.Value += bonus
+		w.Modifiers = append(w.Modifiers, ModifierStreak)
+	}
+
+	if player.Fallen && m.FallenPenalty > 0 {
+		w.Value = max(w.Value-m.FallenPenalty, 1)
+		w.Modifiers = append(w.Modifiers, ModifierFallen)
+	}
+
+	if kind == MoveSupport && player.Ally && m.AllyMultiplier > 1 {
+		w.Value *= m.AllyMultiplier
+		w.Modifiers = append(w.Modifiers, ModifierAlly)
+	}
+
+	if item != "" {
+		w.Value *= m.Items[item].Multiplier
+		w.Modifiers = append(w.Modifiers, item)
+	}
+
+	return w, nil
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeigh(t *testing.T) {
+	rules := DefaultRules().Moves
+	rules.Streak.Bonus = 1
+	rules.FallenPenalty = 1
+	rules.Items = DefaultItems()
+
+	cases := []struct {
+		name   string
+		kind   string
+		item   string
+		player Player
+		want   Weight
+		err    error
+	}{
+		{"anonymous", MoveAttack, "", Player{}, Weight{1, []string{}}, nil},
+		{"first round", MoveAttack, "", Player{Known: true, Streak: 1}, Weight{1, []string{}}, nil},
+		{"streak", MoveSupport, "", Player{Known: true, Streak: 3}, Weight{2, []string{ModifierStreak}}, nil},
+		{"streak capped", MoveSupport, "", Player{Known: true, Streak: 30}, Weight{3, []string{ModifierStreak}}, nil},
+		{"fallen", MoveAttack, "", Player{Known: true, Streak: 6, Fallen: true}, Weight{2, []string{ModifierStreak, ModifierFallen}}, nil},
+		{"fallen never below one", MoveAttack, "", Player{Known: true, Fallen: true}, Weight{1, []string{ModifierFallen}}, nil},
+		{"double strike", MoveAttack, ItemDoubleStrike, Player{Known: true, Streak: 3}, Weight{4, []string{ModifierStreak, ItemDoubleStrike}}, nil},
+		{"shield", MoveSupport, ItemShield, Player{Known: true}, Weight{2, []string{ItemShield}}, nil},
+		{"ally support", MoveSupport, ItemShield, Player{Known: true, Ally: true}, Weight{4, []string{ModifierAlly, ItemShield}}, nil},
+		{"ally attack", MoveAttack, "", Player{Known: true, Ally: true}, Weight{1, []string{}}, nil},
+		{"unknown item", MoveAttack, "nuke", Player{Known: true}, Weight{}, ErrUnknownItem},
+		{"item on the wrong move", MoveSupport, ItemDoubleStrike, Player{Known: true}, Weight{}, ErrItemNotForMove},
+		{"item used up", MoveAttack, ItemDoubleStrike, Player{Known: true, ItemsUsed: map[string]int{ItemDoubleStrike: 1}}, Weight{}, ErrItemUsedUp},
+		{"item without account", MoveAttack, ItemDoubleStrike, Player{}, Weight{}, ErrItemNeedsAccount},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rules.Weigh(tc.kind, tc.item, tc.player)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDefaultRules_WeighEveryMoveOne(t *testing.T) {
+	rules := DefaultRules()
+
+	for _, player := range []Player{{}, {Known: true, Streak: 30}, {Known: true, Streak: 6, Fallen: true}} {
+		for _, kind := range []string{MoveAttack, MoveSupport} {
+			got, err := rules.Moves.Weigh(kind, "", player)
+			require.NoError(t, err)
+			assert.Equal(t, Weight{1, []string{}}, got)
+		}
+	}
+
+	_, err := rules.Moves.Weigh(MoveAttack, ItemDoubleStrike, Player{Known: true})
+	assert.ErrorIs(t, err, ErrUnknownItem)
+	assert.Zero(t, rules.Shields.SupportThreshold)
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := LoadRules("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRules(), rules)
+
+	file := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"moves": {"base_weight": 2, "items": {"nuke": {"moves": ["attack"], "multiplier": 10, "per_round": 1}}}}`), 0o600))
+
+	rules, err = LoadRules(file)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rules.Moves.BaseWeight)
+	assert.Equal(t, DefaultRules().Moves.Streak, rules.Moves.Streak)
+	assert.Contains(t, rules.Moves.Items, "nuke")
+
+	assert.Equal(t, DefaultRules().Shields, rules.Shields)
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"moves": {"base_weight": 0, "items": {"nuke": {"moves": ["bomb"], "multiplier": 0}}}, "shields": {"support_threshold": -1}, "carry": {"decay": 2}}`), 0o600))
+	_, err = LoadRules(file)
+	assert.ErrorContains(t, err, "base_weight")
+	assert.ErrorContains(t, err, "shields.support_threshold")
+	assert.ErrorContains(t, err, "carry.decay")
+	assert.ErrorContains(t, err, "moves.items.nuke.moves")
+	assert.ErrorContains(t, err, "multiplier")
+}
+
+func TestNukeRules_Count(t *testing.T) {
+	fixed := NukeRules{PerRound: 3}
+	assert.Equal(t, 3, fixed.Count(81))
+	assert.Equal(t, 2, fixed.Count(3))
+	assert.Equal(t, 0, fixed.Count(1))
+	assert.Equal(t, 0, fixed.Count(0))
+
+	scaled := NukeRules{PerRound: 1, LivingPerNuke: 10}
+	assert.Equal(t, 9, scaled.Count(81))
+	assert.Equal(t, 1, scaled.Count(10))
+	assert.Equal(t, 1, scaled.Count(2))
+
+	assert.ErrorContains(t, Rules{Moves: DefaultRules().Moves, Nukes: NukeRules{}}.Validate(), "nukes.per_round")
+	assert.NoError(t, Rules{Moves: DefaultRules().Moves, Nukes: NukeRules{LivingPerNuke: 20}}.Validate())
+}
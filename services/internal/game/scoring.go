@@ -0,0 +1,94 @@
+package game
+
+import "fmt"
+
+// Scoring rules selectable by name in the game rules
+const (
+	ScoringDifference    = "difference"
+	ScoringRatio         = "ratio"
+	ScoringAttackShare   = "attack_share"
+	ScoringWeightedDecay = "weighted_decay"
+)
+
+// Tally is what a province is scored on, counts carried from past rounds included
+type Tally struct {
+	Attacks       float64
+	Supports      float64
+	PreviousScore float64 // score at the end of the previous round
+}
+
+// ScoringRule scores a province for the nuke, the highest score is nuked first
+type ScoringRule interface {
+	Score(t Tally) float64
+}
+
+// ScoringRules choose the ScoringRule of a game
+type ScoringRules struct {
+	Rule  string  `json:"rule"`  // one of the Scoring* names, empty is difference
+	Decay float64 `json:"decay"` // share of the previous score weighted_decay carries, 0 to 1
+}
+
+// ScoringRule returns the rule chosen by name, Difference if the name is unknown
+func (s ScoringRules) ScoringRule() ScoringRule {
+	switch s.Rule {
+	case ScoringRatio:
+		return Ratio{}
+	case ScoringAttackShare:
+		return AttackShare{}
+	case ScoringWeightedDecay:
+		return WeightedDecay{Decay: s.Decay}
+	default:
+		return Difference{}
+	}
+}
+
+func (s ScoringRules) validate() error {
+	switch s.Rule {
+	case "", ScoringDifference, ScoringRatio, ScoringAttackShare, ScoringWeightedDecay:
+	default:
+		return fmt.Errorf("scoring.rule must be one of %s, %s, %s, %s, got %q", ScoringDifference, ScoringRatio, ScoringAttackShare, ScoringWeightedDecay, s.Rule)
+	}
+
+	if s.Decay < 0 || s.Decay > 1 {
+		return fmt.Errorf("scoring.decay must be between 0 and 1, got %g", s.Decay)
+	}
+
+	return nil
+}
+
+// Difference scores attacks - supports, the original rule
+type Difference struct{}
+
+func (Difference) Score(t Tally) float64 {
+	return t.Attacks - t.Supports
+}
+
+// Ratio scores attacks per support, both smoothed by one so a province without
+// supports doesn't score infinity
+type Ratio struct{}
+
+func (Ratio) Score(t Tally) float64 {
+	return (t.Attacks + 1) / (t.Supports + 1)
+}
+
+// AttackShare scores the share of attacks among all moves, 0 without moves
+type AttackShare struct{}
+
+func (AttackShare) Score(t Tally) float64 {
+	total := t.Attacks + t.Supports
+	if total == 0 {
+		return 0
+	}
+
+	return t.Attacks / total
+}
+
+// WeightedDecay scores attacks - supports plus a decayed share of the previous score,
+// so past rounds keep counting less and less
+type WeightedDecay struct {
+	Decay float64
+}
+
+func (w WeightedDecay) Score(t Tally) float64 {
+	return t.Attacks - t.Supports + w.Decay*t.PreviousScore
+}
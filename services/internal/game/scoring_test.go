@@ -0,0 +1,33 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoringRules(t *testing.T) {
+	tally := Tally{Attacks: 6, Supports: 2, PreviousScore: 10}
+
+	cases := []struct {
+		rules ScoringRules
+		want  float64
+	}{
+		{ScoringRules{}, 4},
+		{ScoringRules{Rule: ScoringDifference}, 4},
+		{ScoringRules{Rule: ScoringRatio}, 7.0 / 3},
+		{ScoringRules{Rule: ScoringAttackShare}, 0.75},
+		{ScoringRules{Rule: ScoringWeightedDecay, Decay: 0.5}, 9},
+	}
+	for _, tc := range cases {
+		t.Run(tc.rules.Rule, func(t *testing.T) {
+			assert.InDelta(t, tc.want, tc.rules.ScoringRule().Score(tally), 1e-9)
+		})
+	}
+
+	assert.Zero(t, AttackShare{}.Score(Tally{}))
+	assert.Equal(t, 1.0, Ratio{}.Score(Tally{}))
+
+	assert.ErrorContains(t, ScoringRules{Rule: "elo"}.validate(), "scoring.rule")
+	assert.ErrorContains(t, ScoringRules{Rule: ScoringWeightedDecay, Decay: 1.5}.validate(), "scoring.decay")
+}
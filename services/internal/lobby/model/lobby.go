@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Lobby is a private game on its own provinces, for a friend group or a classroom.
+// Players join it with its invite code and it is nuked on its own schedule
+type Lobby struct {
+	ID               primitive.ObjectID `json:"ID" bson:"_id,omitempty"`
+	Name             string             `json:"name" bson:"name"`
+	InviteCode       string             `json:"invite_code" bson:"inviteCode"` // shared by the members, joins the lobby
+	OwnerID          string             `json:"owner_id" bson:"ownerID"`       // user who created it
+	PlayerIDs        []string           `json:"player_ids" bson:"playerIDs"`   // members, the owner first
+	MaxPlayers       int                `json:"max_players" bson:"maxPlayers"`
+	NukeEveryMinutes int                `json:"nuke_every_minutes" bson:"nukeEveryMinutes"` // length of a round
+	StartedAt        time.Time          `json:"started_at" bson:"startedAt"`
+	NextNukeAt       time.Time          `json:"next_nuke_at" bson:"nextNukeAt"`
+	Bots             []LobbyBot         `json:"bots,omitempty" bson:"bots,omitempty"`              // bot players the owner seated, among PlayerIDs
+	FinishedAt       *time.Time         `json:"finished_at,omitempty" bson:"finishedAt,omitempty"` // set once a single province is left, nil while the lobby is played
+}
+
+// LobbyBot is a bot player seated in a lobby, it plays there for as long as the lobby lasts
+type LobbyBot struct {
+	UserID   string `json:"user_id" bson:"userID"`
+	Username string `json:"username" bson:"username"`
+	Strategy string `json:"strategy" bson:"strategy"` // one of the bot.Strategy* strategies
+}
+
+// Finished tells whether the lobby is over, it is neither nuked nor played any more
+func (l Lobby) Finished() bool {
+	return l.FinishedAt != nil
+}
+
+// RoundLength is how long a round of the lobby lasts
+func (l Lobby) RoundLength() time.Duration {
+	return time.Duration(l.NukeEveryMinutes) * time.Minute
+}
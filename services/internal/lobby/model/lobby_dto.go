@@ -0,0 +1,47 @@
+package model
+
+// --------------------------------------------------------------------
+
+type GetLobbiesResponse struct {
+	Lobbies []Lobby `json:"lobbies"`
+}
+
+type GetLobbyRequest struct {
+	ID string `json:"id" validate:"required,objectid"`
+}
+
+type LobbyResponse struct {
+	Lobby Lobby `json:"lobby"`
+}
+
+// --------------------------------------------------------------------
+
+// CreateLobbyRequest opens a lobby on either a subset of the public map, by province ID,
+// or a custom list of provinces, not both
+type CreateLobbyRequest struct {
+	Name             string        `json:"name" validate:"required,min=3,max=40"`
+	MaxPlayers       int           `json:"max_players" validate:"required,min=2,max=100"`
+	NukeEveryMinutes int           `json:"nuke_every_minutes" validate:"required,min=5,max=10080"`
+	ProvinceIDs      []string      `json:"province_ids,omitempty" validate:"required_without=Provinces,omitempty,min=2,max=200,unique,dive,objectid"`
+	Provinces        []NewProvince `json:"provinces,omitempty" validate:"required_without=ProvinceIDs,omitempty,min=2,max=200,dive"`
+}
+
+// NewProvince is a province of a custom lobby map
+type NewProvince struct {
+	ProvinceName     string `json:"province_name" validate:"required,max=40"`
+	ProvinceColorHex string `json:"province_color_hex" validate:"required,hexcolor"`
+}
+
+// --------------------------------------------------------------------
+
+type JoinLobbyRequest struct {
+	InviteCode string `json:"invite_code" validate:"required,len=8,alphanum"`
+}
+
+// --------------------------------------------------------------------
+
+// AddBotsRequest seats bot players in a lobby, all playing by the same strategy
+type AddBotsRequest struct {
+	Strategy string `json:"strategy" validate:"required,oneof=random defend-home pile-on-leader"`
+	Count    int    `json:"count" validate:"required,min=1,max=10"`
+}
@@ -0,0 +1,246 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"services/internal/core/logging"
+	"services/internal/lobby/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotFound is returned by every lobby repo implementation when a lobby doesn't exist
+var ErrNotFound = errors.New("lobby not found")
+
+// ErrFull is returned when a player joins a lobby that reached its player cap
+var ErrFull = errors.New("lobby is full")
+
+// LobbyRepo keeps the lobbies, one document per lobby listing its players. The provinces
+// of each lobby live in a collection of their own
+type LobbyRepo struct {
+	collection *mongo.Collection
+}
+
+// NewLobbyRepo creates a new lobby repository
+func NewLobbyRepo(collection *mongo.Collection) *LobbyRepo {
+	return &LobbyRepo{
+		collection: collection,
+	}
+}
+
+// GetByID retrieves a single lobby, ErrNotFound if it doesn't exist
+func (lr *LobbyRepo) GetByID(ctx context.Context, id string) (*model.Lobby, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return lr.findOne(ctx, "GetByID", bson.M{"_id": objectID})
+}
+
+// GetByInviteCode retrieves the lobby an invite code joins, ErrNotFound if none does
+func (lr *LobbyRepo) GetByInviteCode(ctx context.Context, code string) (*model.Lobby, error) {
+	return lr.findOne(ctx, "GetByInviteCode", bson.M{"inviteCode": code})
+}
+
+// GetByPlayer retrieves the lobbies a user is a member of, oldest first
+func (lr *LobbyRepo) GetByPlayer(ctx context.Context, userID string) ([]model.Lobby, error) {
+	return lr.find(ctx, "GetByPlayer", bson.M{"playerIDs": userID})
+}
+
+// GetDue retrieves the unfinished lobbies whose next nuke is due at now
+func (lr *LobbyRepo) GetDue(ctx context.Context, now time.Time) ([]model.Lobby, error) {
+	return lr.find(ctx, "GetDue", bson.M{"nextNukeAt": bson.M{"$lte": now}, "finishedAt": nil})
+}
+
+// InsertLobby adds a lobby, one without an ID gets one
+func (lr *LobbyRepo) InsertLobby(ctx context.Context, lobby *model.Lobby) error {
+	if lobby.ID.IsZero() {
+		lobby.ID = primitive.NewObjectID()
+	}
+
+	_, err := lr.collection.InsertOne(ctx, lobby)
+	return lr.fail(ctx, "InsertLobby", err)
+}
+
+// DeleteLobby removes a lobby, a missing one is no error
+func (lr *LobbyRepo) DeleteLobby(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = lr.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	return lr.fail(ctx, "DeleteLobby", err)
+}
+
+// AddPlayer makes a user a member of a lobby, ErrFull once it reached its player cap.
+// Joining a lobby twice changes nothing. The cap is checked by the update itself, two
+// players can't take the last seat
+func (lr *LobbyRepo) AddPlayer(ctx context.Context, id, userID string) error {
+	return lr.seat(ctx, "AddPlayer", id, userID, bson.M{"playerIDs": userID})
+}
+
+// AddBot seats a bot player in a lobby like AddPlayer and lists it among its bots
+func (lr *LobbyRepo) AddBot(ctx context.Context, id string, bot model.LobbyBot) error {
+	return lr.seat(ctx, "AddBot", id, bot.UserID, bson.M{"playerIDs": bot.UserID, "bots": bot})
+}
+
+// GetWithBots retrieves the unfinished lobbies with bots seated, oldest first
+func (lr *LobbyRepo) GetWithBots(ctx context.Context) ([]model.Lobby, error) {
+	return lr.find(ctx, "GetWithBots", bson.M{"bots.0": bson.M{"$exists": true}, "finishedAt": nil})
+}
+
+// seat adds to the sets of a lobby while it has a seat for userID, the player is among
+// the sets added
+func (lr *LobbyRepo) seat(ctx context.Context, operation, id, userID string, sets bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": objectID,
+		"$or": bson.A{
+			bson.M{"playerIDs": userID},
+			bson.M{"$expr": bson.M{"$lt": bson.A{bson.M{"$size": "$playerIDs"}, "$maxPlayers"}}},
+		},
+	}
+	result, err := lr.collection.UpdateOne(ctx, filter, bson.M{"$addToSet": sets})
+	if err != nil {
+		return lr.fail(ctx, operation, err)
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	// Nothing matched, either there is no such lobby or it is full
+	if _, err := lr.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return ErrFull
+}
+
+// ClaimNuke moves the next nuke of a lobby from due to next and tells whether this call
+// did, only one of several instances running the lobby nukes gets to nuke a round
+func (lr *LobbyRepo) ClaimNuke(ctx context.Context, id string, due, next time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := lr.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "nextNukeAt": due},
+		bson.M{"$set": bson.M{"nextNukeAt": next}})
+	if err != nil {
+		return false, lr.fail(ctx, "ClaimNuke", err)
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// LastMove returns when a player last moved in a lobby, the zero time if they never did.
+// The last moves are kept in the lobby's document by user ID, model.Lobby leaves them out
+func (lr *LobbyRepo) LastMove(ctx context.Context, id, userID string) (time.Time, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var moves struct {
+		LastMoves map[string]time.Time `bson:"lastMoves"`
+	}
+	err = lr.collection.FindOne(ctx, bson.M{"_id": objectID}, options.FindOne().SetProjection(bson.M{"lastMoves." + userID: 1})).Decode(&moves)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, lr.fail(ctx, "LastMove", err)
+	}
+
+	return moves.LastMoves[userID], nil
+}
+
+// SetLastMove moves the last move of a player in a lobby from last to next and tells
+// whether this call did. A zero next forgets the move
+func (lr *LobbyRepo) SetLastMove(ctx context.Context, id, userID string, last, next time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	field := "lastMoves." + userID
+	filter := bson.M{"_id": objectID, field: last}
+	if last.IsZero() {
+		filter[field] = bson.M{"$exists": false}
+	}
+	update := bson.M{"$set": bson.M{field: next}}
+	if next.IsZero() {
+		update = bson.M{"$unset": bson.M{field: ""}}
+	}
+
+	result, err := lr.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, lr.fail(ctx, "SetLastMove", err)
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// FinishLobby marks a lobby over at at, a finished lobby keeps the time it finished at
+func (lr *LobbyRepo) FinishLobby(ctx context.Context, id string, at time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = lr.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "finishedAt": nil},
+		bson.M{"$set": bson.M{"finishedAt": at}})
+	return lr.fail(ctx, "FinishLobby", err)
+}
+
+func (lr *LobbyRepo) find(ctx context.Context, operation string, filter bson.M) ([]model.Lobby, error) {
+	cursor, err := lr.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "startedAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, lr.fail(ctx, operation, err)
+	}
+	defer cursor.Close(ctx)
+
+	lobbies := []model.Lobby{}
+	if err := cursor.All(ctx, &lobbies); err != nil {
+		return nil, lr.fail(ctx, operation, err)
+	}
+
+	return lobbies, nil
+}
+
+func (lr *LobbyRepo) findOne(ctx context.Context, operation string, filter bson.M) (*model.Lobby, error) {
+	var lobby model.Lobby
+	err := lr.collection.FindOne(ctx, filter).Decode(&lobby)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, lr.fail(ctx, operation, err)
+	}
+
+	return &lobby, nil
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (lr *LobbyRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Lobby store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"services/internal/lobby/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryLobbyRepo is an in-process LobbyRepo for tests and local runs without MongoDB
+type MemoryLobbyRepo struct {
+	mu      sync.RWMutex
+	lobbies []model.Lobby
+	moves   map[lobbyPlayer]time.Time // last move of each player in each lobby
+}
+
+// lobbyPlayer is a player of a lobby, by their IDs
+type lobbyPlayer struct {
+	lobbyID, userID string
+}
+
+// NewMemoryLobbyRepo creates an empty in-memory lobby repository
+func NewMemoryLobbyRepo() *MemoryLobbyRepo {
+	return &MemoryLobbyRepo{
+		moves: map[lobbyPlayer]time.Time{},
+	}
+}
+
+// GetByID returns a copy of a single lobby, ErrNotFound if it doesn't exist
+func (mlr *MemoryLobbyRepo) GetByID(ctx context.Context, id string) (*model.Lobby, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return mlr.findOne(func(lobby model.Lobby) bool { return lobby.ID == objectID })
+}
+
+// GetByInviteCode returns a copy of the lobby an invite code joins, ErrNotFound if none does
+func (mlr *MemoryLobbyRepo) GetByInviteCode(ctx context.Context, code string) (*model.Lobby, error) {
+	return mlr.findOne(func(lobby model.Lobby) bool { return lobby.InviteCode == code })
+}
+
+// GetByPlayer returns copies of the lobbies a user is a member of, oldest first
+func (mlr *MemoryLobbyRepo) GetByPlayer(ctx context.Context, userID string) ([]model.Lobby, error) {
+	return mlr.find(func(lobby model.Lobby) bool { return slices.Contains(lobby.PlayerIDs, userID) }), nil
+}
+
+// GetDue returns copies of the unfinished lobbies whose next nuke is due at now
+func (mlr *MemoryLobbyRepo) GetDue(ctx context.Context, now time.Time) ([]model.Lobby, error) {
+	return mlr.find(func(lobby model.Lobby) bool { return !lobby.NextNukeAt.After(now) && !lobby.Finished() }), nil
+}
+
+// InsertLobby adds a lobby, one without an ID gets one
+func (mlr *MemoryLobbyRepo) InsertLobby(ctx context.Context, lobby *model.Lobby) error {
+	if lobby.ID.IsZero() {
+		lobby.ID = primitive.NewObjectID()
+	}
+
+	mlr.mu.Lock()
+	defer mlr.mu.Unlock()
+
+	mlr.lobbies = append(mlr.lobbies, cloneLobby(*lobby))
+	return nil
+}
+
+// DeleteLobby removes a lobby with the last moves of its players, a missing one is no
+// error
+func (mlr *MemoryLobbyRepo) DeleteLobby(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	mlr.mu.Lock()
+	defer mlr.mu.Unlock()
+
+	mlr.lobbies = slices.DeleteFunc(mlr.lobbies, func(lobby model.Lobby) bool { return lobby.ID == objectID })
+	for key := range mlr.moves {
+		if key.lobbyID == id {
+			delete(mlr.moves, key)
+		}
+	}
+	return nil
+}
+
+// AddPlayer makes a user a member of a lobby, ErrFull once it reached its player cap.
+// Joining a lobby twice changes nothing
+func (mlr *MemoryLobbyRepo) AddPlayer(ctx context.Context, id, userID string) error {
+	return mlr.seat(id, userID, nil)
+}
+
+// AddBot seats a bot player in a lobby like AddPlayer and lists it among its bots
+func (mlr *MemoryLobbyRepo) AddBot(ctx context.Context, id string, bot model.LobbyBot) error {
+	return mlr.seat(id, bot.UserID, &bot)
+}
+
+// GetWithBots returns copies of the unfinished lobbies with bots seated, oldest first
+func (mlr *MemoryLobbyRepo) GetWithBots(ctx context.Context) ([]model.Lobby, error) {
+	return mlr.find(func(lobby model.Lobby) bool { return len(lobby.Bots) > 0 && !lobby.Finished() }), nil
+}
+
+// seat makes a user a member of a lobby, listing it among its bots unless bot is nil
+func (mlr *MemoryLobbyRepo) seat(id, userID string, bot *model.LobbyBot) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	mlr.mu.Lock()
+	defer mlr.mu.Unlock()
+
+	for i := range mlr.lobbies {
+		lobby := &mlr.lobbies[i]
+		switch {
+		case lobby.ID != objectID:
+			continue
+		case slices.Contains(lobby.PlayerIDs, userID):
+			return nil
+		case len(lobby.PlayerIDs) >= lobby.MaxPlayers:
+			return ErrFull
+		}
+
+		lobby.PlayerIDs = append(lobby.PlayerIDs, userID)
+		if bot != nil {
+			lobby.Bots = append(lobby.Bots, *bot)
+		}
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+// ClaimNuke moves the next nuke of a lobby from due to next and tells whether this call did
+func (mlr *MemoryLobbyRepo) ClaimNuke(ctx context.Context, id string, due, next time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	mlr.mu.Lock()
+	defer mlr.mu.Unlock()
+
+	for i := range mlr.lobbies {
+		if mlr.lobbies[i].ID == objectID && mlr.lobbies[i].NextNukeAt.Equal(due) {
+			mlr.lobbies[i].NextNukeAt = next
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// LastMove returns when a player last moved in a lobby, the zero time if they never did
+func (mlr *MemoryLobbyRepo) LastMove(ctx context.Context, id, userID string) (time.Time, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return time.Time{}, err
+	}
+
+	mlr.mu.RLock()
+	defer mlr.mu.RUnlock()
+
+	return mlr.moves[lobbyPlayer{id, userID}], nil
+}
+
+// SetLastMove moves the last move of a player in a lobby from last to next and tells
+// whether this call did. A zero next forgets the move
+func (mlr *MemoryLobbyRepo) SetLastMove(ctx context.Context, id, userID string, last, next time.Time) (bool, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, err
+	}
+
+	mlr.mu.Lock()
+	defer mlr.mu.Unlock()
+
+	key := lobbyPlayer{id, userID}
+	if !mlr.moves[key].Equal(last) {
+		return false, nil
+	}
+
+	if next.IsZero() {
+		delete(mlr.moves, key)
+	} else {
+		mlr.moves[key] = next
+	}
+	return true, nil
+}
+
+// FinishLobby marks a lobby over at at, a finished lobby keeps the time it finished at
+func (mlr *MemoryLobbyRepo) FinishLobby(ctx context.Context, id string, at time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	mlr.mu.Lock()
+	defer mlr.mu.Unlock()
+
+	for i := range mlr.lobbies {
+		if mlr.lobbies[i].ID == objectID && !mlr.lobbies[i].Finished() {
+			mlr.lobbies[i].FinishedAt = &at
+		}
+	}
+
+	return nil
+}
+
+func (mlr *MemoryLobbyRepo) find(match func(model.Lobby) bool) []model.Lobby {
+	mlr.mu.RLock()
+	defer mlr.mu.RUnlock()
+
+	lobbies := []model.Lobby{}
+	for _, lobby := range mlr.lobbies {
+		if match(lobby) {
+			lobbies = append(lobbies, cloneLobby(lobby))
+		}
+	}
+
+	return lobbies
+}
+
+func (mlr *MemoryLobbyRepo) findOne(match func(model.Lobby) bool) (*model.Lobby, error) {
+	lobbies := mlr.find(match)
+	if len(lobbies) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &lobbies[0], nil
+}
+
+// cloneLobby copies a lobby with its own player and bot lists
+func cloneLobby(lobby model.Lobby) model.Lobby {
+	lobby.PlayerIDs = slices.Clone(lobby.PlayerIDs)
+	lobby.Bots = slices.Clone(lobby.Bots)
+	if lobby.FinishedAt != nil {
+		finishedAt := *lobby.FinishedAt
+		lobby.FinishedAt = &finishedAt
+	}
+	return lobby
+}
@@ -0,0 +1,278 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"services/internal/core/logging"
+	"services/internal/lobby/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLLobbyRepo is a LobbyRepo on the lobbies table of a SQLite or PostgreSQL database.
+// The provinces of each lobby are the rows of the provinces table carrying its ID
+type SQLLobbyRepo struct {
+	db *sql.DB
+}
+
+// NewSQLLobbyRepo creates a lobby repository on a database opened with sqldb.Open
+func NewSQLLobbyRepo(db *sql.DB) *SQLLobbyRepo {
+	return &SQLLobbyRepo{
+		db: db,
+	}
+}
+
+const selectLobby = `SELECT id, name, invite_code, owner_id, player_ids, max_players, nuke_every_minutes, started_at, next_nuke_at, bots, finished_at FROM lobbies`
+
+// isPlayer matches the lobbies listing the user given as $1
+const isPlayer = `',' || player_ids || ',' LIKE '%,' || $1 || ',%'`
+
+// GetByID retrieves a single lobby, ErrNotFound if it doesn't exist
+func (slr *SQLLobbyRepo) GetByID(ctx context.Context, id string) (*model.Lobby, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+
+	return slr.queryOne(ctx, "GetByID", selectLobby+` WHERE id = $1`, id)
+}
+
+// GetByInviteCode retrieves the lobby an invite code joins, ErrNotFound if none does
+func (slr *SQLLobbyRepo) GetByInviteCode(ctx context.Context, code string) (*model.Lobby, error) {
+	return slr.queryOne(ctx, "GetByInviteCode", selectLobby+` WHERE invite_code = $1`, code)
+}
+
+// GetByPlayer retrieves the lobbies a user is a member of, oldest first
+func (slr *SQLLobbyRepo) GetByPlayer(ctx context.Context, userID string) ([]model.Lobby, error) {
+	lobbies, err := slr.query(ctx, selectLobby+` WHERE `+isPlayer+` ORDER BY started_at, id`, userID)
+	return lobbies, slr.fail(ctx, "GetByPlayer", err)
+}
+
+// GetDue retrieves the unfinished lobbies whose next nuke is due at now
+func (slr *SQLLobbyRepo) GetDue(ctx context.Context, now time.Time) ([]model.Lobby, error) {
+	lobbies, err := slr.query(ctx, selectLobby+` WHERE next_nuke_at <= $1 AND finished_at IS NULL ORDER BY started_at, id`, now.UTC())
+	return lobbies, slr.fail(ctx, "GetDue", err)
+}
+
+// InsertLobby adds a lobby, one without an ID gets one
+func (slr *SQLLobbyRepo) InsertLobby(ctx context.Context, lobby *model.Lobby) error {
+	if lobby.ID.IsZero() {
+		lobby.ID = primitive.NewObjectID()
+	}
+
+	_, err := slr.db.ExecContext(ctx,
+		`INSERT INTO lobbies (id, name, invite_code, owner_id, player_ids, max_players, nuke_every_minutes, started_at, next_nuke_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		lobby.ID.Hex(), lobby.Name, lobby.InviteCode, lobby.OwnerID, strings.Join(lobby.PlayerIDs, ","), lobby.MaxPlayers, lobby.NukeEveryMinutes, lobby.StartedAt.UTC(), lobby.NextNukeAt.UTC())
+	return slr.fail(ctx, "InsertLobby", err)
+}
+
+// DeleteLobby removes a lobby with the last moves of its players, a missing one is no
+// error
+func (slr *SQLLobbyRepo) DeleteLobby(ctx context.Context, id string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return err
+	}
+
+	_, err := slr.db.ExecContext(ctx, `DELETE FROM lobby_moves WHERE lobby_id = $1`, id)
+	if err == nil {
+		_, err = slr.db.ExecContext(ctx, `DELETE FROM lobbies WHERE id = $1`, id)
+	}
+	return slr.fail(ctx, "DeleteLobby", err)
+}
+
+// AddPlayer makes a user a member of a lobby, ErrFull once it reached its player cap.
+// Joining a lobby twice changes nothing. The cap is checked by the update itself, two
+// players can't take the last seat
+func (slr *SQLLobbyRepo) AddPlayer(ctx context.Context, id, userID string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return err
+	}
+
+	// The player count is one more than the commas between the IDs
+	result, err := slr.db.ExecContext(ctx, `UPDATE lobbies SET player_ids = player_ids || ',' || $1
+		WHERE id = $2 AND NOT (`+isPlayer+`)
+		AND LENGTH(player_ids) - LENGTH(REPLACE(player_ids, ',', '')) + 1 < max_players`, userID, id)
+	if err != nil {
+		return slr.fail(ctx, "AddPlayer", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return slr.fail(ctx, "AddPlayer", err)
+	} else if affected > 0 {
+		return nil
+	}
+
+	// Nothing changed, the lobby is missing, full or has the player already
+	lobby, err := slr.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(lobby.PlayerIDs, userID) {
+		return nil
+	}
+	return ErrFull
+}
+
+// AddBot seats a bot player in a lobby like AddPlayer and lists it among its bots
+func (slr *SQLLobbyRepo) AddBot(ctx context.Context, id string, bot model.LobbyBot) error {
+	if err := slr.AddPlayer(ctx, id, bot.UserID); err != nil {
+		return err
+	}
+
+	_, err := slr.db.ExecContext(ctx, `UPDATE lobbies SET bots = CASE WHEN bots = '' THEN $1 ELSE bots || ',' || $1 END WHERE id = $2`,
+		bot.UserID+":"+bot.Username+":"+bot.Strategy, id)
+	return slr.fail(ctx, "AddBot", err)
+}
+
+// GetWithBots retrieves the unfinished lobbies with bots seated, oldest first
+func (slr *SQLLobbyRepo) GetWithBots(ctx context.Context) ([]model.Lobby, error) {
+	lobbies, err := slr.query(ctx, selectLobby+` WHERE bots <> '' AND finished_at IS NULL ORDER BY started_at, id`)
+	return lobbies, slr.fail(ctx, "GetWithBots", err)
+}
+
+// ClaimNuke moves the next nuke of a lobby from due to next and tells whether this call
+// did, only one of several instances running the lobby nukes gets to nuke a round
+func (slr *SQLLobbyRepo) ClaimNuke(ctx context.Context, id string, due, next time.Time) (bool, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, err
+	}
+
+	result, err := slr.db.ExecContext(ctx, `UPDATE lobbies SET next_nuke_at = $1 WHERE id = $2 AND next_nuke_at = $3`, next.UTC(), id, due.UTC())
+	if err != nil {
+		return false, slr.fail(ctx, "ClaimNuke", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, slr.fail(ctx, "ClaimNuke", err)
+	}
+
+	return affected > 0, nil
+}
+
+// LastMove returns when a player last moved in a lobby, the zero time if they never did.
+// The last moves are the rows of the lobby_moves table
+func (slr *SQLLobbyRepo) LastMove(ctx context.Context, id, userID string) (time.Time, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return time.Time{}, err
+	}
+
+	var movedAt time.Time
+	err := slr.db.QueryRowContext(ctx, `SELECT moved_at FROM lobby_moves WHERE lobby_id = $1 AND user_id = $2`, id, userID).Scan(&movedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, slr.fail(ctx, "LastMove", err)
+	}
+
+	return movedAt.UTC(), nil
+}
+
+// SetLastMove moves the last move of a player in a lobby from last to next and tells
+// whether this call did. A zero next forgets the move
+func (slr *SQLLobbyRepo) SetLastMove(ctx context.Context, id, userID string, last, next time.Time) (bool, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, err
+	}
+
+	var result sql.Result
+	var err error
+	switch {
+	case next.IsZero():
+		result, err = slr.db.ExecContext(ctx, `DELETE FROM lobby_moves WHERE lobby_id = $1 AND user_id = $2 AND moved_at = $3`, id, userID, last.UTC())
+	case last.IsZero():
+		result, err = slr.db.ExecContext(ctx, `INSERT INTO lobby_moves (lobby_id, user_id, moved_at) VALUES ($1, $2, $3) ON CONFLICT (lobby_id, user_id) DO NOTHING`, id, userID, next.UTC())
+	default:
+		result, err = slr.db.ExecContext(ctx, `UPDATE lobby_moves SET moved_at = $1 WHERE lobby_id = $2 AND user_id = $3 AND moved_at = $4`, next.UTC(), id, userID, last.UTC())
+	}
+	if err != nil {
+		return false, slr.fail(ctx, "SetLastMove", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, slr.fail(ctx, "SetLastMove", err)
+	}
+
+	return affected > 0, nil
+}
+
+// FinishLobby marks a lobby over at at, a finished lobby keeps the time it finished at
+func (slr *SQLLobbyRepo) FinishLobby(ctx context.Context, id string, at time.Time) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return err
+	}
+
+	_, err := slr.db.ExecContext(ctx, `UPDATE lobbies SET finished_at = $1 WHERE id = $2 AND finished_at IS NULL`, at.UTC(), id)
+	return slr.fail(ctx, "FinishLobby", err)
+}
+
+func (slr *SQLLobbyRepo) queryOne(ctx context.Context, operation, query string, args ...any) (*model.Lobby, error) {
+	lobbies, err := slr.query(ctx, query, args...)
+	if err != nil {
+		return nil, slr.fail(ctx, operation, err)
+	}
+	if len(lobbies) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &lobbies[0], nil
+}
+
+// query reads the lobbies selected with selectLobby
+func (slr *SQLLobbyRepo) query(ctx context.Context, query string, args ...any) ([]model.Lobby, error) {
+	rows, err := slr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lobbies := []model.Lobby{}
+	for rows.Next() {
+		var l model.Lobby
+		var id, playerIDs, bots string
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&id, &l.Name, &l.InviteCode, &l.OwnerID, &playerIDs, &l.MaxPlayers, &l.NukeEveryMinutes, &l.StartedAt, &l.NextNukeAt, &bots, &finishedAt); err != nil {
+			return nil, err
+		}
+
+		if l.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, err
+		}
+		l.PlayerIDs = []string{}
+		if playerIDs != "" {
+			l.PlayerIDs = strings.Split(playerIDs, ",")
+		}
+		for _, bot := range strings.Split(bots, ",") {
+			if fields := strings.SplitN(bot, ":", 3); len(fields) == 3 {
+				l.Bots = append(l.Bots, model.LobbyBot{UserID: fields[0], Username: fields[1], Strategy: fields[2]})
+			}
+		}
+		l.StartedAt = l.StartedAt.UTC()
+		l.NextNukeAt = l.NextNukeAt.UTC()
+		if finishedAt.Valid {
+			at := finishedAt.Time.UTC()
+			l.FinishedAt = &at
+		}
+
+		lobbies = append(lobbies, l)
+	}
+
+	return lobbies, rows.Err()
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (slr *SQLLobbyRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Lobby store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/core/sqldb/sqldbtest"
+	"services/internal/lobby/model"
+	province_model "services/internal/province/model"
+	province_repo "services/internal/province/repo"
+)
+
+func TestSQLLobbyRepo(t *testing.T) {
+	sqldbtest.Run(t, testSQLLobbyRepo)
+}
+
+func testSQLLobbyRepo(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	slr := NewSQLLobbyRepo(db)
+
+	owner, friend, stranger := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	start := time.Now().UTC().Truncate(time.Second)
+	lobby := model.Lobby{Name: "Class 9B", InviteCode: "K7P2QX9M", OwnerID: owner, PlayerIDs: []string{owner}, MaxPlayers: 2, NukeEveryMinutes: 60, StartedAt: start, NextNukeAt: start.Add(time.Hour)}
+	require.NoError(t, slr.InsertLobby(ctx, &lobby))
+	assert.False(t, lobby.ID.IsZero())
+
+	found, err := slr.GetByInviteCode(ctx, "K7P2QX9M")
+	require.NoError(t, err)
+	assert.Equal(t, lobby, *found)
+	_, err = slr.GetByInviteCode(ctx, "AAAAAAAA")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// The second seat is the last one, joining twice takes no seat
+	require.NoError(t, slr.AddPlayer(ctx, lobby.ID.Hex(), friend))
+	require.NoError(t, slr.AddPlayer(ctx, lobby.ID.Hex(), friend))
+	assert.ErrorIs(t, slr.AddPlayer(ctx, lobby.ID.Hex(), stranger), ErrFull)
+	assert.ErrorIs(t, slr.AddPlayer(ctx, primitive.NewObjectID().Hex(), stranger), ErrNotFound)
+
+	joined, err := slr.GetByPlayer(ctx, friend)
+	require.NoError(t, err)
+	require.Len(t, joined, 1)
+	assert.Equal(t, []string{owner, friend}, joined[0].PlayerIDs)
+	none, err := slr.GetByPlayer(ctx, stranger)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	// The nuke is due once its time came, and only one claim of it wins
+	due, err := slr.GetDue(ctx, start.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	due, err = slr.GetDue(ctx, start.Add(time.Hour+time.Second))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	claimed, err := slr.ClaimNuke(ctx, lobby.ID.Hex(), due[0].NextNukeAt, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = slr.ClaimNuke(ctx, lobby.ID.Hex(), due[0].NextNukeAt, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	found, err = slr.GetByID(ctx, lobby.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(2*time.Hour), found.NextNukeAt)
+
+	// A finished lobby is never due again and keeps the time it finished at
+	require.NoError(t, slr.FinishLobby(ctx, lobby.ID.Hex(), start.Add(2*time.Hour)))
+	require.NoError(t, slr.FinishLobby(ctx, lobby.ID.Hex(), start.Add(3*time.Hour)))
+	due, err = slr.GetDue(ctx, start.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	found, err = slr.GetByID(ctx, lobby.ID.Hex())
+	require.NoError(t, err)
+	require.True(t, found.Finished())
+	assert.Equal(t, start.Add(2*time.Hour), *found.FinishedAt)
+
+	require.NoError(t, slr.DeleteLobby(ctx, lobby.ID.Hex()))
+	_, err = slr.GetByID(ctx, lobby.ID.Hex())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLLobbyRepo_SeatsBots(t *testing.T) {
+	sqldbtest.Run(t, testSQLLobbyRepo_SeatsBots)
+}
+
+func testSQLLobbyRepo_SeatsBots(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	slr := NewSQLLobbyRepo(db)
+
+	owner := primitive.NewObjectID().Hex()
+	start := time.Now().UTC().Truncate(time.Second)
+	lobby := model.Lobby{Name: "Quiet room", InviteCode: "QU13TR00", OwnerID: owner, PlayerIDs: []string{owner}, MaxPlayers: 3, NukeEveryMinutes: 60, StartedAt: start, NextNukeAt: start.Add(time.Hour)}
+	require.NoError(t, slr.InsertLobby(ctx, &lobby))
+
+	none, err := slr.GetWithBots(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	// Bots take seats like players do
+	first := model.LobbyBot{UserID: primitive.NewObjectID().Hex(), Username: "bot_k7p2qx9m", Strategy: "pile-on-leader"}
+	second := model.LobbyBot{UserID: primitive.NewObjectID().Hex(), Username: "bot_a2b3c4d5", Strategy: "defend-home"}
+	require.NoError(t, slr.AddBot(ctx, lobby.ID.Hex(), first))
+	require.NoError(t, slr.AddBot(ctx, lobby.ID.Hex(), second))
+	assert.ErrorIs(t, slr.AddBot(ctx, lobby.ID.Hex(), model.LobbyBot{UserID: primitive.NewObjectID().Hex(), Username: "bot_full", Strategy: "random"}), ErrFull)
+
+	seated, err := slr.GetWithBots(ctx)
+	require.NoError(t, err)
+	require.Len(t, seated, 1)
+	assert.Equal(t, []model.LobbyBot{first, second}, seated[0].Bots)
+	assert.Equal(t, []string{owner, first.UserID, second.UserID}, seated[0].PlayerIDs)
+
+	// The bots of a finished lobby aren't deployed again
+	require.NoError(t, slr.FinishLobby(ctx, lobby.ID.Hex(), start))
+	none, err = slr.GetWithBots(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestSQLLobbyProvinces_AreKeptApart(t *testing.T) {
+	sqldbtest.Run(t, testSQLLobbyProvinces_AreKeptApart)
+}
+
+func testSQLLobbyProvinces_AreKeptApart(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+
+	public := province_repo.NewSQLProvinceRepo(db)
+	lobby := province_repo.NewSQLLobbyProvinceRepo(db, primitive.NewObjectID().Hex())
+	require.NoError(t, public.InsertProvinces(ctx, province_model.Province{ProvinceName: "Ankara", DestroymentRound: -1}))
+	require.NoError(t, lobby.InsertProvinces(ctx, province_model.Province{ProvinceName: "Narnia", DestroymentRound: -1}))
+
+	provinces, err := lobby.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, provinces, 1)
+	assert.Equal(t, "Narnia", provinces[0].ProvinceName)
+
+	// The public game neither sees nor changes the lobby's provinces
+	_, err = public.GetByID(ctx, provinces[0].ID.Hex())
+	assert.ErrorIs(t, err, province_repo.ErrNotFound)
+	require.NoError(t, public.NukeProvinces(ctx, 1, []string{provinces[0].ID.Hex()}))
+	require.NoError(t, public.ResetAllProvinceCounts(ctx))
+
+	narnia, err := lobby.GetByID(ctx, provinces[0].ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, -1, narnia.DestroymentRound)
+}
+
+func TestSQLLobbyRepo_LastMove(t *testing.T) {
+	sqldbtest.Run(t, testSQLLobbyRepo_LastMove)
+}
+
+func testSQLLobbyRepo_LastMove(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	slr := NewSQLLobbyRepo(db)
+
+	lobbyID, userID := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	moved := time.Now().UTC().Truncate(time.Millisecond)
+
+	last, err := slr.LastMove(ctx, lobbyID, userID)
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+
+	// Of two moves started from the same last move only one wins
+	started, err := slr.SetLastMove(ctx, lobbyID, userID, time.Time{}, moved)
+	require.NoError(t, err)
+	assert.True(t, started)
+	started, err = slr.SetLastMove(ctx, lobbyID, userID, time.Time{}, moved)
+	require.NoError(t, err)
+	assert.False(t, started)
+	last, err = slr.LastMove(ctx, lobbyID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, moved, last)
+
+	started, err = slr.SetLastMove(ctx, lobbyID, userID, moved, moved.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, started)
+	started, err = slr.SetLastMove(ctx, lobbyID, userID, moved, moved.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, started)
+
+	// Giving a move back to the zero time forgets it
+	given, err := slr.SetLastMove(ctx, lobbyID, userID, moved.Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, given)
+	last, err = slr.LastMove(ctx, lobbyID, userID)
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+}
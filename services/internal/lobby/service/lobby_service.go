@@ -0,0 +1,867 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	auth_model "services/internal/auth/model"
+	auth_service "services/internal/auth/service"
+	"services/internal/bot"
+	"services/internal/core/logging"
+	"services/internal/core/response"
+	"services/internal/core/tracing"
+	"services/internal/core/validation"
+	"services/internal/game"
+	"services/internal/lobby/model"
+	"services/internal/lobby/repo"
+	province_model "services/internal/province/model"
+	province_service "services/internal/province/service"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LobbyRepository is where lobbies are kept, implemented by repo.LobbyRepo and its memory
+// and SQL siblings
+type LobbyRepository interface {
+	GetByID(ctx context.Context, id string) (*model.Lobby, error)
+	GetByInviteCode(ctx context.Context, code string) (*model.Lobby, error)
+	GetByPlayer(ctx context.Context, userID string) ([]model.Lobby, error)
+	GetDue(ctx context.Context, now time.Time) ([]model.Lobby, error)
+	InsertLobby(ctx context.Context, lobby *model.Lobby) error
+	DeleteLobby(ctx context.Context, id string) error
+	AddPlayer(ctx context.Context, id, userID string) error
+	AddBot(ctx context.Context, id string, bot model.LobbyBot) error
+	GetWithBots(ctx context.Context) ([]model.Lobby, error)
+	ClaimNuke(ctx context.Context, id string, due, next time.Time) (bool, error)
+	FinishLobby(ctx context.Context, id string, at time.Time) error
+	LastMove(ctx context.Context, id, userID string) (time.Time, error)
+	SetLastMove(ctx context.Context, id, userID string, last, next time.Time) (bool, error)
+}
+
+// ProvinceRepository is the storage of the provinces of a single lobby, the one a
+// province service plays on and a way to seed it
+type ProvinceRepository interface {
+	province_service.ProvinceRepository
+	InsertProvinces(ctx context.Context, provinces ...province_model.Province) error
+}
+
+// MapRepository is the public game's map new lobbies copy their provinces from
+type MapRepository interface {
+	GetAll(ctx context.Context) ([]province_model.Province, error)
+}
+
+// ProvinceStores opens the province store of a lobby by its ID
+type ProvinceStores func(lobbyID string) ProvinceRepository
+
+// errNotLoggedIn answers every lobby request without a valid token, lobbies are only
+// for logged in players
+var errNotLoggedIn = errors.New("lobbies need a logged in player")
+
+// errNotMember hides a lobby from anyone who didn't join it, it answers like a missing one
+var errNotMember = errors.New("not a member of the lobby")
+
+// errNotOwner rejects changes only the owner of a lobby may make
+var errNotOwner = errors.New("not the owner of the lobby")
+
+// errNoBots answers bot requests while no fleet runs the bots
+var errNoBots = errors.New("bots are not available")
+
+// errFinished rejects moves in a lobby that is over
+var errFinished = errors.New("the lobby is over")
+
+// inviteAlphabet leaves out the letters and digits that are easily confused, 32 of them
+// map a random byte without bias
+const inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const inviteCodeLength = 8
+
+// gameIdleTimeout is how long the game of a lobby nobody plays or nukes is kept in memory,
+// it is built again on the next request
+const gameIdleTimeout = time.Hour
+
+// movesPerRound is how many moves a player makes in a round of a lobby at most, as many
+// as in a day of the public game
+const movesPerRound = int64(24 * time.Hour / auth_service.MoveCooldown)
+
+type LobbyService struct {
+	repo      LobbyRepository
+	publicMap MapRepository
+	provinces ProvinceStores
+	tracer    trace.Tracer
+	tp        trace.TracerProvider
+
+	rules    game.Rules
+	cacheTTL time.Duration
+	bots     *bot.Fleet // nil if lobbies can't have bots
+
+	mu    sync.Mutex
+	games map[string]*lobbyGame // of the unfinished lobbies by ID, built on first use
+}
+
+// lobbyGame is the province service a lobby is played with and when it was last used
+type lobbyGame struct {
+	game   *province_service.ProvinceService
+	usedAt time.Time
+}
+
+// NewLobbyService creates a lobby service keeping lobbies in repo, their provinces in the
+// stores opened by provinces and copying maps from publicMap
+func NewLobbyService(repo LobbyRepository, publicMap MapRepository, provinces ProvinceStores) *LobbyService {
+	ls := &LobbyService{
+		repo:      repo,
+		publicMap: publicMap,
+		provinces: provinces,
+		tracer:    tracing.Tracer(nil),
+		games:     map[string]*lobbyGame{},
+	}
+	ls.SetRules(game.DefaultRules())
+
+	return ls
+}
+
+// SetTracerProvider records spans on tp, for the lobbies and the games played in them.
+// Nothing is recorded by default
+func (ls *LobbyService) SetTracerProvider(tp trace.TracerProvider) {
+	ls.tracer = tracing.Tracer(tp)
+	ls.tp = tp
+}
+
+// SetRules plays the lobbies by rules instead of game.DefaultRules. Players have no home
+// province on a lobby's map, every province is in their reach
+func (ls *LobbyService) SetRules(rules game.Rules) {
+	rules.Attacks.AdjacentOnly = false
+	ls.rules = rules
+}
+
+// SetCacheTTL serves the province list and standings of each lobby from memory for up to
+// ttl after they were read, nothing is cached by default
+func (ls *LobbyService) SetCacheTTL(ttl time.Duration) {
+	ls.cacheTTL = ttl
+}
+
+// SetBots lets lobby owners seat bot players run by fleet, lobbies have no bots by default
+func (ls *LobbyService) SetBots(fleet *bot.Fleet) {
+	ls.bots = fleet
+}
+
+// --------------------------------------------------------------------
+// CreateLobby opens a lobby owned by the logged in player, on a copy of some provinces of
+// the public map or on a custom list of provinces. The first round starts right away
+func (ls *LobbyService) CreateLobby(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateLobbyRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.CreateLobby")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	userID, err := player(r)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	provinces, err := ls.lobbyMap(ctx, req)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	start := time.Now().UTC().Truncate(time.Second)
+	lobby := model.Lobby{
+		ID:               primitive.NewObjectID(),
+		Name:             req.Name,
+		InviteCode:       code,
+		OwnerID:          userID,
+		PlayerIDs:        []string{userID},
+		MaxPlayers:       req.MaxPlayers,
+		NukeEveryMinutes: req.NukeEveryMinutes,
+		StartedAt:        start,
+	}
+	lobby.NextNukeAt = start.Add(lobby.RoundLength())
+
+	// The lobby goes first, its provinces are never left without a lobby. A lobby whose
+	// map failed is taken out again, only its owner could have seen it meanwhile
+	if err := ls.repo.InsertLobby(ctx, &lobby); err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+	if err := ls.provinces(lobby.ID.Hex()).InsertProvinces(ctx, provinces...); err != nil {
+		if deleteErr := ls.repo.DeleteLobby(context.WithoutCancel(ctx), lobby.ID.Hex()); deleteErr != nil {
+			err = errors.Join(err, deleteErr)
+		}
+		writeLobbyError(w, r, span, err)
+		return
+	}
+	slog.InfoContext(ctx, "Lobby opened", logging.LobbyID(lobby.ID.Hex()), "provinces", len(provinces), "max_players", lobby.MaxPlayers)
+
+	response.JSON(w, http.StatusCreated, model.LobbyResponse{
+		Lobby: lobby,
+	})
+}
+
+// GetLobbies returns the lobbies the logged in player is a member of, oldest first
+func (ls *LobbyService) GetLobbies(w http.ResponseWriter, r *http.Request) {
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.GetLobbies")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userID, err := player(r)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	lobbies, err := ls.repo.GetByPlayer(ctx, userID)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, model.GetLobbiesResponse{
+		Lobbies: lobbies,
+	})
+}
+
+// GetLobby returns a single lobby by ID, for its members only
+func (ls *LobbyService) GetLobby(w http.ResponseWriter, r *http.Request) {
+	req := model.GetLobbyRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.GetLobby", trace.WithAttributes(attribute.String(tracing.KeyLobbyID, req.ID)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lobby, err := ls.memberOf(ctx, r, req.ID)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, model.LobbyResponse{
+		Lobby: *lobby,
+	})
+}
+
+// JoinLobby makes the logged in player a member of the lobby an invite code joins, while
+// it has a free seat and isn't finished. Joining twice changes nothing
+func (ls *LobbyService) JoinLobby(w http.ResponseWriter, r *http.Request) {
+	var req model.JoinLobbyRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.JoinLobby")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	userID, err := player(r)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	lobby, err := ls.repo.GetByInviteCode(ctx, strings.ToUpper(req.InviteCode))
+	if err == nil && lobby.Finished() {
+		err = errFinished
+	}
+	if err == nil {
+		err = ls.repo.AddPlayer(ctx, lobby.ID.Hex(), userID)
+	}
+	if err == nil {
+		lobby, err = ls.repo.GetByID(ctx, lobby.ID.Hex())
+	}
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, model.LobbyResponse{
+		Lobby: *lobby,
+	})
+}
+
+// AddBots seats bot players in a lobby, for its owner only. They take free seats like
+// players and play there with the same cooldowns for as long as the lobby lasts
+func (ls *LobbyService) AddBots(w http.ResponseWriter, r *http.Request) {
+	req := model.GetLobbyRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	var body model.AddBotsRequest
+	if err := validation.DecodeJSON(w, r, &body); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.AddBots", trace.WithAttributes(attribute.String(tracing.KeyLobbyID, req.ID)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	ctx = logging.With(ctx, logging.LobbyID(req.ID))
+
+	lobby, err := ls.memberOf(ctx, r, req.ID)
+	switch userID, _ := player(r); {
+	case err != nil:
+	case ls.bots == nil:
+		err = errNoBots
+	case userID != lobby.OwnerID:
+		err = errNotOwner
+	case len(lobby.PlayerIDs)+body.Count > lobby.MaxPlayers:
+		err = repo.ErrFull
+	}
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	for range body.Count {
+		if err := ls.addBot(ctx, req.ID, body.Strategy); err != nil {
+			writeLobbyError(w, r, span, err)
+			return
+		}
+	}
+	slog.InfoContext(ctx, "Bots seated", "strategy", body.Strategy, "count", body.Count)
+
+	lobby, err = ls.repo.GetByID(ctx, req.ID)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, model.LobbyResponse{
+		Lobby: *lobby,
+	})
+}
+
+// addBot signs a bot up under a random username, seats it and deploys it
+func (ls *LobbyService) addBot(ctx context.Context, lobbyID, strategy string) error {
+	code, err := newInviteCode()
+	if err != nil {
+		return err
+	}
+	username := "bot_" + strings.ToLower(code)
+
+	b, err := ls.bots.Enlist(ctx, bot.LobbyGame(lobbyID), username, strategy)
+	if err != nil {
+		return err
+	}
+	if err := ls.repo.AddBot(ctx, lobbyID, model.LobbyBot{UserID: b.Account.UserID, Username: username, Strategy: strategy}); err != nil {
+		return err
+	}
+
+	ls.bots.Deploy(b)
+	return nil
+}
+
+// DeployBots deploys the bots seated in every lobby, for them to play on after a restart
+func (ls *LobbyService) DeployBots(ctx context.Context) error {
+	if ls.bots == nil {
+		return nil
+	}
+
+	lobbies, err := ls.repo.GetWithBots(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, lobby := range lobbies {
+		for _, seated := range lobby.Bots {
+			b, err := ls.bots.Enlist(ctx, bot.LobbyGame(lobby.ID.Hex()), seated.Username, seated.Strategy)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ls.bots.Deploy(b)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// --------------------------------------------------------------------
+// The game of a lobby is played with the handlers of the public game, on the lobby's own
+// provinces and rounds. Moves in a lobby are weighed as anonymous ones, streaks, items and
+// home provinces belong to the public game. A finished lobby can still be looked at, moves
+// in it are rejected
+
+// GetProvinces returns the provinces of a lobby
+func (ls *LobbyService) GetProvinces(w http.ResponseWriter, r *http.Request) {
+	ls.play(w, r, false, (*province_service.ProvinceService).GetAllProvinces)
+}
+
+// GetTopProvinces returns the top 5 living provinces of a lobby
+func (ls *LobbyService) GetTopProvinces(w http.ResponseWriter, r *http.Request) {
+	ls.play(w, r, false, (*province_service.ProvinceService).GetTopProvinces)
+}
+
+// GetProjection returns the provinces of a lobby its nuke would take if the round ended now
+func (ls *LobbyService) GetProjection(w http.ResponseWriter, r *http.Request) {
+	ls.play(w, r, false, (*province_service.ProvinceService).GetProjection)
+}
+
+// GetCurrentRound returns which round a lobby is in
+func (ls *LobbyService) GetCurrentRound(w http.ResponseWriter, r *http.Request) {
+	ls.play(w, r, false, (*province_service.ProvinceService).GetCurrentRoundHandler)
+}
+
+// AttackProvince attacks a province of a lobby
+func (ls *LobbyService) AttackProvince(w http.ResponseWriter, r *http.Request) {
+	ls.play(w, r, true, (*province_service.ProvinceService).AttackProvince)
+}
+
+// SupportProvince supports a province of a lobby
+func (ls *LobbyService) SupportProvince(w http.ResponseWriter, r *http.Request) {
+	ls.play(w, r, true, (*province_service.ProvinceService).SupportProvince)
+}
+
+// GetCooldownLeft returns how long the logged in player waits before their next move in
+// a lobby
+func (ls *LobbyService) GetCooldownLeft(w http.ResponseWriter, r *http.Request) {
+	req := model.GetLobbyRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.GetCooldownLeft", trace.WithAttributes(attribute.String(tracing.KeyLobbyID, req.ID)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lobby, err := ls.memberOf(ctx, r, req.ID)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	userID, _ := player(r)
+	last, err := ls.repo.LastMove(ctx, req.ID, userID)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, auth_model.CooldownLeftInSecondsResponse{
+		CooldownLeftInSeconds: int(cooldownLeft(*lobby, last, time.Now()).Seconds()),
+	})
+}
+
+// play hands r to a handler of the game of the lobby in its path, for its members only.
+// A move is only handed on while the lobby isn't finished and its player's cooldown in
+// the lobby is over, there is no separate call to start it like in the public game. The
+// cooldown is taken before the move is handed on, so two moves sent together can't both
+// pass it, and given back if the handler rejects the move
+func (ls *LobbyService) play(w http.ResponseWriter, r *http.Request, move bool, handler func(*province_service.ProvinceService, http.ResponseWriter, *http.Request)) {
+	req := model.GetLobbyRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ls.tracer.Start(r.Context(), "LobbyService.play", trace.WithAttributes(attribute.String(tracing.KeyLobbyID, req.ID)))
+	defer span.End()
+
+	lobby, err := ls.memberOf(ctx, r, req.ID)
+	if err == nil && move && lobby.Finished() {
+		err = errFinished
+	}
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+
+	ctx = logging.With(ctx, logging.LobbyID(req.ID))
+	if !move {
+		handler(ls.Game(*lobby), w, r.WithContext(ctx))
+		return
+	}
+
+	userID, _ := player(r)
+	now := time.Now().UTC().Truncate(time.Millisecond) // as precise as every store keeps it
+	last, remaining, err := ls.startMove(ctx, *lobby, userID, now)
+	if err != nil {
+		writeLobbyError(w, r, span, err)
+		return
+	}
+	if remaining > 0 {
+		slog.InfoContext(ctx, "Move rejected by cooldown", "cooldown_left", remaining)
+		auth_service.WriteCooldownActive(w, r, remaining)
+		return
+	}
+
+	sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	handler(ls.Game(*lobby), sr, r.WithContext(ctx))
+	if sr.status >= http.StatusBadRequest {
+		// The rejected move isn't taken, it costs the player no cooldown
+		if _, err := ls.repo.SetLastMove(context.WithoutCancel(ctx), req.ID, userID, now, last); err != nil {
+			tracing.Fail(span, err)
+		}
+	}
+}
+
+// startMove takes the cooldown of a move of a player in lobby at now. It returns the
+// player's move before, to give the cooldown back with, or what is left of a cooldown
+// that still runs. Of two moves started together only one takes it, the other one waits
+// like a late one
+func (ls *LobbyService) startMove(ctx context.Context, lobby model.Lobby, userID string, now time.Time) (time.Time, time.Duration, error) {
+	last, err := ls.repo.LastMove(ctx, lobby.ID.Hex(), userID)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if remaining := cooldownLeft(lobby, last, now); remaining > 0 {
+		return last, remaining, nil
+	}
+
+	started, err := ls.repo.SetLastMove(ctx, lobby.ID.Hex(), userID, last, now)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if !started {
+		return last, moveCooldown(lobby), nil
+	}
+
+	return last, 0, nil
+}
+
+// moveCooldown is how long a player of lobby waits between two moves, the same share of
+// a round the public game's cooldown is of a day
+func moveCooldown(lobby model.Lobby) time.Duration {
+	return lobby.RoundLength() / time.Duration(movesPerRound)
+}
+
+// cooldownLeft is what is left at now of the cooldown a move in lobby at last started,
+// a zero last is a player who never moved there
+func cooldownLeft(lobby model.Lobby, last, now time.Time) time.Duration {
+	if last.IsZero() {
+		return 0
+	}
+
+	return max(last.Add(moveCooldown(lobby)).Sub(now), 0)
+}
+
+// Game returns the province service a lobby is played with, built on first use and kept
+// until the lobby finishes or idles for gameIdleTimeout. The game of a finished lobby is
+// built for every request, it is only looked at
+func (ls *LobbyService) Game(lobby model.Lobby) *province_service.ProvinceService {
+	if lobby.Finished() {
+		return ls.newGame(lobby)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	id := lobby.ID.Hex()
+	kept, ok := ls.games[id]
+	if !ok {
+		kept = &lobbyGame{game: ls.newGame(lobby)}
+		ls.games[id] = kept
+	}
+	kept.usedAt = time.Now()
+
+	return kept.game
+}
+
+func (ls *LobbyService) newGame(lobby model.Lobby) *province_service.ProvinceService {
+	game := province_service.NewProvinceService(ls.provinces(lobby.ID.Hex()), lobby.StartedAt)
+	game.SetRoundLength(lobby.RoundLength())
+	game.SetRules(ls.rules)
+	game.SetCacheTTL(ls.cacheTTL)
+	game.SetTracerProvider(ls.tp)
+
+	return game
+}
+
+// forgetGames drops the games of the lobbies with ids, and the ones idle since before
+// gameIdleTimeout ahead of now
+func (ls *LobbyService) forgetGames(now time.Time, ids ...string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for _, id := range ids {
+		delete(ls.games, id)
+	}
+	for id, kept := range ls.games {
+		if now.Sub(kept.usedAt) > gameIdleTimeout {
+			delete(ls.games, id)
+		}
+	}
+}
+
+// --------------------------------------------------------------------
+// RunNukes nukes the lobbies whose round is over every interval until ctx is done
+func (ls *LobbyService) RunNukes(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ls.NukeDue(ctx, now)
+		}
+	}
+}
+
+// NukeDue nukes every lobby whose next nuke is due at now and schedules the one after.
+// Rounds missed while nothing ran aren't made up, the lobby goes on from the next one.
+// Each nuke is claimed first, several instances can run NukeDue side by side. A lobby with
+// a single province left is finished, it isn't nuked any more, and the games of finished
+// and idle lobbies are dropped from memory
+func (ls *LobbyService) NukeDue(ctx context.Context, now time.Time) error {
+	due, err := ls.repo.GetDue(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var finished []string
+	defer func() { ls.forgetGames(now, finished...) }()
+
+	for _, lobby := range due {
+		lobbyCtx := logging.With(ctx, logging.LobbyID(lobby.ID.Hex()))
+
+		next := lobby.NextNukeAt.Add(lobby.RoundLength())
+		for !next.After(now) {
+			next = next.Add(lobby.RoundLength())
+		}
+
+		claimed, err := ls.repo.ClaimNuke(lobbyCtx, lobby.ID.Hex(), lobby.NextNukeAt, next)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		result, err := ls.Game(lobby).ExecuteDestroymentRound(lobbyCtx)
+		if err != nil {
+			slog.ErrorContext(lobbyCtx, "Lobby nuke failed, the round is skipped", logging.Err(err))
+			errs = append(errs, err)
+			continue
+		}
+		slog.InfoContext(lobbyCtx, "Lobby nuked", logging.Round(result.Round), "victims", len(result.Victims), "next_nuke_at", next)
+
+		over, err := ls.finishIfWon(lobbyCtx, lobby, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if over {
+			finished = append(finished, lobby.ID.Hex())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// finishIfWon finishes a lobby once no more than a single province of it is alive and
+// tells whether it did
+func (ls *LobbyService) finishIfWon(ctx context.Context, lobby model.Lobby, now time.Time) (bool, error) {
+	provinces, err := ls.provinces(lobby.ID.Hex()).GetAll(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var living []string
+	for _, p := range provinces {
+		if p.DestroymentRound == -1 {
+			living = append(living, p.ProvinceName)
+		}
+	}
+	if len(living) > 1 {
+		return false, nil
+	}
+
+	if err := ls.repo.FinishLobby(ctx, lobby.ID.Hex(), now); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "Lobby finished", "winner", strings.Join(living, ""))
+
+	return true, nil
+}
+
+// --------------------------------------------------------------------
+// memberOf reads a lobby the player sending r is a member of
+func (ls *LobbyService) memberOf(ctx context.Context, r *http.Request, id string) (*model.Lobby, error) {
+	userID, err := player(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lobby, err := ls.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(lobby.PlayerIDs, userID) {
+		return nil, errNotMember
+	}
+
+	return lobby, nil
+}
+
+// lobbyMap builds the provinces of a new lobby, fresh copies of the requested provinces
+// of the public map keeping their neighbors among each other, or the custom ones
+func (ls *LobbyService) lobbyMap(ctx context.Context, req model.CreateLobbyRequest) ([]province_model.Province, error) {
+	if len(req.ProvinceIDs) > 0 && len(req.Provinces) > 0 {
+		return nil, validation.FieldErrors{{Field: "provinces", Rule: "excluded_with", Message: "can't be sent together with province_ids"}}
+	}
+
+	provinces := []province_model.Province{}
+	for _, p := range req.Provinces {
+		provinces = append(provinces, province_model.Province{
+			ID:               primitive.NewObjectID(),
+			ProvinceName:     p.ProvinceName,
+			ProvinceColorHex: p.ProvinceColorHex,
+			DestroymentRound: -1,
+		})
+	}
+	if len(req.ProvinceIDs) == 0 {
+		return provinces, nil
+	}
+
+	public, err := ls.publicMap.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]province_model.Province, len(public))
+	for _, p := range public {
+		byID[p.ID.Hex()] = p
+	}
+
+	copies := make(map[string]primitive.ObjectID, len(req.ProvinceIDs))
+	for _, id := range req.ProvinceIDs {
+		if _, ok := byID[id]; !ok {
+			return nil, validation.FieldErrors{{Field: "province_ids", Rule: "exists", Message: "province " + id + " doesn't exist"}}
+		}
+		copies[id] = primitive.NewObjectID()
+	}
+
+	for _, id := range req.ProvinceIDs {
+		original := byID[id]
+
+		var neighbors []string
+		for _, neighbor := range original.Neighbors {
+			if copied, ok := copies[neighbor]; ok {
+				neighbors = append(neighbors, copied.Hex())
+			}
+		}
+
+		provinces = append(provinces, province_model.Province{
+			ID:               copies[id],
+			ProvinceName:     original.ProvinceName,
+			ProvinceColorHex: original.ProvinceColorHex,
+			DestroymentRound: -1,
+			Neighbors:        neighbors,
+		})
+	}
+
+	return provinces, nil
+}
+
+// player reads the ID of the logged in player sending r
+func player(r *http.Request) (string, error) {
+	userID, err := province_service.ExtractUserIDFromRequest(r)
+	if err != nil {
+		return "", errNotLoggedIn
+	}
+
+	return userID, nil
+}
+
+// newInviteCode draws a random invite code
+func newInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+
+	for i, b := range code {
+		code[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+
+	return string(code), nil
+}
+
+// writeLobbyError answers a lobby request that was rejected or failed
+func writeLobbyError(w http.ResponseWriter, r *http.Request, span trace.Span, err error) {
+	var fieldErrors validation.FieldErrors
+
+	switch {
+	case errors.Is(err, errNotLoggedIn):
+		response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
+	case errors.Is(err, repo.ErrNotFound), errors.Is(err, errNotMember):
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "Lobby not found")
+	case errors.Is(err, errNoBots):
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "Bots are not available")
+	case errors.Is(err, errNotOwner):
+		response.Error(w, r, http.StatusForbidden, response.CodeForbidden, "Only the owner of the lobby can do this")
+	case errors.Is(err, repo.ErrFull):
+		response.Error(w, r, http.StatusConflict, response.CodeLobbyFull, "The lobby is full")
+	case errors.Is(err, errFinished):
+		response.Error(w, r, http.StatusConflict, response.CodeLobbyFinished, "The lobby is over, a single province is left")
+	case errors.As(err, &fieldErrors):
+		validation.WriteError(w, r, fieldErrors)
+	default:
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to process the lobby")
+	}
+}
+
+// statusRecorder remembers the status code a game handler answered a move with
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
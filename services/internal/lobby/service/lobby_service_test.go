@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kahlery/pkg/go/auth/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	auth_model "services/internal/auth/model"
+	"services/internal/core/response"
+	"services/internal/lobby/model"
+	"services/internal/lobby/repo"
+	province_model "services/internal/province/model"
+	province_repo "services/internal/province/repo"
+)
+
+// memoryProvinces keeps the provinces of each lobby in a memory repo of its own
+func memoryProvinces() (ProvinceStores, map[string]*province_repo.MemoryProvinceRepo) {
+	repos := map[string]*province_repo.MemoryProvinceRepo{}
+
+	return func(lobbyID string) ProvinceRepository {
+		if _, ok := repos[lobbyID]; !ok {
+			repos[lobbyID] = province_repo.NewMemoryProvinceRepo()
+		}
+		return repos[lobbyID]
+	}, repos
+}
+
+func TestCreateLobby_CopiesTheMapWithNeighborsAmongTheCopies(t *testing.T) {
+	ankara, izmir, van := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	publicMap := province_repo.NewMemoryProvinceRepo(
+		province_model.Province{ID: ankara, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", AttackCount: 7, DestroymentRound: -1, Neighbors: []string{izmir.Hex(), van.Hex()}},
+		province_model.Province{ID: izmir, ProvinceName: "Izmir", ProvinceColorHex: "#0000ff", DestroymentRound: 2, Neighbors: []string{ankara.Hex()}},
+		province_model.Province{ID: van, ProvinceName: "Van", ProvinceColorHex: "#00ff00", DestroymentRound: -1, Neighbors: []string{ankara.Hex()}},
+	)
+	stores, repos := memoryProvinces()
+	service := NewLobbyService(repo.NewMemoryLobbyRepo(), publicMap, stores)
+
+	owner := primitive.NewObjectID().Hex()
+	ownerToken, err := token.GenerateToken(owner)
+	require.NoError(t, err)
+
+	body := `{"name": "Class 9B", "max_players": 30, "nuke_every_minutes": 45, "province_ids": ["` + ankara.Hex() + `", "` + izmir.Hex() + `"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lobbies", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	rr := httptest.NewRecorder()
+	service.CreateLobby(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var created model.LobbyResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	lobby := created.Lobby
+	assert.Equal(t, []string{owner}, lobby.PlayerIDs)
+	assert.Regexp(t, `^[A-Z2-9]{8}$`, lobby.InviteCode)
+	assert.Equal(t, lobby.StartedAt.Add(45*time.Minute), lobby.NextNukeAt)
+
+	// Fresh, living copies keep only the neighbors copied along
+	provinces, err := repos[lobby.ID.Hex()].GetAll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, provinces, 2)
+	copiedAnkara, copiedIzmir := provinces[0], provinces[1]
+	assert.Equal(t, "Ankara", copiedAnkara.ProvinceName)
+	assert.NotEqual(t, ankara, copiedAnkara.ID)
+	assert.Zero(t, copiedAnkara.AttackCount)
+	assert.Equal(t, -1, copiedIzmir.DestroymentRound)
+	assert.Equal(t, []string{copiedIzmir.ID.Hex()}, copiedAnkara.Neighbors)
+	assert.Equal(t, []string{copiedAnkara.ID.Hex()}, copiedIzmir.Neighbors)
+
+	// The public map is left as it was
+	original, err := publicMap.GetByID(context.Background(), ankara.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, 7, original.AttackCount)
+}
+
+// failingProvinces is a province store that can't take new provinces
+type failingProvinces struct {
+	*province_repo.MemoryProvinceRepo
+}
+
+func (failingProvinces) InsertProvinces(ctx context.Context, provinces ...province_model.Province) error {
+	return errors.New("disk full")
+}
+
+func TestCreateLobby_LeavesNoLobbyWithoutItsMap(t *testing.T) {
+	lobbies := repo.NewMemoryLobbyRepo()
+	service := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), func(lobbyID string) ProvinceRepository {
+		return failingProvinces{province_repo.NewMemoryProvinceRepo()}
+	})
+
+	owner := primitive.NewObjectID().Hex()
+	ownerToken, err := token.GenerateToken(owner)
+	require.NoError(t, err)
+
+	body := `{"name": "Class 9B", "max_players": 30, "nuke_every_minutes": 45, "provinces": [{"province_name": "Narnia", "province_color_hex": "#ff0000"}, {"province_name": "Oz", "province_color_hex": "#00ff00"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/lobbies", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	rr := httptest.NewRecorder()
+	service.CreateLobby(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	owned, err := lobbies.GetByPlayer(context.Background(), owner)
+	require.NoError(t, err)
+	assert.Empty(t, owned)
+}
+
+func TestJoinLobby_RejectsAFinishedLobby(t *testing.T) {
+	ctx := context.Background()
+	lobbies := repo.NewMemoryLobbyRepo()
+	stores, _ := memoryProvinces()
+	service := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), stores)
+
+	lobby := model.Lobby{Name: "Duel", InviteCode: "DU3LDU3L", PlayerIDs: []string{primitive.NewObjectID().Hex()}, MaxPlayers: 2, NukeEveryMinutes: 60, StartedAt: time.Now(), NextNukeAt: time.Now().Add(time.Hour)}
+	require.NoError(t, lobbies.InsertLobby(ctx, &lobby))
+	require.NoError(t, lobbies.FinishLobby(ctx, lobby.ID.Hex(), time.Now()))
+
+	latecomer := primitive.NewObjectID().Hex()
+	latecomerToken, err := token.GenerateToken(latecomer)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/lobbies/join", strings.NewReader(`{"invite_code": "du3ldu3l"}`))
+	req.Header.Set("Authorization", "Bearer "+latecomerToken)
+	rr := httptest.NewRecorder()
+	service.JoinLobby(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), response.CodeLobbyFinished)
+
+	found, err := lobbies.GetByID(ctx, lobby.ID.Hex())
+	require.NoError(t, err)
+	assert.NotContains(t, found.PlayerIDs, latecomer)
+}
+
+func TestNukeDue_NukesEachRoundOnceOnTheLobbySchedule(t *testing.T) {
+	ctx := context.Background()
+	lobbies := repo.NewMemoryLobbyRepo()
+	stores, _ := memoryProvinces()
+
+	// The lobby missed its first nuke, its second one is due
+	now := time.Now().UTC().Truncate(time.Second)
+	start := now.Add(-2*time.Hour - time.Minute)
+	lobby := model.Lobby{Name: "Night shift", InviteCode: "N1GHT5HF", MaxPlayers: 5, NukeEveryMinutes: 60, StartedAt: start, NextNukeAt: start.Add(time.Hour)}
+	require.NoError(t, lobbies.InsertLobby(ctx, &lobby))
+	require.NoError(t, stores(lobby.ID.Hex()).InsertProvinces(ctx,
+		province_model.Province{ProvinceName: "Doomed", AttackCount: 5, DestroymentRound: -1},
+		province_model.Province{ProvinceName: "Loved", SupportCount: 5, DestroymentRound: -1},
+	))
+
+	// Two instances share the lobbies, only one of them nukes the round
+	first := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), stores)
+	second := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), stores)
+	require.NoError(t, first.NukeDue(ctx, now))
+	require.NoError(t, second.NukeDue(ctx, now))
+
+	provinces, err := stores(lobby.ID.Hex()).GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, provinces[0].DestroymentRound, "the round of the lobby's own length")
+	assert.Equal(t, -1, provinces[1].DestroymentRound)
+	assert.Zero(t, provinces[0].AttackCount+provinces[1].SupportCount)
+
+	// Missed rounds aren't made up, the next nuke ends the current round
+	scheduled, err := lobbies.GetByID(ctx, lobby.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(3*time.Hour), scheduled.NextNukeAt)
+
+	round, err := first.Game(*scheduled).GetCurrentRound(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, round)
+}
+
+func TestNukeDue_FinishesTheLobbyOnceOneProvinceIsLeft(t *testing.T) {
+	ctx := context.Background()
+	lobbies := repo.NewMemoryLobbyRepo()
+	stores, _ := memoryProvinces()
+	service := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), stores)
+
+	member := primitive.NewObjectID().Hex()
+	memberToken, err := token.GenerateToken(member)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	start := now.Add(-time.Hour - time.Minute)
+	lobby := model.Lobby{Name: "Duel", InviteCode: "DU3LDU3L", PlayerIDs: []string{member}, MaxPlayers: 2, NukeEveryMinutes: 60, StartedAt: start, NextNukeAt: start.Add(time.Hour)}
+	require.NoError(t, lobbies.InsertLobby(ctx, &lobby))
+	require.NoError(t, stores(lobby.ID.Hex()).InsertProvinces(ctx,
+		province_model.Province{ProvinceName: "Doomed", AttackCount: 5, DestroymentRound: -1},
+		province_model.Province{ProvinceName: "Winner", SupportCount: 5, DestroymentRound: -1},
+	))
+	service.Game(lobby)
+
+	require.NoError(t, service.NukeDue(ctx, now))
+
+	finished, err := lobbies.GetByID(ctx, lobby.ID.Hex())
+	require.NoError(t, err)
+	require.True(t, finished.Finished())
+	assert.Equal(t, now, *finished.FinishedAt)
+	assert.Empty(t, service.games, "the game of a finished lobby isn't kept")
+
+	// No nuke follows, the winner stays alive
+	require.NoError(t, service.NukeDue(ctx, now.Add(3*time.Hour)))
+	provinces, err := stores(lobby.ID.Hex()).GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, -1, provinces[1].DestroymentRound)
+
+	// The board can still be looked at, moves are rejected
+	asMember := func(method, path, body string) *http.Request {
+		req := httptest.NewRequest(method, "/api/lobbies/"+lobby.ID.Hex()+path, strings.NewReader(body))
+		req.SetPathValue("id", lobby.ID.Hex())
+		req.Header.Set("Authorization", "Bearer "+memberToken)
+		return req
+	}
+	rr := httptest.NewRecorder()
+	service.GetProvinces(rr, asMember(http.MethodGet, "/province", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	service.SupportProvince(rr, asMember(http.MethodPost, "/province/support", `{"province_id": "`+provinces[1].ID.Hex()+`"}`))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), response.CodeLobbyFinished)
+	assert.Empty(t, service.games)
+}
+
+func TestNukeDue_DropsIdleGames(t *testing.T) {
+	ctx := context.Background()
+	lobbies := repo.NewMemoryLobbyRepo()
+	stores, _ := memoryProvinces()
+	service := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), stores)
+
+	lobby := model.Lobby{ID: primitive.NewObjectID(), Name: "Weekly", NukeEveryMinutes: 10080, StartedAt: time.Now()}
+	game := service.Game(lobby)
+	assert.Same(t, game, service.Game(lobby))
+
+	require.NoError(t, service.NukeDue(ctx, time.Now()))
+	assert.Len(t, service.games, 1)
+
+	require.NoError(t, service.NukeDue(ctx, time.Now().Add(gameIdleTimeout+time.Minute)))
+	assert.Empty(t, service.games)
+	assert.NotSame(t, game, service.Game(lobby), "an idle game is built again")
+}
+
+func TestSupportProvince_HoldsLobbyMovesToTheCooldown(t *testing.T) {
+	ctx := context.Background()
+	lobbies := repo.NewMemoryLobbyRepo()
+	stores, _ := memoryProvinces()
+	service := NewLobbyService(lobbies, province_repo.NewMemoryProvinceRepo(), stores)
+
+	member := primitive.NewObjectID().Hex()
+	memberToken, err := token.GenerateToken(member)
+	require.NoError(t, err)
+
+	lobby := model.Lobby{Name: "Duel", InviteCode: "DU3LDU3L", PlayerIDs: []string{member}, MaxPlayers: 2, NukeEveryMinutes: 60, StartedAt: time.Now(), NextNukeAt: time.Now().Add(time.Hour)}
+	require.NoError(t, lobbies.InsertLobby(ctx, &lobby))
+	province := province_model.Province{ID: primitive.NewObjectID(), ProvinceName: "Ankara", DestroymentRound: -1}
+	require.NoError(t, stores(lobby.ID.Hex()).InsertProvinces(ctx, province))
+
+	send := func(method, path, body string, handle http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/lobbies/"+lobby.ID.Hex()+path, strings.NewReader(body))
+		req.SetPathValue("id", lobby.ID.Hex())
+		req.Header.Set("Authorization", "Bearer "+memberToken)
+
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		return rr
+	}
+	support := func(provinceID string) *httptest.ResponseRecorder {
+		return send(http.MethodPost, "/province/support", `{"province_id": "`+provinceID+`"}`, service.SupportProvince)
+	}
+	cooldownLeft := func() int {
+		rr := send(http.MethodGet, "/user/cooldown", "", service.GetCooldownLeft)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var cooldown auth_model.CooldownLeftInSecondsResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&cooldown))
+		return cooldown.CooldownLeftInSeconds
+	}
+
+	// A move the game rejects costs no cooldown
+	assert.Equal(t, http.StatusNotFound, support(primitive.NewObjectID().Hex()).Code)
+	assert.Zero(t, cooldownLeft())
+
+	require.Equal(t, http.StatusOK, support(province.ID.Hex()).Code)
+	assert.InDelta(t, (2*time.Minute + 30*time.Second).Seconds(), cooldownLeft(), 1, "an hour long round has 24 moves")
+
+	rr := support(province.ID.Hex())
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), response.CodeCooldownActive)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	supported, err := stores(lobby.ID.Hex()).GetByID(ctx, province.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, 1, supported.SupportCount, "the move in cooldown isn't taken")
+}
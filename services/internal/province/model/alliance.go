@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Alliance is a named pact between provinces. Supporting an ally counts more, attacking
+// one is forbidden, and the pact dissolves as soon as one of its members is nuked. A
+// province the founder invites joins once a player whose home it is accepts
+type Alliance struct {
+	ID          primitive.ObjectID `json:"ID" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Pact        string             `json:"pact" bson:"pact"`                                  // terms the members agreed on
+	ProvinceIDs []string           `json:"province_ids" bson:"provinceIDs"`                   // members, a province is in one alliance at most
+	InvitedIDs  []string           `json:"invited_ids,omitempty" bson:"invitedIDs,omitempty"` // provinces invited that haven't accepted yet
+	FounderID   string             `json:"founder_id" bson:"founderID"`                       // user who formed it, the only one who may change it
+	CreatedAt   time.Time          `json:"created_at" bson:"createdAt"`
+}
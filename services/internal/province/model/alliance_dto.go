@@ -0,0 +1,25 @@
+package model
+
+// --------------------------------------------------------------------
+
+type GetAlliancesResponse struct {
+	Alliances []Alliance `json:"alliances"`
+}
+
+type GetAllianceRequest struct {
+	ID string `json:"id" validate:"required,objectid"`
+}
+
+type AllianceResponse struct {
+	Alliance Alliance `json:"alliance"`
+}
+
+// --------------------------------------------------------------------
+
+// AllianceRequest forms an alliance or replaces one, the founder's home province must be
+// among the provinces. The others are invited, only members listed again stay members
+type AllianceRequest struct {
+	Name        string   `json:"name" validate:"required,min=3,max=40"`
+	Pact        string   `json:"pact" validate:"max=280"`
+	ProvinceIDs []string `json:"province_ids" validate:"required,unique,dive,objectid"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Move is an attack or support as it was counted
+type Move struct {
+	ID         primitive.ObjectID `json:"ID" bson:"_id,omitempty"`
+	ProvinceID string             `json:"province_id" bson:"provinceID"`
+	UserID     string             `json:"user_id,omitempty" bson:"userID,omitempty"` // empty for anonymous moves
+	Kind       string             `json:"kind" bson:"kind"`                          // game.MoveAttack or game.MoveSupport
+	Item       string             `json:"item,omitempty" bson:"item,omitempty"`
+	Weight     int                `json:"weight" bson:"weight"`
+	Modifiers  []string           `json:"modifiers" bson:"modifiers"` // why Weight differs from the base weight
+	Round      int                `json:"round" bson:"round"`
+	CreatedAt  time.Time          `json:"created_at" bson:"createdAt"`
+}
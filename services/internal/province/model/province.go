@@ -9,6 +9,30 @@ type Province struct {
 	AttackCount      int                `json:"attack_count" bson:"attackCount"`
 	SupportCount     int                `json:"support_count" bson:"supportCount"`
 	DestroymentRound int                `json:"destroyment_round" bson:"destroymentRound"`
+	Shielded         bool               `json:"shielded" bson:"shielded"`                       // skipped by the next nuke
+	Neighbors        []string           `json:"neighbors,omitempty" bson:"neighbors,omitempty"` // IDs of the adjacent provinces
+	PreviousScore    float64            `json:"previous_score" bson:"previousScore"`            // score the last round ended with
+	CarriedAttacks   float64            `json:"carried_attacks" bson:"carriedAttacks"`          // decayed attacks of past rounds
+	CarriedSupports  float64            `json:"carried_supports" bson:"carriedSupports"`        // decayed supports of past rounds
+}
+
+// RankedProvince is a province with the score the nuke ranks it by
+type RankedProvince struct {
+	Province
+	Score float64 `json:"score"`
+}
+
+// RoundResult is what a nuke round did
+type RoundResult struct {
+	Round   int        `json:"round"`
+	Victims []Province `json:"victims"` // provinces nuked in the round, worst first
+}
+
+// CountDelta is how much the counts of a province grow, moves batched together
+type CountDelta struct {
+	ProvinceID string
+	Attacks    int
+	Supports   int
 }
 
 func (p Province) MongoIDToStringID(mongoID primitive.ObjectID) (string, error) {
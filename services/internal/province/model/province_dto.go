@@ -6,22 +6,59 @@ type GetAllProvinceResponse struct {
 	ProvinceList []Province `json:"province_list"`
 }
 
+type GetTopProvincesResponse struct {
+	Provinces []RankedProvince `json:"provinces"`
+}
+
+type GetProjectionResponse struct {
+	Round   int              `json:"round"`
+	Scoring string           `json:"scoring"`
+	Victims []RankedProvince `json:"victims"` // provinces the nuke would take if the round ended now, worst first
+}
+
+// --------------------------------------------------------------------
+
+type GetProvinceRequest struct {
+	ID string `json:"id" validate:"required,objectid"`
+}
+
+type GetProvinceResponse struct {
+	Province Province `json:"province"`
+}
+
+// --------------------------------------------------------------------
+
+type GetCurrentRoundResponse struct {
+	Round   int  `json:"round"`
+	Success bool `json:"success"`
+}
+
+type UpdateDestroymentRoundResponse struct {
+	Message    string     `json:"message"`
+	RoundCount int        `json:"round_count"`
+	Victims    []Province `json:"victims"`
+}
+
 // --------------------------------------------------------------------
 
 type AttackProvinceRequest struct {
-	ProvinceID string `json:"province_id"`
+	ProvinceID string `json:"province_id" validate:"required,objectid"`
+	Item       string `json:"item,omitempty" validate:"omitempty,max=32"` // one of the game's items, needs a logged in player
 }
 
 type AttackProvinceResponse struct {
 	IsSuccess bool `json:"is_success"`
+	Move      Move `json:"move"`
 }
 
 // --------------------------------------------------------------------
 
 type SupportProvinceRequest struct {
-	ProvinceID string `json:"province_id"`
+	ProvinceID string `json:"province_id" validate:"required,objectid"`
+	Item       string `json:"item,omitempty" validate:"omitempty,max=32"` // one of the game's items, needs a logged in player
 }
 
 type SupportProvinceResponse struct {
 	IsSuccess bool `json:"is_success"`
+	Move      Move `json:"move"`
 }
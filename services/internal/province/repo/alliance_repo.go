@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"services/internal/core/logging"
+	"services/internal/province/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrAllianceNotFound is returned by every alliance repo implementation when an alliance
+// doesn't exist
+var ErrAllianceNotFound = errors.New("alliance not found")
+
+// AllianceRepo keeps the alliances, one document per alliance listing its members
+type AllianceRepo struct {
+	collection *mongo.Collection
+}
+
+// NewAllianceRepo creates a new alliance repository
+func NewAllianceRepo(collection *mongo.Collection) *AllianceRepo {
+	return &AllianceRepo{
+		collection: collection,
+	}
+}
+
+// GetAll retrieves every alliance, oldest first
+func (ar *AllianceRepo) GetAll(ctx context.Context) ([]model.Alliance, error) {
+	return ar.find(ctx, "GetAll", bson.M{})
+}
+
+// GetByID retrieves a single alliance, ErrAllianceNotFound if it doesn't exist
+func (ar *AllianceRepo) GetByID(ctx context.Context, id string) (*model.Alliance, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ar.findOne(ctx, "GetByID", bson.M{"_id": objectID})
+}
+
+// GetByProvinceID retrieves the alliance a province is a member of, ErrAllianceNotFound
+// if it isn't in one
+func (ar *AllianceRepo) GetByProvinceID(ctx context.Context, provinceID string) (*model.Alliance, error) {
+	return ar.findOne(ctx, "GetByProvinceID", bson.M{"provinceIDs": provinceID})
+}
+
+// InsertAlliance adds an alliance, one without an ID gets one
+func (ar *AllianceRepo) InsertAlliance(ctx context.Context, alliance *model.Alliance) error {
+	if alliance.ID.IsZero() {
+		alliance.ID = primitive.NewObjectID()
+	}
+
+	_, err := ar.collection.InsertOne(ctx, alliance)
+	return ar.fail(ctx, "InsertAlliance", err)
+}
+
+// UpdateAlliance replaces an alliance, ErrAllianceNotFound if it doesn't exist
+func (ar *AllianceRepo) UpdateAlliance(ctx context.Context, alliance model.Alliance) error {
+	result, err := ar.collection.ReplaceOne(ctx, bson.M{"_id": alliance.ID}, alliance)
+	if err != nil {
+		return ar.fail(ctx, "UpdateAlliance", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrAllianceNotFound
+	}
+
+	return nil
+}
+
+// DeleteAlliance removes an alliance, ErrAllianceNotFound if it doesn't exist
+func (ar *AllianceRepo) DeleteAlliance(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := ar.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return ar.fail(ctx, "DeleteAlliance", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrAllianceNotFound
+	}
+
+	return nil
+}
+
+// DissolveAlliancesOf removes the alliances any of the provinces is a member of and
+// returns them
+func (ar *AllianceRepo) DissolveAlliancesOf(ctx context.Context, provinceIDs []string) ([]model.Alliance, error) {
+	if len(provinceIDs) == 0 {
+		return []model.Alliance{}, nil
+	}
+
+	filter := bson.M{"provinceIDs": bson.M{"$in": provinceIDs}}
+	dissolved, err := ar.find(ctx, "DissolveAlliancesOf", filter)
+	if err != nil || len(dissolved) == 0 {
+		return dissolved, err
+	}
+
+	ids := make([]primitive.ObjectID, len(dissolved))
+	for i, alliance := range dissolved {
+		ids[i] = alliance.ID
+	}
+
+	if _, err := ar.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return nil, ar.fail(ctx, "DissolveAlliancesOf", err)
+	}
+
+	return dissolved, nil
+}
+
+func (ar *AllianceRepo) find(ctx context.Context, operation string, filter bson.M) ([]model.Alliance, error) {
+	cursor, err := ar.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, ar.fail(ctx, operation, err)
+	}
+	defer cursor.Close(ctx)
+
+	alliances := []model.Alliance{}
+	if err := cursor.All(ctx, &alliances); err != nil {
+		return nil, ar.fail(ctx, operation, err)
+	}
+
+	return alliances, nil
+}
+
+func (ar *AllianceRepo) findOne(ctx context.Context, operation string, filter bson.M) (*model.Alliance, error) {
+	var alliance model.Alliance
+	err := ar.collection.FindOne(ctx, filter).Decode(&alliance)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAllianceNotFound
+	}
+	if err != nil {
+		return nil, ar.fail(ctx, operation, err)
+	}
+
+	return &alliance, nil
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (ar *AllianceRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Alliance store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
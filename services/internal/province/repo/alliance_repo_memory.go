@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+	"services/internal/province/model"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryAllianceRepo is an in-process AllianceRepo for tests and local runs without MongoDB
+type MemoryAllianceRepo struct {
+	mu        sync.RWMutex
+	alliances []model.Alliance
+}
+
+// NewMemoryAllianceRepo creates an empty in-memory alliance repository
+func NewMemoryAllianceRepo() *MemoryAllianceRepo {
+	return &MemoryAllianceRepo{}
+}
+
+// GetAll returns a copy of every alliance, oldest first
+func (mar *MemoryAllianceRepo) GetAll(ctx context.Context) ([]model.Alliance, error) {
+	mar.mu.RLock()
+	defer mar.mu.RUnlock()
+
+	alliances := make([]model.Alliance, len(mar.alliances))
+	for i, alliance := range mar.alliances {
+		alliances[i] = cloneAlliance(alliance)
+	}
+
+	return alliances, nil
+}
+
+// GetByID returns a copy of a single alliance, ErrAllianceNotFound if it doesn't exist
+func (mar *MemoryAllianceRepo) GetByID(ctx context.Context, id string) (*model.Alliance, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return mar.findOne(func(alliance model.Alliance) bool { return alliance.ID == objectID })
+}
+
+// GetByProvinceID returns a copy of the alliance a province is a member of,
+// ErrAllianceNotFound if it isn't in one
+func (mar *MemoryAllianceRepo) GetByProvinceID(ctx context.Context, provinceID string) (*model.Alliance, error) {
+	return mar.findOne(func(alliance model.Alliance) bool { return slices.Contains(alliance.ProvinceIDs, provinceID) })
+}
+
+// InsertAlliance adds an alliance, one without an ID gets one
+func (mar *MemoryAllianceRepo) InsertAlliance(ctx context.Context, alliance *model.Alliance) error {
+	if alliance.ID.IsZero() {
+		alliance.ID = primitive.NewObjectID()
+	}
+
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	mar.alliances = append(mar.alliances, cloneAlliance(*alliance))
+	return nil
+}
+
+// UpdateAlliance replaces an alliance, ErrAllianceNotFound if it doesn't exist
+func (mar *MemoryAllianceRepo) UpdateAlliance(ctx context.Context, alliance model.Alliance) error {
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	for i := range mar.alliances {
+		if mar.alliances[i].ID == alliance.ID {
+			mar.alliances[i] = cloneAlliance(alliance)
+			return nil
+		}
+	}
+
+	return ErrAllianceNotFound
+}
+
+// DeleteAlliance removes an alliance, ErrAllianceNotFound if it doesn't exist
+func (mar *MemoryAllianceRepo) DeleteAlliance(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	for i := range mar.alliances {
+		if mar.alliances[i].ID == objectID {
+			mar.alliances = slices.Delete(mar.alliances, i, i+1)
+			return nil
+		}
+	}
+
+	return ErrAllianceNotFound
+}
+
+// DissolveAlliancesOf removes the alliances any of the provinces is a member of and
+// returns them
+func (mar *MemoryAllianceRepo) DissolveAlliancesOf(ctx context.Context, provinceIDs []string) ([]model.Alliance, error) {
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	dissolved := []model.Alliance{}
+	mar.alliances = slices.DeleteFunc(mar.alliances, func(alliance model.Alliance) bool {
+		if slices.ContainsFunc(alliance.ProvinceIDs, func(id string) bool { return slices.Contains(provinceIDs, id) }) {
+			dissolved = append(dissolved, alliance)
+			return true
+		}
+		return false
+	})
+
+	return dissolved, nil
+}
+
+func (mar *MemoryAllianceRepo) findOne(match func(model.Alliance) bool) (*model.Alliance, error) {
+	mar.mu.RLock()
+	defer mar.mu.RUnlock()
+
+	for _, alliance := range mar.alliances {
+		if match(alliance) {
+			found := cloneAlliance(alliance)
+			return &found, nil
+		}
+	}
+
+	return nil, ErrAllianceNotFound
+}
+
+// cloneAlliance copies an alliance with its own member and invite lists
+func cloneAlliance(alliance model.Alliance) model.Alliance {
+	alliance.ProvinceIDs = slices.Clone(alliance.ProvinceIDs)
+	alliance.InvitedIDs = slices.Clone(alliance.InvitedIDs)
+	return alliance
+}
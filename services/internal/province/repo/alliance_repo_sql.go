@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"services/internal/core/logging"
+	"services/internal/province/model"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLAllianceRepo is an AllianceRepo on the alliances table of a SQLite or PostgreSQL database
+type SQLAllianceRepo struct {
+	db *sql.DB
+}
+
+// NewSQLAllianceRepo creates an alliance repository on a database opened with sqldb.Open
+func NewSQLAllianceRepo(db *sql.DB) *SQLAllianceRepo {
+	return &SQLAllianceRepo{
+		db: db,
+	}
+}
+
+const selectAlliance = `SELECT id, name, pact, province_ids, invited_ids, founder_id, created_at FROM alliances`
+
+// hasMember matches the alliances listing the province given as $1
+const hasMember = `',' || province_ids || ',' LIKE '%,' || $1 || ',%'`
+
+// GetAll retrieves every alliance, oldest first
+func (sar *SQLAllianceRepo) GetAll(ctx context.Context) ([]model.Alliance, error) {
+	alliances, err := queryAlliances(ctx, sar.db, selectAlliance+` ORDER BY created_at, id`)
+	return alliances, sar.fail(ctx, "GetAll", err)
+}
+
+// GetByID retrieves a single alliance, ErrAllianceNotFound if it doesn't exist
+func (sar *SQLAllianceRepo) GetByID(ctx context.Context, id string) (*model.Alliance, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+
+	return sar.queryOne(ctx, "GetByID", selectAlliance+` WHERE id = $1`, id)
+}
+
+// GetByProvinceID retrieves the alliance a province is a member of, ErrAllianceNotFound
+// if it isn't in one
+func (sar *SQLAllianceRepo) GetByProvinceID(ctx context.Context, provinceID string) (*model.Alliance, error) {
+	return sar.queryOne(ctx, "GetByProvinceID", selectAlliance+` WHERE `+hasMember, provinceID)
+}
+
+// InsertAlliance adds an alliance, one without an ID gets one
+func (sar *SQLAllianceRepo) InsertAlliance(ctx context.Context, alliance *model.Alliance) error {
+	if alliance.ID.IsZero() {
+		alliance.ID = primitive.NewObjectID()
+	}
+
+	_, err := sar.db.ExecContext(ctx,
+		`INSERT INTO alliances (id, name, pact, province_ids, invited_ids, founder_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		alliance.ID.Hex(), alliance.Name, alliance.Pact, strings.Join(alliance.ProvinceIDs, ","), strings.Join(alliance.InvitedIDs, ","), alliance.FounderID, alliance.CreatedAt.UTC())
+	return sar.fail(ctx, "InsertAlliance", err)
+}
+
+// UpdateAlliance replaces an alliance, ErrAllianceNotFound if it doesn't exist
+func (sar *SQLAllianceRepo) UpdateAlliance(ctx context.Context, alliance model.Alliance) error {
+	result, err := sar.db.ExecContext(ctx,
+		`UPDATE alliances SET name = $1, pact = $2, province_ids = $3, invited_ids = $4, founder_id = $5, created_at = $6 WHERE id = $7`,
+		alliance.Name, alliance.Pact, strings.Join(alliance.ProvinceIDs, ","), strings.Join(alliance.InvitedIDs, ","), alliance.FounderID, alliance.CreatedAt.UTC(), alliance.ID.Hex())
+	if err != nil {
+		return sar.fail(ctx, "UpdateAlliance", err)
+	}
+
+	return sar.matched(ctx, "UpdateAlliance", result)
+}
+
+// DeleteAlliance removes an alliance, ErrAllianceNotFound if it doesn't exist
+func (sar *SQLAllianceRepo) DeleteAlliance(ctx context.Context, id string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return err
+	}
+
+	result, err := sar.db.ExecContext(ctx, `DELETE FROM alliances WHERE id = $1`, id)
+	if err != nil {
+		return sar.fail(ctx, "DeleteAlliance", err)
+	}
+
+	return sar.matched(ctx, "DeleteAlliance", result)
+}
+
+// DissolveAlliancesOf removes the alliances any of the provinces is a member of and
+// returns them, in one transaction
+func (sar *SQLAllianceRepo) DissolveAlliancesOf(ctx context.Context, provinceIDs []string) ([]model.Alliance, error) {
+	dissolved := []model.Alliance{}
+
+	tx, err := sar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, sar.fail(ctx, "DissolveAlliancesOf", err)
+	}
+
+	for _, provinceID := range provinceIDs {
+		alliances, err := queryAlliances(ctx, tx, selectAlliance+` WHERE `+hasMember, provinceID)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `DELETE FROM alliances WHERE `+hasMember, provinceID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, sar.fail(ctx, "DissolveAlliancesOf", err)
+		}
+		dissolved = append(dissolved, alliances...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, sar.fail(ctx, "DissolveAlliancesOf", err)
+	}
+
+	return dissolved, nil
+}
+
+func (sar *SQLAllianceRepo) queryOne(ctx context.Context, operation, query string, args ...any) (*model.Alliance, error) {
+	alliances, err := queryAlliances(ctx, sar.db, query, args...)
+	if err != nil {
+		return nil, sar.fail(ctx, operation, err)
+	}
+	if len(alliances) == 0 {
+		return nil, ErrAllianceNotFound
+	}
+
+	return &alliances[0], nil
+}
+
+// matched tells ErrAllianceNotFound apart from a write that changed a row
+func (sar *SQLAllianceRepo) matched(ctx context.Context, operation string, result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return sar.fail(ctx, operation, err)
+	}
+	if affected == 0 {
+		return ErrAllianceNotFound
+	}
+
+	return nil
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (sar *SQLAllianceRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Alliance store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
+
+// querier is a *sql.DB or a *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// queryAlliances reads the alliances selected with selectAlliance
+func queryAlliances(ctx context.Context, db querier, query string, args ...any) ([]model.Alliance, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alliances := []model.Alliance{}
+	for rows.Next() {
+		var a model.Alliance
+		var id, provinceIDs, invitedIDs string
+		if err := rows.Scan(&id, &a.Name, &a.Pact, &provinceIDs, &invitedIDs, &a.FounderID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if a.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, err
+		}
+		a.ProvinceIDs = []string{}
+		if provinceIDs != "" {
+			a.ProvinceIDs = strings.Split(provinceIDs, ",")
+		}
+		if invitedIDs != "" {
+			a.InvitedIDs = strings.Split(invitedIDs, ",")
+		}
+		a.CreatedAt = a.CreatedAt.UTC()
+
+		alliances = append(alliances, a)
+	}
+
+	return alliances, rows.Err()
+}
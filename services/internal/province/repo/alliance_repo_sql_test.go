@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/core/sqldb/sqldbtest"
+	"services/internal/province/model"
+)
+
+func TestSQLAllianceRepo(t *testing.T) {
+	sqldbtest.Run(t, testSQLAllianceRepo)
+}
+
+func testSQLAllianceRepo(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	sar := NewSQLAllianceRepo(db)
+
+	ankara, izmir, van := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	now := time.Now().UTC().Truncate(time.Second)
+	west := model.Alliance{Name: "West", Pact: "No attacks before noon", ProvinceIDs: []string{ankara, izmir}, FounderID: primitive.NewObjectID().Hex(), CreatedAt: now}
+	require.NoError(t, sar.InsertAlliance(ctx, &west))
+	assert.False(t, west.ID.IsZero())
+
+	found, err := sar.GetByProvinceID(ctx, izmir)
+	require.NoError(t, err)
+	assert.Equal(t, west, *found)
+	_, err = sar.GetByProvinceID(ctx, van)
+	assert.ErrorIs(t, err, ErrAllianceNotFound)
+
+	// An invited province isn't a member yet
+	west.ProvinceIDs, west.InvitedIDs = []string{ankara}, []string{van}
+	require.NoError(t, sar.UpdateAlliance(ctx, west))
+	found, err = sar.GetByID(ctx, west.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, []string{van}, found.InvitedIDs)
+	_, err = sar.GetByProvinceID(ctx, van)
+	assert.ErrorIs(t, err, ErrAllianceNotFound)
+
+	west.ProvinceIDs, west.InvitedIDs = []string{ankara, van}, nil
+	require.NoError(t, sar.UpdateAlliance(ctx, west))
+	found, err = sar.GetByID(ctx, west.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, []string{ankara, van}, found.ProvinceIDs)
+	assert.Empty(t, found.InvitedIDs)
+	assert.ErrorIs(t, sar.UpdateAlliance(ctx, model.Alliance{ID: primitive.NewObjectID()}), ErrAllianceNotFound)
+
+	// Nuking Van dissolves West, East has no nuked member
+	east := model.Alliance{Name: "East", ProvinceIDs: []string{izmir, primitive.NewObjectID().Hex()}, CreatedAt: now.Add(time.Second)}
+	require.NoError(t, sar.InsertAlliance(ctx, &east))
+	dissolved, err := sar.DissolveAlliancesOf(ctx, []string{van})
+	require.NoError(t, err)
+	require.Len(t, dissolved, 1)
+	assert.Equal(t, "West", dissolved[0].Name)
+
+	alliances, err := sar.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, alliances, 1)
+	assert.Equal(t, "East", alliances[0].Name)
+
+	require.NoError(t, sar.DeleteAlliance(ctx, east.ID.Hex()))
+	assert.ErrorIs(t, sar.DeleteAlliance(ctx, east.ID.Hex()), ErrAllianceNotFound)
+}
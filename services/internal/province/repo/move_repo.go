@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"services/internal/core/logging"
+	"services/internal/province/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MoveRepo keeps the moves of every player, one document per move
+type MoveRepo struct {
+	collection *mongo.Collection
+}
+
+// NewMoveRepo creates a new move repository
+func NewMoveRepo(collection *mongo.Collection) *MoveRepo {
+	return &MoveRepo{
+		collection: collection,
+	}
+}
+
+// RecordMoves inserts moves in one write
+func (mr *MoveRepo) RecordMoves(ctx context.Context, moves []model.Move) error {
+	if len(moves) == 0 {
+		return nil
+	}
+
+	documents := make([]any, len(moves))
+	for i, move := range moves {
+		documents[i] = move
+	}
+
+	_, err := mr.collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	return mr.fail(ctx, "RecordMoves", err)
+}
+
+// GetMovesByRound retrieves the moves made in a round, oldest first
+func (mr *MoveRepo) GetMovesByRound(ctx context.Context, round int) ([]model.Move, error) {
+	cursor, err := mr.collection.Find(ctx, bson.M{"round": round}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, mr.fail(ctx, "GetMovesByRound", err)
+	}
+	defer cursor.Close(ctx)
+
+	moves := []model.Move{}
+	if err := cursor.All(ctx, &moves); err != nil {
+		return nil, mr.fail(ctx, "GetMovesByRound", err)
+	}
+
+	return moves, nil
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (mr *MoveRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Move store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
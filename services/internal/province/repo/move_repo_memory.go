@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"services/internal/province/model"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryMoveRepo is an in-process MoveRepo for tests and local runs without MongoDB
+type MemoryMoveRepo struct {
+	mu    sync.RWMutex
+	moves []model.Move
+}
+
+// NewMemoryMoveRepo creates an empty in-memory move repository
+func NewMemoryMoveRepo() *MemoryMoveRepo {
+	return &MemoryMoveRepo{}
+}
+
+// RecordMoves appends moves, moves without an ID get one
+func (mmr *MemoryMoveRepo) RecordMoves(ctx context.Context, moves []model.Move) error {
+	mmr.mu.Lock()
+	defer mmr.mu.Unlock()
+
+	for _, move := range moves {
+		if move.ID.IsZero() {
+			move.ID = primitive.NewObjectID()
+		}
+		mmr.moves = append(mmr.moves, move)
+	}
+
+	return nil
+}
+
+// GetMovesByRound returns a copy of the moves made in a round, oldest first
+func (mmr *MemoryMoveRepo) GetMovesByRound(ctx context.Context, round int) ([]model.Move, error) {
+	mmr.mu.RLock()
+	defer mmr.mu.RUnlock()
+
+	moves := []model.Move{}
+	for _, move := range mmr.moves {
+		if move.Round == round {
+			moves = append(moves, move)
+		}
+	}
+
+	return moves, nil
+}
@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"services/internal/core/logging"
+	"services/internal/province/model"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLMoveRepo is a MoveRepo on the moves table of a SQLite or PostgreSQL database
+type SQLMoveRepo struct {
+	db *sql.DB
+}
+
+// NewSQLMoveRepo creates a move repository on a database opened with sqldb.Open
+func NewSQLMoveRepo(db *sql.DB) *SQLMoveRepo {
+	return &SQLMoveRepo{
+		db: db,
+	}
+}
+
+// RecordMoves inserts moves in one transaction, moves without an ID get one
+func (smr *SQLMoveRepo) RecordMoves(ctx context.Context, moves []model.Move) error {
+	if len(moves) == 0 {
+		return nil
+	}
+
+	tx, err := smr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return smr.fail(ctx, "RecordMoves", err)
+	}
+
+	for _, m := range moves {
+		if m.ID.IsZero() {
+			m.ID = primitive.NewObjectID()
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO moves (id, province_id, user_id, kind, item, weight, modifiers, round, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			m.ID.Hex(), m.ProvinceID, m.UserID, m.Kind, m.Item, m.Weight, strings.Join(m.Modifiers, ","), m.Round, m.CreatedAt.UTC())
+		if err != nil {
+			tx.Rollback()
+			return smr.fail(ctx, "RecordMoves", err)
+		}
+	}
+
+	return smr.fail(ctx, "RecordMoves", tx.Commit())
+}
+
+// GetMovesByRound retrieves the moves made in a round, oldest first
+func (smr *SQLMoveRepo) GetMovesByRound(ctx context.Context, round int) ([]model.Move, error) {
+	rows, err := smr.db.QueryContext(ctx,
+		`SELECT id, province_id, user_id, kind, item, weight, modifiers, round, created_at FROM moves WHERE round = $1 ORDER BY created_at, id`,
+		round)
+	if err != nil {
+		return nil, smr.fail(ctx, "GetMovesByRound", err)
+	}
+	defer rows.Close()
+
+	moves := []model.Move{}
+	for rows.Next() {
+		var m model.Move
+		var id, modifiers string
+		if err := rows.Scan(&id, &m.ProvinceID, &m.UserID, &m.Kind, &m.Item, &m.Weight, &modifiers, &m.Round, &m.CreatedAt); err != nil {
+			return nil, smr.fail(ctx, "GetMovesByRound", err)
+		}
+
+		if m.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, smr.fail(ctx, "GetMovesByRound", err)
+		}
+		m.Modifiers = []string{}
+		if modifiers != "" {
+			m.Modifiers = strings.Split(modifiers, ",")
+		}
+		m.CreatedAt = m.CreatedAt.UTC()
+
+		moves = append(moves, m)
+	}
+
+	return moves, smr.fail(ctx, "GetMovesByRound", rows.Err())
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (smr *SQLMoveRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Move store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
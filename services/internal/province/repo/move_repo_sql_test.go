@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/core/sqldb/sqldbtest"
+	"services/internal/province/model"
+)
+
+func TestSQLMoveRepo_RecordMoves(t *testing.T) {
+	sqldbtest.Run(t, testSQLMoveRepo_RecordMoves)
+}
+
+func testSQLMoveRepo_RecordMoves(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	smr := NewSQLMoveRepo(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	weighted := model.Move{ID: primitive.NewObjectID(), ProvinceID: primitive.NewObjectID().Hex(), UserID: primitive.NewObjectID().Hex(), Kind: "attack", Item: "double_strike", Weight: 4, Modifiers: []string{"streak", "double_strike"}, Round: 3, CreatedAt: now}
+	anonymous := model.Move{ID: primitive.NewObjectID(), ProvinceID: weighted.ProvinceID, Kind: "support", Weight: 1, Modifiers: []string{}, Round: 3, CreatedAt: now.Add(time.Second)}
+
+	require.NoError(t, smr.RecordMoves(ctx, []model.Move{weighted, anonymous, {ProvinceID: weighted.ProvinceID, Kind: "attack", Weight: 1, Round: 2, CreatedAt: now}}))
+	require.NoError(t, smr.RecordMoves(ctx, nil))
+
+	moves, err := smr.GetMovesByRound(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Move{weighted, anonymous}, moves)
+
+	moves, err = smr.GetMovesByRound(ctx, 7)
+	require.NoError(t, err)
+	assert.Empty(t, moves)
+}
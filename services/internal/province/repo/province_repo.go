@@ -2,13 +2,20 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"services/internal/core/logging"
 	"services/internal/province/model"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrNotFound is returned by every province repo implementation when a province doesn't exist
+var ErrNotFound = errors.New("province not found")
+
 type ProvinceRepo struct {
 	collection *mongo.Collection
 }
@@ -22,10 +29,10 @@ func NewProvinceRepo(collection *mongo.Collection) *ProvinceRepo {
 
 // GetAll retrieves all provinces from the database
 func (pr *ProvinceRepo) GetAll(ctx context.Context) ([]model.Province, error) {
-	var provinces []model.Province
+	provinces := []model.Province{}
 	cursor, err := pr.collection.Find(ctx, bson.M{})
 	if err != nil {
-		return nil, err
+		return nil, pr.fail(ctx, "GetAll", err)
 	}
 	defer cursor.Close(ctx)
 
@@ -33,15 +40,33 @@ func (pr *ProvinceRepo) GetAll(ctx context.Context) ([]model.Province, error) {
 		var p model.Province
 		// Raw data
 		if err := cursor.Decode(&p); err != nil {
-			return nil, err
+			return nil, pr.fail(ctx, "GetAll", err)
 		}
 		provinces = append(provinces, p)
 	}
 	return provinces, nil
 }
 
-// UpdateProvinceByID updates the attack or support count for a province
-func (pr *ProvinceRepo) UpdateProvinceByID(ctx context.Context, id string, isAttackNorSupport bool) error {
+// GetByID retrieves a single province, ErrNotFound if it doesn't exist
+func (pr *ProvinceRepo) GetByID(ctx context.Context, id string) (*model.Province, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var province model.Province
+	if err := pr.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&province); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, pr.fail(ctx, "GetByID", err)
+	}
+
+	return &province, nil
+}
+
+// UpdateProvinceByID adds weight to the attack or support count for a province
+func (pr *ProvinceRepo) UpdateProvinceByID(ctx context.Context, id string, isAttackNorSupport bool, weight int) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
@@ -50,118 +75,180 @@ func (pr *ProvinceRepo) UpdateProvinceByID(ctx context.Context, id string, isAtt
 	var update bson.M
 	if isAttackNorSupport {
 		update = bson.M{
-			"$inc": bson.M{"attackCount": 1},
+			"$inc": bson.M{"attackCount": weight},
 		}
 	} else {
 		update = bson.M{
-			"$inc": bson.M{"supportCount": 1},
+			"$inc": bson.M{"supportCount": weight},
 		}
 	}
 
 	filter := bson.M{"_id": objectID}
-	_, err = pr.collection.UpdateOne(ctx, filter, update)
-	// return error if ID format is invalid
-	return err
-}
-
-// GetProvincesByScoreDifference retrieves provinces sorted by the difference between attackCount and supportCount
-func (pr *ProvinceRepo) GetProvincesByScoreDifference(ctx context.Context) ([]model.Province, error) {
-	// Create a pipeline to calculate difference and sort
-	pipeline := []bson.M{
-		{
-			"$addFields": bson.M{
-				"scoreDifference": bson.M{
-					"$subtract": []string{"$attackCount", "$supportCount"},
-				},
-			},
-		},
-		{
-			"$sort": bson.M{"scoreDifference": -1}, // Sort by difference in descending order
-		},
+	result, err := pr.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return pr.fail(ctx, "UpdateProvinceByID", err)
 	}
 
-	// Execute the aggregation
-	cursor, err := pr.collection.Aggregate(ctx, pipeline)
-	if err != nil {
-		return nil, err
+	if result.MatchedCount == 0 {
+		return ErrNotFound
 	}
-	defer cursor.Close(ctx)
 
-	// Iterate through the results
-	var provinces []model.Province
-	for cursor.Next(ctx) {
-		var p model.Province
-		if err := cursor.Decode(&p); err != nil {
-			return nil, err
+	return nil
+}
+
+// ApplyCountDeltas adds batched moves to the counts of several provinces in one bulk
+// write, provinces that don't exist are skipped
+func (pr *ProvinceRepo) ApplyCountDeltas(ctx context.Context, deltas []model.CountDelta) error {
+	models := make([]mongo.WriteModel, 0, len(deltas))
+	for _, delta := range deltas {
+		objectID, err := primitive.ObjectIDFromHex(delta.ProvinceID)
+		if err != nil {
+			return err
 		}
-		provinces = append(provinces, p)
-	}
 
-	if err := cursor.Err(); err != nil {
-		return nil, err
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objectID}).
+			SetUpdate(bson.M{"$inc": bson.M{"attackCount": delta.Attacks, "supportCount": delta.Supports}}))
+	}
+	if len(models) == 0 {
+		return nil
 	}
 
-	return provinces, nil
+	// Unordered, one failed update doesn't hold back the others
+	_, err := pr.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return pr.fail(ctx, "ApplyCountDeltas", err)
 }
 
-// UpdateDestroymentRoundOfTheWorstProvince finds the province with the highest (attackCount - supportCount)
-// and sets its destroymentRound to the given round count
-func (pr *ProvinceRepo) UpdateDestroymentRoundOfTheWorstProvince(ctx context.Context, roundCount int) error {
-	// Create aggregation pipeline to find the province with highest score difference
-	pipeline := []bson.M{
-		{
-			"$addFields": bson.M{
-				"scoreDifference": bson.M{
-					"$subtract": []string{"$attackCount", "$supportCount"},
-				},
-			},
-		},
-		{
-			"$sort": bson.M{"scoreDifference": -1}, // Sort by difference in descending order
-		},
-		{
-			"$limit": 1, // Get only the worst province
-		},
+// ShieldProvince shields a living province once its support count reached minSupports,
+// shielded tells whether it wasn't shielded before
+func (pr *ProvinceRepo) ShieldProvince(ctx context.Context, id string, minSupports int) (shielded bool, err error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
 	}
 
-	// Execute the aggregation to find the worst province
-	cursor, err := pr.collection.Aggregate(ctx, pipeline)
+	filter := bson.M{
+		"_id":              objectID,
+		"supportCount":     bson.M{"$gte": minSupports},
+		"destroymentRound": -1,
+		"shielded":         bson.M{"$ne": true},
+	}
+	result, err := pr.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"shielded": true}})
 	if err != nil {
-		return err
+		return false, pr.fail(ctx, "ShieldProvince", err)
 	}
-	defer cursor.Close(ctx)
 
-	// Get the worst province
-	var worstProvince model.Province
-	if cursor.Next(ctx) {
-		if err := cursor.Decode(&worstProvince); err != nil {
+	return result.ModifiedCount > 0, nil
+}
+
+// NukeProvinces sets the destroymentRound of the living provinces among ids
+func (pr *ProvinceRepo) NukeProvinces(ctx context.Context, roundCount int, ids []string) error {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
 			return err
 		}
-	} else {
+		objectIDs = append(objectIDs, objectID)
+	}
+	if len(objectIDs) == 0 {
 		return nil
 	}
 
-	// Update the destroyment round of the worst province
-	filter := bson.M{"_id": worstProvince.ID}
-	update := bson.M{
-		"$set": bson.M{"destroymentRound": roundCount},
+	filter := bson.M{"_id": bson.M{"$in": objectIDs}, "destroymentRound": -1}
+	update := bson.M{"$set": bson.M{"destroymentRound": roundCount}}
+
+	_, err := pr.collection.UpdateMany(ctx, filter, update)
+	return pr.fail(ctx, "NukeProvinces", err)
+}
+
+// SetPreviousScores stores the score each province ended the round with, by ID, in one
+// bulk write
+func (pr *ProvinceRepo) SetPreviousScores(ctx context.Context, scores map[string]float64) error {
+	models := make([]mongo.WriteModel, 0, len(scores))
+	for id, score := range scores {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objectID}).
+			SetUpdate(bson.M{"$set": bson.M{"previousScore": score}}))
+	}
+	if len(models) == 0 {
+		return nil
 	}
 
-	_, err = pr.collection.UpdateOne(ctx, filter, update)
-	return err
+	_, err := pr.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return pr.fail(ctx, "SetPreviousScores", err)
 }
 
-// ResetAllProvinceCounts resets attackCount and supportCount to 0 for all provinces
+// ResetAllProvinceCounts resets attackCount, supportCount and the carried counts to 0 and
+// drops the shields of all provinces
 func (pr *ProvinceRepo) ResetAllProvinceCounts(ctx context.Context) error {
 	filter := bson.M{} // Empty filter to match all documents
 	update := bson.M{
 		"$set": bson.M{
-			"attackCount":  0,
-			"supportCount": 0,
+			"attackCount":     0,
+			"supportCount":    0,
+			"carriedAttacks":  0,
+			"carriedSupports": 0,
+			"shielded":        false,
 		},
 	}
 
 	// Use UpdateMany to update all provinces
 	_, err := pr.collection.UpdateMany(ctx, filter, update)
+	return pr.fail(ctx, "ResetAllProvinceCounts", err)
+}
+
+// CarryProvinceCounts carries decay of every province's counts, the carried ones
+// included, into its carried counts, then resets attackCount and supportCount to 0 and
+// drops the shields
+func (pr *ProvinceRepo) CarryProvinceCounts(ctx context.Context, decay float64) error {
+	carried := func(count, carried string) bson.M {
+		return bson.M{"$multiply": bson.A{decay, bson.M{"$add": bson.A{"$" + count, bson.M{"$ifNull": bson.A{"$" + carried, 0}}}}}}
+	}
+
+	// An update pipeline, the carried counts are computed from the counts before the reset
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"carriedAttacks":  carried("attackCount", "carriedAttacks"),
+		"carriedSupports": carried("supportCount", "carriedSupports"),
+		"attackCount":     0,
+		"supportCount":    0,
+		"shielded":        false,
+	}}}}
+
+	_, err := pr.collection.UpdateMany(ctx, bson.M{}, update)
+	return pr.fail(ctx, "CarryProvinceCounts", err)
+}
+
+// InsertProvinces adds provinces, for seeding a new game. Provinces without an ID get one
+func (pr *ProvinceRepo) InsertProvinces(ctx context.Context, provinces ...model.Province) error {
+	if len(provinces) == 0 {
+		return nil
+	}
+
+	documents := make([]any, len(provinces))
+	for i, p := range provinces {
+		if p.ID.IsZero() {
+			p.ID = primitive.NewObjectID()
+		}
+		documents[i] = p
+	}
+
+	_, err := pr.collection.InsertMany(ctx, documents)
+	return pr.fail(ctx, "InsertProvinces", err)
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (pr *ProvinceRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Province store operation failed", "operation", operation, logging.Err(err))
 	return err
 }
@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"context"
+	"services/internal/province/model"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryProvinceRepo is an in-process ProvinceRepo for tests and local runs without MongoDB
+type MemoryProvinceRepo struct {
+	mu        sync.RWMutex
+	provinces []model.Province
+}
+
+// NewMemoryProvinceRepo creates an in-memory province repository seeded with the given provinces
+func NewMemoryProvinceRepo(provinces ...model.Province) *MemoryProvinceRepo {
+	mpr := &MemoryProvinceRepo{}
+
+	for _, p := range provinces {
+		if p.ID.IsZero() {
+			p.ID = primitive.NewObjectID()
+		}
+		mpr.provinces = append(mpr.provinces, p)
+	}
+
+	return mpr
+}
+
+// GetAll returns a copy of all provinces in insertion order
+func (mpr *MemoryProvinceRepo) GetAll(ctx context.Context) ([]model.Province, error) {
+	mpr.mu.RLock()
+	defer mpr.mu.RUnlock()
+
+	return append([]model.Province{}, mpr.provinces...), nil
+}
+
+// GetByID returns a copy of a single province, ErrNotFound if it doesn't exist
+func (mpr *MemoryProvinceRepo) GetByID(ctx context.Context, id string) (*model.Province, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	mpr.mu.RLock()
+	defer mpr.mu.RUnlock()
+
+	for _, p := range mpr.provinces {
+		if p.ID == objectID {
+			return &p, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// UpdateProvinceByID adds weight to the attack or support count of a province
+func (mpr *MemoryProvinceRepo) UpdateProvinceByID(ctx context.Context, id string, isAttackNorSupport bool, weight int) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for i := range mpr.provinces {
+		if mpr.provinces[i].ID != objectID {
+			continue
+		}
+
+		if isAttackNorSupport {
+			mpr.provinces[i].AttackCount += weight
+		} else {
+			mpr.provinces[i].SupportCount += weight
+		}
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+// ApplyCountDeltas adds batched moves to the counts of several provinces, provinces
+// that don't exist are skipped
+func (mpr *MemoryProvinceRepo) ApplyCountDeltas(ctx context.Context, deltas []model.CountDelta) error {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for _, delta := range deltas {
+		objectID, err := primitive.ObjectIDFromHex(delta.ProvinceID)
+		if err != nil {
+			return err
+		}
+
+		for i := range mpr.provinces {
+			if mpr.provinces[i].ID == objectID {
+				mpr.provinces[i].AttackCount += delta.Attacks
+				mpr.provinces[i].SupportCount += delta.Supports
+			}
+		}
+	}
+
+	return nil
+}
+
+// ShieldProvince shields a living province once its support count reached minSupports,
+// shielded tells whether it wasn't shielded before
+func (mpr *MemoryProvinceRepo) ShieldProvince(ctx context.Context, id string, minSupports int) (shielded bool, err error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for i := range mpr.provinces {
+		p := &mpr.provinces[i]
+		if p.ID == objectID && p.SupportCount >= minSupports && p.DestroymentRound == -1 && !p.Shielded {
+			p.Shielded = true
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// NukeProvinces sets the destroyment round of the living provinces among ids
+func (mpr *MemoryProvinceRepo) NukeProvinces(ctx context.Context, roundCount int, ids []string) error {
+	nuked := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		nuked[objectID] = true
+	}
+
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for i := range mpr.provinces {
+		if nuked[mpr.provinces[i].ID] && mpr.provinces[i].DestroymentRound == -1 {
+			mpr.provinces[i].DestroymentRound = roundCount
+		}
+	}
+
+	return nil
+}
+
+// SetPreviousScores stores the score each province ended the round with, by ID
+func (mpr *MemoryProvinceRepo) SetPreviousScores(ctx context.Context, scores map[string]float64) error {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for i := range mpr.provinces {
+		if score, ok := scores[mpr.provinces[i].ID.Hex()]; ok {
+			mpr.provinces[i].PreviousScore = score
+		}
+	}
+
+	return nil
+}
+
+// ResetAllProvinceCounts resets attackCount, supportCount and the carried counts to 0 and
+// drops the shields of all provinces
+func (mpr *MemoryProvinceRepo) ResetAllProvinceCounts(ctx context.Context) error {
+	return mpr.CarryProvinceCounts(ctx, 0)
+}
+
+// CarryProvinceCounts carries decay of every province's counts, the carried ones
+// included, into its carried counts, then resets attackCount and supportCount to 0 and
+// drops the shields
+func (mpr *MemoryProvinceRepo) CarryProvinceCounts(ctx context.Context, decay float64) error {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for i := range mpr.provinces {
+		p := &mpr.provinces[i]
+		p.CarriedAttacks = decay * (float64(p.AttackCount) + p.CarriedAttacks)
+		p.CarriedSupports = decay * (float64(p.SupportCount) + p.CarriedSupports)
+		p.AttackCount = 0
+		p.SupportCount = 0
+		p.Shielded = false
+	}
+
+	return nil
+}
+
+// InsertProvinces adds provinces, for seeding a new game. Provinces without an ID get one
+func (mpr *MemoryProvinceRepo) InsertProvinces(ctx context.Context, provinces ...model.Province) error {
+	mpr.mu.Lock()
+	defer mpr.mu.Unlock()
+
+	for _, p := range provinces {
+		if p.ID.IsZero() {
+			p.ID = primitive.NewObjectID()
+		}
+		mpr.provinces = append(mpr.provinces, p)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"services/internal/core/logging"
+	"services/internal/province/model"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLProvinceRepo is a ProvinceRepo on the provinces table of a SQLite or PostgreSQL
+// database. The table holds the provinces of every game, a repo only sees the ones of its own
+type SQLProvinceRepo struct {
+	db      *sql.DB
+	lobbyID string // "" for the public game
+}
+
+// NewSQLProvinceRepo creates a repository of the public game's provinces on a database
+// opened with sqldb.Open
+func NewSQLProvinceRepo(db *sql.DB) *SQLProvinceRepo {
+	return NewSQLLobbyProvinceRepo(db, "")
+}
+
+// NewSQLLobbyProvinceRepo creates a repository of the provinces of a private lobby
+func NewSQLLobbyProvinceRepo(db *sql.DB, lobbyID string) *SQLProvinceRepo {
+	return &SQLProvinceRepo{
+		db:      db,
+		lobbyID: lobbyID,
+	}
+}
+
+const selectProvince = `SELECT id, province_name, province_color_hex, attack_count, support_count, destroyment_round, shielded, neighbors, previous_score, carried_attacks, carried_supports FROM provinces`
+
+// GetAll retrieves all provinces in insertion order
+func (spr *SQLProvinceRepo) GetAll(ctx context.Context) ([]model.Province, error) {
+	provinces, err := spr.query(ctx, selectProvince+` WHERE lobby_id = $1 ORDER BY id`, spr.lobbyID)
+	return provinces, spr.fail(ctx, "GetAll", err)
+}
+
+// GetByID retrieves a single province, ErrNotFound if it doesn't exist
+func (spr *SQLProvinceRepo) GetByID(ctx context.Context, id string) (*model.Province, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+
+	province, err := scanProvince(spr.db.QueryRowContext(ctx, selectProvince+` WHERE id = $1 AND lobby_id = $2`, id, spr.lobbyID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, spr.fail(ctx, "GetByID", err)
+	}
+
+	return &province, nil
+}
+
+// UpdateProvinceByID adds weight to the attack or support count of a province
+func (spr *SQLProvinceRepo) UpdateProvinceByID(ctx context.Context, id string, isAttackNorSupport bool, weight int) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return err
+	}
+
+	query := `UPDATE provinces SET support_count = support_count + $2 WHERE id = $1 AND lobby_id = $3`
+	if isAttackNorSupport {
+		query = `UPDATE provinces SET attack_count = attack_count + $2 WHERE id = $1 AND lobby_id = $3`
+	}
+
+	result, err := spr.db.ExecContext(ctx, query, id, weight, spr.lobbyID)
+	if err != nil {
+		return spr.fail(ctx, "UpdateProvinceByID", err)
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return spr.fail(ctx, "UpdateProvinceByID", err)
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ApplyCountDeltas adds batched moves to the counts of several provinces in one
+// transaction, provinces that don't exist are skipped
+func (spr *SQLProvinceRepo) ApplyCountDeltas(ctx context.Context, deltas []model.CountDelta) error {
+	for _, delta := range deltas {
+		if _, err := primitive.ObjectIDFromHex(delta.ProvinceID); err != nil {
+			return err
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	return spr.fail(ctx, "ApplyCountDeltas", spr.inTx(ctx, func(tx *sql.Tx) error {
+		for _, delta := range deltas {
+			_, err := tx.ExecContext(ctx,
+				`UPDATE provinces SET attack_count = attack_count + $1, support_count = support_count + $2 WHERE id = $3 AND lobby_id = $4`,
+				delta.Attacks, delta.Supports, delta.ProvinceID, spr.lobbyID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// ShieldProvince shields a living province once its support count reached minSupports,
+// shielded tells whether it wasn't shielded before
+func (spr *SQLProvinceRepo) ShieldProvince(ctx context.Context, id string, minSupports int) (shielded bool, err error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, err
+	}
+
+	result, err := spr.db.ExecContext(ctx,
+		`UPDATE provinces SET shielded = TRUE WHERE id = $1 AND lobby_id = $3 AND support_count >= $2 AND destroyment_round = -1 AND NOT shielded`,
+		id, minSupports, spr.lobbyID)
+	if err != nil {
+		return false, spr.fail(ctx, "ShieldProvince", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, spr.fail(ctx, "ShieldProvince", err)
+	}
+
+	return affected > 0, nil
+}
+
+// NukeProvinces sets the destroymentRound of the living provinces among ids
+func (spr *SQLProvinceRepo) NukeProvinces(ctx context.Context, roundCount int, ids []string) error {
+	for _, id := range ids {
+		if _, err := primitive.ObjectIDFromHex(id); err != nil {
+			return err
+		}
+	}
+
+	return spr.fail(ctx, "NukeProvinces", spr.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, `UPDATE provinces SET destroyment_round = $1 WHERE id = $2 AND lobby_id = $3 AND destroyment_round = -1`, roundCount, id, spr.lobbyID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// SetPreviousScores stores the score each province ended the round with, by ID, in one
+// transaction
+func (spr *SQLProvinceRepo) SetPreviousScores(ctx context.Context, scores map[string]float64) error {
+	return spr.fail(ctx, "SetPreviousScores", spr.inTx(ctx, func(tx *sql.Tx) error {
+		for id, score := range scores {
+			if _, err := tx.ExecContext(ctx, `UPDATE provinces SET previous_score = $1 WHERE id = $2 AND lobby_id = $3`, score, id, spr.lobbyID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// ResetAllProvinceCounts resets attackCount, supportCount and the carried counts to 0 and
+// drops the shields of all provinces
+func (spr *SQLProvinceRepo) ResetAllProvinceCounts(ctx context.Context) error {
+	_, err := spr.db.ExecContext(ctx, `UPDATE provinces SET attack_count = 0, support_count = 0, carried_attacks = 0, carried_supports = 0, shielded = FALSE WHERE lobby_id = $1`, spr.lobbyID)
+	return spr.fail(ctx, "ResetAllProvinceCounts", err)
+}
+
+// CarryProvinceCounts carries decay of every province's counts, the carried ones
+// included, into its carried counts, then resets attackCount and supportCount to 0 and
+// drops the shields. The SET expressions read the values from before the update
+func (spr *SQLProvinceRepo) CarryProvinceCounts(ctx context.Context, decay float64) error {
+	_, err := spr.db.ExecContext(ctx, `UPDATE provinces SET
+		carried_attacks = $1 * (attack_count + carried_attacks),
+		carried_supports = $1 * (support_count + carried_supports),
+		attack_count = 0, support_count = 0, shielded = FALSE
+		WHERE lobby_id = $2`, decay, spr.lobbyID)
+	return spr.fail(ctx, "CarryProvinceCounts", err)
+}
+
+// InsertProvinces adds provinces, for seeding a new database or game. Provinces without an
+// ID get one
+func (spr *SQLProvinceRepo) InsertProvinces(ctx context.Context, provinces ...model.Province) error {
+	return spr.fail(ctx, "InsertProvinces", spr.inTx(ctx, func(tx *sql.Tx) error {
+		for _, p := range provinces {
+			if p.ID.IsZero() {
+				p.ID = primitive.NewObjectID()
+			}
+
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO provinces (id, province_name, province_color_hex, attack_count, support_count, destroyment_round, shielded, neighbors, previous_score, carried_attacks, carried_supports, lobby_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+				p.ID.Hex(), p.ProvinceName, p.ProvinceColorHex, p.AttackCount, p.SupportCount, p.DestroymentRound, p.Shielded, strings.Join(p.Neighbors, ","), p.PreviousScore, p.CarriedAttacks, p.CarriedSupports, spr.lobbyID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func (spr *SQLProvinceRepo) query(ctx context.Context, query string, args ...any) ([]model.Province, error) {
+	rows, err := spr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	provinces := []model.Province{}
+	for rows.Next() {
+		p, err := scanProvince(rows)
+		if err != nil {
+			return nil, err
+		}
+		provinces = append(provinces, p)
+	}
+
+	return provinces, rows.Err()
+}
+
+// inTx runs fn in a transaction, committed when fn succeeds
+func (spr *SQLProvinceRepo) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := spr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// fail logs a failed operation with the request attributes carried by ctx and returns
+// err, a nil err is returned as is
+func (spr *SQLProvinceRepo) fail(ctx context.Context, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	slog.ErrorContext(ctx, "Province store operation failed", "operation", operation, logging.Err(err))
+	return err
+}
+
+// scanProvince reads a row selected with selectProvince
+func scanProvince(row interface{ Scan(dest ...any) error }) (model.Province, error) {
+	var p model.Province
+	var id, neighbors string
+	if err := row.Scan(&id, &p.ProvinceName, &p.ProvinceColorHex, &p.AttackCount, &p.SupportCount, &p.DestroymentRound, &p.Shielded, &neighbors, &p.PreviousScore, &p.CarriedAttacks, &p.CarriedSupports); err != nil {
+		return model.Province{}, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Province{}, err
+	}
+	p.ID = objectID
+	if neighbors != "" {
+		p.Neighbors = strings.Split(neighbors, ",")
+	}
+
+	return p, nil
+}
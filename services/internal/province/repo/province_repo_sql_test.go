@@ -0,0 +1,181 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/internal/core/sqldb/sqldbtest"
+	"services/internal/province/model"
+)
+
+func newSQLProvinceRepo(t *testing.T, db *sql.DB, provinces ...model.Province) *SQLProvinceRepo {
+	t.Helper()
+
+	spr := NewSQLProvinceRepo(db)
+	require.NoError(t, spr.InsertProvinces(context.Background(), provinces...))
+	return spr
+}
+
+func TestSQLProvinceRepo_Moves(t *testing.T) {
+	sqldbtest.Run(t, testSQLProvinceRepo_Moves)
+}
+
+func testSQLProvinceRepo_Moves(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	neighbors := []string{primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()}
+	spr := newSQLProvinceRepo(t, db, model.Province{ID: id, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", DestroymentRound: -1, Neighbors: neighbors})
+
+	require.NoError(t, spr.UpdateProvinceByID(ctx, id.Hex(), true, 2))
+	require.NoError(t, spr.UpdateProvinceByID(ctx, id.Hex(), false, 1))
+	require.NoError(t, spr.ApplyCountDeltas(ctx, []model.CountDelta{
+		{ProvinceID: id.Hex(), Attacks: 3, Supports: 1},
+		{ProvinceID: primitive.NewObjectID().Hex(), Attacks: 1},
+	}))
+
+	province, err := spr.GetByID(ctx, id.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, model.Province{ID: id, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", AttackCount: 5, SupportCount: 2, DestroymentRound: -1, Neighbors: neighbors}, *province)
+
+	assert.ErrorIs(t, spr.UpdateProvinceByID(ctx, primitive.NewObjectID().Hex(), true, 1), ErrNotFound)
+	assert.Error(t, spr.UpdateProvinceByID(ctx, "ankara", true, 1))
+
+	_, err = spr.GetByID(ctx, primitive.NewObjectID().Hex())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLProvinceRepo_Round(t *testing.T) {
+	sqldbtest.Run(t, testSQLProvinceRepo_Round)
+}
+
+func testSQLProvinceRepo_Round(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	spr := newSQLProvinceRepo(t, db,
+		model.Province{ProvinceName: "Ankara", AttackCount: 2, SupportCount: 1, DestroymentRound: -1},
+		model.Province{ProvinceName: "Izmir", AttackCount: 9, SupportCount: 2, DestroymentRound: -1},
+		model.Province{ProvinceName: "Bursa", AttackCount: 1, SupportCount: 5, DestroymentRound: -1},
+	)
+
+	provinces, err := spr.GetAll(ctx)
+	require.NoError(t, err)
+	require.NoError(t, spr.NukeProvinces(ctx, 7, []string{provinces[1].ID.Hex()}))
+	require.NoError(t, spr.ResetAllProvinceCounts(ctx))
+
+	provinces, err = spr.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, provinces, 3)
+	assert.Equal(t, "Ankara", provinces[0].ProvinceName)
+	for _, p := range provinces {
+		assert.Zero(t, p.AttackCount+p.SupportCount)
+		if p.ProvinceName == "Izmir" {
+			assert.Equal(t, 7, p.DestroymentRound)
+		} else {
+			assert.Equal(t, -1, p.DestroymentRound)
+		}
+	}
+}
+
+func TestSQLProvinceRepo_Shields(t *testing.T) {
+	sqldbtest.Run(t, testSQLProvinceRepo_Shields)
+}
+
+func testSQLProvinceRepo_Shields(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	izmir, bursa := primitive.NewObjectID(), primitive.NewObjectID()
+	ankara := primitive.NewObjectID()
+	spr := newSQLProvinceRepo(t, db,
+		model.Province{ID: ankara, ProvinceName: "Ankara", AttackCount: 2, SupportCount: 1, DestroymentRound: -1},
+		model.Province{ID: izmir, ProvinceName: "Izmir", AttackCount: 90, SupportCount: 20, DestroymentRound: -1},
+		model.Province{ID: bursa, ProvinceName: "Bursa", SupportCount: 30, DestroymentRound: 2},
+	)
+
+	shielded, err := spr.ShieldProvince(ctx, izmir.Hex(), 21)
+	require.NoError(t, err)
+	assert.False(t, shielded)
+
+	shielded, err = spr.ShieldProvince(ctx, izmir.Hex(), 20)
+	require.NoError(t, err)
+	assert.True(t, shielded)
+
+	// Already shielded, and nuked provinces can't be
+	shielded, err = spr.ShieldProvince(ctx, izmir.Hex(), 20)
+	require.NoError(t, err)
+	assert.False(t, shielded)
+	shielded, err = spr.ShieldProvince(ctx, bursa.Hex(), 20)
+	require.NoError(t, err)
+	assert.False(t, shielded)
+
+	// The shield holds for the nuke of this round, then drops
+	require.NoError(t, spr.NukeProvinces(ctx, 3, []string{ankara.Hex()}))
+	require.NoError(t, spr.ResetAllProvinceCounts(ctx))
+
+	provinces, err := spr.GetAll(ctx)
+	require.NoError(t, err)
+	rounds := map[string]int{}
+	for _, p := range provinces {
+		assert.False(t, p.Shielded)
+		rounds[p.ProvinceName] = p.DestroymentRound
+	}
+	assert.Equal(t, map[string]int{"Ankara": 3, "Izmir": -1, "Bursa": 2}, rounds)
+}
+
+func TestSQLProvinceRepo_NukeByIDAndPreviousScores(t *testing.T) {
+	sqldbtest.Run(t, testSQLProvinceRepo_NukeByIDAndPreviousScores)
+}
+
+func testSQLProvinceRepo_NukeByIDAndPreviousScores(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	spr := newSQLProvinceRepo(t, db,
+		model.Province{ProvinceName: "Ankara", DestroymentRound: -1},
+		model.Province{ProvinceName: "Izmir", DestroymentRound: 2},
+		model.Province{ProvinceName: "Van", DestroymentRound: -1},
+	)
+	provinces, err := spr.GetAll(ctx)
+	require.NoError(t, err)
+	ankara, izmir, van := provinces[0].ID.Hex(), provinces[1].ID.Hex(), provinces[2].ID.Hex()
+
+	// A nuked province keeps the round it fell in
+	require.NoError(t, spr.NukeProvinces(ctx, 5, []string{ankara, izmir}))
+	require.NoError(t, spr.SetPreviousScores(ctx, map[string]float64{van: 2.5}))
+
+	provinces, err = spr.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, provinces[0].DestroymentRound)
+	assert.Equal(t, 2, provinces[1].DestroymentRound)
+	assert.Equal(t, -1, provinces[2].DestroymentRound)
+	assert.Equal(t, 2.5, provinces[2].PreviousScore)
+	assert.Zero(t, provinces[0].PreviousScore)
+
+	assert.Error(t, spr.NukeProvinces(ctx, 6, []string{"invalidid"}))
+}
+
+func TestSQLProvinceRepo_CarryCounts(t *testing.T) {
+	sqldbtest.Run(t, testSQLProvinceRepo_CarryCounts)
+}
+
+func testSQLProvinceRepo_CarryCounts(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	spr := newSQLProvinceRepo(t, db,
+		model.Province{ProvinceName: "Ankara", AttackCount: 4, SupportCount: 8, CarriedAttacks: 2, DestroymentRound: -1, Shielded: true},
+	)
+
+	require.NoError(t, spr.CarryProvinceCounts(ctx, 0.25))
+	provinces, err := spr.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, provinces[0].AttackCount)
+	assert.Zero(t, provinces[0].SupportCount)
+	assert.False(t, provinces[0].Shielded)
+	assert.Equal(t, 1.5, provinces[0].CarriedAttacks)
+	assert.Equal(t, 2.0, provinces[0].CarriedSupports)
+
+	require.NoError(t, spr.ResetAllProvinceCounts(ctx))
+	provinces, err = spr.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, provinces[0].CarriedAttacks)
+	assert.Zero(t, provinces[0].CarriedSupports)
+}
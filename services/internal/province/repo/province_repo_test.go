@@ -106,7 +106,7 @@ func TestUpdateProvinceByID(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test 1: Increment attack count
-	err = provinceRepo.UpdateProvinceByID(ctx, provinceID.Hex(), true)
+	err = provinceRepo.UpdateProvinceByID(ctx, provinceID.Hex(), true, 1)
 	assert.NoError(t, err)
 
 	// Verify the update
@@ -117,7 +117,7 @@ func TestUpdateProvinceByID(t *testing.T) {
 	assert.Equal(t, 0, updatedProvince.SupportCount)
 
 	// Test 2: Increment support count
-	err = provinceRepo.UpdateProvinceByID(ctx, provinceID.Hex(), false)
+	err = provinceRepo.UpdateProvinceByID(ctx, provinceID.Hex(), false, 1)
 	assert.NoError(t, err)
 
 	// Verify the update
@@ -127,7 +127,7 @@ func TestUpdateProvinceByID(t *testing.T) {
 	assert.Equal(t, 1, updatedProvince.SupportCount)
 
 	// Test 3: Invalid ID
-	err = provinceRepo.UpdateProvinceByID(ctx, "invalid-id", true)
+	err = provinceRepo.UpdateProvinceByID(ctx, "invalid-id", true, 1)
 	assert.Error(t, err)
 }
 */
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	auth_model "services/internal/auth/model"
+	"services/internal/core/logging"
+	"services/internal/core/response"
+	"services/internal/core/tracing"
+	"services/internal/core/validation"
+	"services/internal/province/model"
+	"services/internal/province/repo"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errAlliancesOff answers the alliance endpoints of a service without an alliance store
+var errAlliancesOff = errors.New("alliances are off")
+
+// errNotFounder rejects a change to an alliance by anyone but its founder
+var errNotFounder = errors.New("only the founder of an alliance may change it")
+
+// errNotInvited rejects accepting an alliance the player's home province isn't invited to
+var errNotInvited = errors.New("the home province isn't invited to the alliance")
+
+// AllianceConflictError rejects a member that already belongs to another alliance
+type AllianceConflictError struct {
+	Province string // name of the province
+	Alliance string // name of the alliance it belongs to
+}
+
+func (e *AllianceConflictError) Error() string {
+	return e.Province + " already belongs to the alliance " + e.Alliance
+}
+
+// AllyAttackError rejects an attack on a province allied to the player's home province
+type AllyAttackError struct {
+	Alliance string
+}
+
+func (e *AllyAttackError) Error() string {
+	return "this province is your ally in " + e.Alliance + ", allies can't be attacked"
+}
+
+// SetAlliances keeps alliances in alliances, the alliance endpoints answer 404 without one
+func (ps *ProvinceService) SetAlliances(alliances AllianceRepository) {
+	ps.alliances = alliances
+}
+
+// GetAlliances returns every alliance, oldest first
+func (ps *ProvinceService) GetAlliances(w http.ResponseWriter, r *http.Request) {
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.GetAlliances")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if ps.alliances == nil {
+		writeAllianceError(w, r, span, errAlliancesOff)
+		return
+	}
+
+	alliances, err := ps.alliances.GetAll(ctx)
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, model.GetAlliancesResponse{
+		Alliances: alliances,
+	})
+}
+
+// GetAlliance returns a single alliance by ID
+func (ps *ProvinceService) GetAlliance(w http.ResponseWriter, r *http.Request) {
+	req := model.GetAllianceRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.GetAlliance")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if ps.alliances == nil {
+		writeAllianceError(w, r, span, errAlliancesOff)
+		return
+	}
+
+	alliance, err := ps.alliances.GetByID(ctx, req.ID)
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, model.AllianceResponse{
+		Alliance: *alliance,
+	})
+}
+
+// CreateAlliance forms an alliance founded by the logged in player, whose home province
+// must be among the provinces. It is the only member at first, the other provinces are
+// invited and join once a player whose home it is accepts
+func (ps *ProvinceService) CreateAlliance(w http.ResponseWriter, r *http.Request) {
+	var req model.AllianceRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.CreateAlliance")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	user, err := ps.founder(ctx, r)
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	ps.allianceMu.Lock()
+	defer ps.allianceMu.Unlock()
+
+	if err := ps.checkMembers(ctx, user, req.ProvinceIDs, ""); err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	alliance := model.Alliance{
+		Name:      req.Name,
+		Pact:      req.Pact,
+		FounderID: user.ID.Hex(),
+		CreatedAt: time.Now().UTC(),
+	}
+	invite(&alliance, req.ProvinceIDs, user.HomeProvinceID)
+	if err := ps.alliances.InsertAlliance(ctx, &alliance); err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+	slog.InfoContext(ctx, "Alliance formed", "alliance", alliance.Name, "invited", len(alliance.InvitedIDs))
+
+	response.JSON(w, http.StatusCreated, model.AllianceResponse{
+		Alliance: alliance,
+	})
+}
+
+// UpdateAlliance replaces the name, pact and provinces of an alliance, for its founder
+// only. Members listed again stay members, new provinces are invited like on creation
+func (ps *ProvinceService) UpdateAlliance(w http.ResponseWriter, r *http.Request) {
+	id := model.GetAllianceRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(id); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+	var req model.AllianceRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.UpdateAlliance")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	user, err := ps.founder(ctx, r)
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	ps.allianceMu.Lock()
+	defer ps.allianceMu.Unlock()
+
+	alliance, err := ps.foundedBy(ctx, user, id.ID)
+	if err == nil {
+		err = ps.checkMembers(ctx, user, req.ProvinceIDs, id.ID)
+	}
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	alliance.Name = req.Name
+	alliance.Pact = req.Pact
+	invite(alliance, req.ProvinceIDs, user.HomeProvinceID)
+	if err := ps.alliances.UpdateAlliance(ctx, *alliance); err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, model.AllianceResponse{
+		Alliance: *alliance,
+	})
+}
+
+// DeleteAlliance dissolves an alliance, for its founder only
+func (ps *ProvinceService) DeleteAlliance(w http.ResponseWriter, r *http.Request) {
+	id := model.GetAllianceRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(id); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.DeleteAlliance")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	user, err := ps.founder(ctx, r)
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	ps.allianceMu.Lock()
+	defer ps.allianceMu.Unlock()
+
+	_, err = ps.foundedBy(ctx, user, id.ID)
+	if err == nil {
+		err = ps.alliances.DeleteAlliance(ctx, id.ID)
+	}
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptAlliance makes the home province of the logged in player a member of an alliance
+// it is invited to, while it is alive and in no other alliance
+func (ps *ProvinceService) AcceptAlliance(w http.ResponseWriter, r *http.Request) {
+	id := model.GetAllianceRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(id); err != nil {
+		validation.WriteError(w, r, err)
+		return
+	}
+
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.AcceptAlliance")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	user, err := ps.founder(ctx, r)
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	ps.allianceMu.Lock()
+	defer ps.allianceMu.Unlock()
+
+	home := user.HomeProvinceID
+	alliance, err := ps.alliances.GetByID(ctx, id.ID)
+	if err == nil && (home == "" || !slices.Contains(alliance.InvitedIDs, home)) {
+		err = errNotInvited
+	}
+	if err == nil {
+		err = ps.checkMember(ctx, home, id.ID)
+	}
+	if err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+
+	alliance.InvitedIDs = slices.DeleteFunc(alliance.InvitedIDs, func(invited string) bool { return invited == home })
+	alliance.ProvinceIDs = append(alliance.ProvinceIDs, home)
+	if err := ps.alliances.UpdateAlliance(ctx, *alliance); err != nil {
+		writeAllianceError(w, r, span, err)
+		return
+	}
+	slog.InfoContext(ctx, "Alliance joined", "alliance", alliance.Name, "members", len(alliance.ProvinceIDs))
+
+	response.JSON(w, http.StatusOK, model.AllianceResponse{
+		Alliance: *alliance,
+	})
+}
+
+// founder reads the logged in player changing alliances with r
+func (ps *ProvinceService) founder(ctx context.Context, r *http.Request) (*auth_model.User, error) {
+	if ps.alliances == nil {
+		return nil, errAlliancesOff
+	}
+
+	userID, err := ps.moverID(r)
+	if err != nil {
+		return nil, err
+	}
+	user, err := ps.mover(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errNotLoggedIn
+	}
+
+	return user, nil
+}
+
+// foundedBy reads an alliance user may change
+func (ps *ProvinceService) foundedBy(ctx context.Context, user *auth_model.User, id string) (*model.Alliance, error) {
+	alliance, err := ps.alliances.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alliance.FounderID != user.ID.Hex() {
+		return nil, errNotFounder
+	}
+
+	return alliance, nil
+}
+
+// checkMembers tells whether provinces may form an alliance founded by user: as many as
+// the rules allow, the founder's home among them, all alive and in no other alliance
+// than the one with allianceID
+func (ps *ProvinceService) checkMembers(ctx context.Context, user *auth_model.User, provinceIDs []string, allianceID string) error {
+	maxMembers := ps.rules.Alliances.MaxMembers
+	switch {
+	case len(provinceIDs) < 2:
+		return allianceField("min", "must list at least 2 provinces")
+	case maxMembers > 0 && len(provinceIDs) > maxMembers:
+		return allianceField("max", fmt.Sprintf("must list at most %d provinces", maxMembers))
+	case user.HomeProvinceID == "" || !slices.Contains(provinceIDs, user.HomeProvinceID):
+		return allianceField("home", "must include your home province")
+	}
+
+	for _, id := range provinceIDs {
+		if err := ps.checkMember(ctx, id, allianceID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkMember tells whether a province may be in the alliance with allianceID, alive and
+// in no other alliance
+func (ps *ProvinceService) checkMember(ctx context.Context, provinceID, allianceID string) error {
+	province, err := ps.repo.GetByID(ctx, provinceID)
+	if errors.Is(err, repo.ErrNotFound) {
+		return allianceField("exists", "province "+provinceID+" doesn't exist")
+	}
+	if err != nil {
+		return err
+	}
+	if province.DestroymentRound != -1 {
+		return allianceField("alive", province.ProvinceName+" was nuked")
+	}
+
+	alliance, err := ps.alliances.GetByProvinceID(ctx, provinceID)
+	switch {
+	case errors.Is(err, repo.ErrAllianceNotFound):
+	case err != nil:
+		return err
+	case alliance.ID.Hex() != allianceID:
+		return &AllianceConflictError{Province: province.ProvinceName, Alliance: alliance.Name}
+	}
+
+	return nil
+}
+
+// allianceField rejects the provinces of an alliance by rule
+func allianceField(rule, message string) error {
+	return validation.FieldErrors{{Field: "province_ids", Rule: rule, Message: message}}
+}
+
+// invite lists provinceIDs in alliance. The home of its founder and its members listed
+// again are its members, the other provinces are invited
+func invite(alliance *model.Alliance, provinceIDs []string, home string) {
+	members, invited := []string{}, []string{}
+	for _, id := range provinceIDs {
+		if id == home || slices.Contains(alliance.ProvinceIDs, id) {
+			members = append(members, id)
+		} else {
+			invited = append(invited, id)
+		}
+	}
+
+	alliance.ProvinceIDs, alliance.InvitedIDs = members, invited
+}
+
+// allyOf returns the alliance a province shares with the home province of user, nil if
+// they aren't allies. A home province isn't its own ally
+func (ps *ProvinceService) allyOf(ctx context.Context, user *auth_model.User, provinceID string) (*model.Alliance, error) {
+	home := user.HomeProvinceID
+	if ps.alliances == nil || home == "" || home == provinceID {
+		return nil, nil
+	}
+
+	alliance, err := ps.alliances.GetByProvinceID(ctx, home)
+	if errors.Is(err, repo.ErrAllianceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(alliance.ProvinceIDs, provinceID) {
+		return nil, nil
+	}
+
+	return alliance, nil
+}
+
+// dissolveAlliances dissolves the alliances of nuked provinces. The nuke stands when it
+// fails, the alliance outlives its member until an organizer removes it
+func (ps *ProvinceService) dissolveAlliances(ctx context.Context, nuked []string) {
+	if ps.alliances == nil || len(nuked) == 0 {
+		return
+	}
+
+	ps.allianceMu.Lock()
+	defer ps.allianceMu.Unlock()
+
+	dissolved, err := ps.alliances.DissolveAlliancesOf(ctx, nuked)
+	if err != nil {
+		slog.WarnContext(ctx, "Dissolving the alliances of nuked provinces failed", logging.Err(err))
+		return
+	}
+	for _, alliance := range dissolved {
+		slog.InfoContext(ctx, "Alliance dissolved, a member was nuked", "alliance", alliance.Name)
+	}
+}
+
+// writeAllianceError answers an alliance request that was rejected or failed
+func writeAllianceError(w http.ResponseWriter, r *http.Request, span trace.Span, err error) {
+	var fieldErrors validation.FieldErrors
+	var conflict *AllianceConflictError
+
+	switch {
+	case errors.Is(err, errAlliancesOff):
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "Alliances are not enabled")
+	case errors.Is(err, repo.ErrAllianceNotFound):
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "Alliance not found")
+	case errors.Is(err, errNotLoggedIn):
+		response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
+	case errors.Is(err, errNotFounder):
+		response.Error(w, r, http.StatusForbidden, response.CodeForbidden, "Only the founder of an alliance may change it")
+	case errors.Is(err, errNotInvited):
+		response.Error(w, r, http.StatusForbidden, response.CodeForbidden, "Your home province isn't invited to this alliance")
+	case errors.As(err, &fieldErrors):
+		validation.WriteError(w, r, fieldErrors)
+	case errors.As(err, &conflict):
+		response.Error(w, r, http.StatusConflict, response.CodeAllianceConflict, err.Error())
+	default:
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to process the alliance")
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kahlery/pkg/go/auth/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	auth_model "services/internal/auth/model"
+	auth_repo "services/internal/auth/repo"
+	"services/internal/core/response"
+	"services/internal/game"
+	"services/internal/province/model"
+	"services/internal/province/repo"
+)
+
+// allianceRequest sends an alliance request authorized by bearer unless it is empty
+func allianceRequest(handler http.HandlerFunc, method, target, id, bearer, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestAlliances(t *testing.T) {
+	ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ID: ids[0], ProvinceName: "Zartistan", DestroymentRound: -1},
+		model.Province{ID: ids[1], ProvinceName: "Zortistan", DestroymentRound: -1},
+		model.Province{ID: ids[2], ProvinceName: "Nukeland", DestroymentRound: -1},
+		model.Province{ID: ids[3], ProvinceName: "Ashes", DestroymentRound: 1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	founder := auth_model.User{ID: primitive.NewObjectID(), Username: "zart", HomeProvinceID: ids[0].Hex()}
+	ally := auth_model.User{ID: primitive.NewObjectID(), Username: "zort", HomeProvinceID: ids[1].Hex()}
+	rival := auth_model.User{ID: primitive.NewObjectID(), Username: "nuke", HomeProvinceID: ids[2].Hex()}
+	service.SetPlayers(auth_repo.NewMemoryUserRepo(founder, ally, rival))
+	service.SetAlliances(repo.NewMemoryAllianceRepo())
+
+	bearer := func(user auth_model.User) string {
+		userToken, err := token.GenerateToken(user.ID.Hex())
+		require.NoError(t, err)
+		return userToken
+	}
+	founderToken, allyToken, rivalToken := bearer(founder), bearer(ally), bearer(rival)
+
+	members := func(name string, provinces ...primitive.ObjectID) string {
+		quoted := make([]string, len(provinces))
+		for i, id := range provinces {
+			quoted[i] = `"` + id.Hex() + `"`
+		}
+		return `{"name": "` + name + `", "pact": "Back to back", "province_ids": [` + strings.Join(quoted, ",") + `]}`
+	}
+	form := func(bearer, body string, status int) model.Alliance {
+		t.Helper()
+		rr := allianceRequest(service.CreateAlliance, http.MethodPost, "/api/alliances", "", bearer, body)
+		require.Equal(t, status, rr.Code, rr.Body.String())
+
+		var formed model.AllianceResponse
+		if status == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &formed))
+		}
+		return formed.Alliance
+	}
+	accept := func(allianceID, bearer string) *httptest.ResponseRecorder {
+		return allianceRequest(service.AcceptAlliance, http.MethodPost, "/api/alliances/"+allianceID+"/accept", allianceID, bearer, "")
+	}
+
+	form("", members("Twins", ids[0], ids[1]), http.StatusUnauthorized)
+	form(founderToken, members("Twins", ids[1], ids[2]), http.StatusBadRequest)
+	form(founderToken, members("Twins", ids[0], ids[3]), http.StatusBadRequest)
+	form(founderToken, members("Twins", ids[0]), http.StatusBadRequest)
+
+	// Only the founder's home joins right away, the other provinces are invited
+	twins := form(founderToken, members("Twins", ids[0], ids[1]), http.StatusCreated)
+	allianceID := twins.ID.Hex()
+	assert.Equal(t, founder.ID.Hex(), twins.FounderID)
+	assert.Equal(t, []string{ids[0].Hex()}, twins.ProvinceIDs)
+	assert.Equal(t, []string{ids[1].Hex()}, twins.InvitedIDs)
+	form(founderToken, members("Twins", ids[0], ids[2]), http.StatusConflict)
+	rivals := form(rivalToken, members("Rivals", ids[2], ids[1]), http.StatusCreated)
+
+	// Only an invited home province accepts, and joins a single alliance
+	rr := accept(allianceID, rivalToken)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, response.CodeForbidden, errorCode(t, rr))
+	rr = accept(allianceID, allyToken)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var joined model.AllianceResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &joined))
+	assert.Equal(t, []string{ids[0].Hex(), ids[1].Hex()}, joined.Alliance.ProvinceIDs)
+	assert.Empty(t, joined.Alliance.InvitedIDs)
+	rr = accept(rivals.ID.Hex(), allyToken)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, response.CodeAllianceConflict, errorCode(t, rr))
+
+	// Only the founder changes it, a province added is invited
+	rr = allianceRequest(service.UpdateAlliance, http.MethodPut, "/api/alliances/"+allianceID, allianceID, rivalToken, members("Twins", ids[2], ids[1]))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, response.CodeForbidden, errorCode(t, rr))
+	rr = allianceRequest(service.DeleteAlliance, http.MethodDelete, "/api/alliances/"+rivals.ID.Hex(), rivals.ID.Hex(), rivalToken, "")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = allianceRequest(service.UpdateAlliance, http.MethodPut, "/api/alliances/"+allianceID, allianceID, founderToken, members("Triplets", ids[0], ids[1], ids[2]))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, http.StatusOK, accept(allianceID, rivalToken).Code)
+
+	rr = allianceRequest(service.GetAlliances, http.MethodGet, "/api/alliances", "", "", "")
+	var list model.GetAlliancesResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list.Alliances, 1)
+	assert.Equal(t, "Triplets", list.Alliances[0].Name)
+	assert.Len(t, list.Alliances[0].ProvinceIDs, 3)
+
+	rr = allianceRequest(service.DeleteAlliance, http.MethodDelete, "/api/alliances/"+allianceID, allianceID, founderToken, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = allianceRequest(service.GetAlliance, http.MethodGet, "/api/alliances/"+allianceID, allianceID, "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAlliances_AlliesInMovesAndNukes(t *testing.T) {
+	ctx := context.Background()
+	home, ally, stranger := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ID: home, ProvinceName: "Zartistan", DestroymentRound: -1},
+		model.Province{ID: ally, ProvinceName: "Zortistan", DestroymentRound: -1},
+		model.Province{ID: stranger, ProvinceName: "Nukeland", DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	player := auth_model.User{ID: primitive.NewObjectID(), Username: "zart", HomeProvinceID: home.Hex()}
+	service.SetPlayers(auth_repo.NewMemoryUserRepo(player))
+	alliances := repo.NewMemoryAllianceRepo()
+	service.SetAlliances(alliances)
+	require.NoError(t, alliances.InsertAlliance(ctx, &model.Alliance{Name: "Twins", ProvinceIDs: []string{home.Hex(), ally.Hex()}}))
+
+	bearer, err := token.GenerateToken(player.ID.Hex())
+	require.NoError(t, err)
+
+	// Supporting an ally counts double, attacking one is forbidden
+	rr := moveAs(service, bearer, game.MoveSupport, ally.Hex(), "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var support model.SupportProvinceResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &support))
+	assert.Equal(t, 2, support.Move.Weight)
+	assert.Equal(t, []string{game.ModifierAlly}, support.Move.Modifiers)
+
+	rr = moveAs(service, bearer, game.MoveAttack, ally.Hex(), "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, response.CodeAllyAttack, errorCode(t, rr))
+
+	// Anonymous players have no allies, their attack counts
+	assert.Equal(t, http.StatusOK, moveAs(service, "", game.MoveAttack, ally.Hex(), "").Code)
+	for range 5 {
+		moveAs(service, "", game.MoveAttack, home.Hex(), "")
+	}
+
+	// Nuking a member dissolves the alliance
+	result, err := service.ExecuteDestroymentRound(ctx)
+	require.NoError(t, err)
+	require.Len(t, result.Victims, 1)
+	assert.Equal(t, "Zartistan", result.Victims[0].ProvinceName)
+
+	remaining, err := alliances.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+	assert.Equal(t, http.StatusOK, moveAs(service, bearer, game.MoveAttack, ally.Hex(), "").Code)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"services/internal/core/response"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Keys of the cached read endpoints, also their cache label in metrics
+const (
+	cacheKeyProvinces = "provinces"
+	cacheKeyTop       = "top"
+	cacheKeyProjected = "projection"
+)
+
+// cacheLoadTimeout bounds a load shared by concurrent misses, it outlives the request
+// that started it
+const cacheLoadTimeout = 5 * time.Second
+
+// cachedResponse is an encoded response body and its entity tag
+type cachedResponse struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// responseCache keeps the encoded responses of the read endpoints for up to ttl, moves
+// show once it is over. Nukes made through this process drop it at once, the ttl bounds
+// how long the other changes (moves, the nuke timer, other API instances) take to show. A
+// zero ttl caches nothing, responses still get an entity tag. Either way concurrent
+// misses of a key share a single load
+type responseCache struct {
+	ttl   time.Duration
+	loads singleflight.Group
+
+	mu         sync.Mutex
+	generation uint64 // bumped by every invalidation, so a load racing one isn't kept
+	entries    map[string]cachedResponse
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+// get returns the response cached under key, or encodes and caches what load returns.
+// hit tells which one happened. The load is shared with the misses joining it, it runs
+// on ctx without its cancelation so a caller giving up doesn't fail the others, only
+// that caller stops waiting
+func (c *responseCache) get(ctx context.Context, key string, load func(ctx context.Context) (any, error)) (cached cachedResponse, hit bool, err error) {
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached, true, nil
+	}
+
+	// A miss after an invalidation doesn't join a load started before it
+	loaded := c.loads.DoChan(key+"@"+strconv.FormatUint(generation, 10), func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheLoadTimeout)
+		defer cancel()
+
+		return c.load(ctx, key, generation, load)
+	})
+
+	select {
+	case <-ctx.Done():
+		return cachedResponse{}, false, ctx.Err()
+	case res := <-loaded:
+		if res.Err != nil {
+			return cachedResponse{}, false, res.Err
+		}
+		return res.Val.(cachedResponse), false, nil
+	}
+}
+
+// load encodes what load returns and caches it under key, unless the cache was
+// invalidated since generation
+func (c *responseCache) load(ctx context.Context, key string, generation uint64, load func(ctx context.Context) (any, error)) (cachedResponse, error) {
+	v, err := load(ctx)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	cached := cachedResponse{
+		body:    body,
+		etag:    response.ETag(body),
+		expires: time.Now().Add(c.ttl),
+	}
+
+	if c.ttl > 0 {
+		c.mu.Lock()
+		if c.generation == generation {
+			c.entries[key] = cached
+		}
+		c.mu.Unlock()
+	}
+
+	return cached, nil
+}
+
+// invalidate drops every cached response
+func (c *responseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
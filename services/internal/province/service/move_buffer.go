@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"services/internal/core/logging"
+	"services/internal/game"
+	"services/internal/province/model"
+	"services/internal/province/repo"
+)
+
+// knownReloadInterval bounds how often an unknown province ID reloads the known ones,
+// so made up IDs can't turn every move into a full read
+const knownReloadInterval = time.Second
+
+// flushTimeout bounds the last flush when the flusher stops
+const flushTimeout = 5 * time.Second
+
+// moveBuffer aggregates attacks and supports in memory, a flush writes all of them to
+// the repository in a single bulk write
+type moveBuffer struct {
+	repo     ProvinceRepository
+	log      MoveRepository // nil records no moves
+	interval time.Duration
+
+	// Called with the deltas of every successful flush
+	written func(ctx context.Context, deltas []model.CountDelta)
+
+	mu      sync.Mutex
+	pending map[string]*model.CountDelta
+	records []model.Move // recorded once their counts are written
+
+	// Held by a flush and for a whole nuke round, so counts can't land between the
+	// round reading them and resetting them
+	flushMu sync.Mutex
+
+	knownMu     sync.Mutex
+	known       map[string]bool // province IDs moves may target
+	knownLoaded time.Time
+}
+
+func newMoveBuffer(repo ProvinceRepository, log MoveRepository, interval time.Duration, written func(ctx context.Context, deltas []model.CountDelta)) *moveBuffer {
+	return &moveBuffer{
+		repo:     repo,
+		log:      log,
+		interval: interval,
+		written:  written,
+		pending:  make(map[string]*model.CountDelta),
+	}
+}
+
+// add buffers a move, ErrNotFound if the province doesn't exist
+func (b *moveBuffer) add(ctx context.Context, move model.Move) error {
+	if err := b.checkKnown(ctx, move.ProvinceID); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delta, ok := b.pending[move.ProvinceID]
+	if !ok {
+		delta = &model.CountDelta{ProvinceID: move.ProvinceID}
+		b.pending[move.ProvinceID] = delta
+	}
+	if move.Kind == game.MoveAttack {
+		delta.Attacks += move.Weight
+	} else {
+		delta.Supports += move.Weight
+	}
+	if b.log != nil {
+		b.records = append(b.records, move)
+	}
+
+	return nil
+}
+
+// checkKnown tells whether a province exists, provinces are read once and again when
+// an ID isn't among them
+func (b *moveBuffer) checkKnown(ctx context.Context, provinceID string) error {
+	b.knownMu.Lock()
+	defer b.knownMu.Unlock()
+
+	if b.known[provinceID] {
+		return nil
+	}
+	if b.known != nil && time.Since(b.knownLoaded) < knownReloadInterval {
+		return repo.ErrNotFound
+	}
+
+	provinces, err := b.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	b.known = make(map[string]bool, len(provinces))
+	for _, p := range provinces {
+		b.known[p.ID.Hex()] = true
+	}
+	b.knownLoaded = time.Now()
+
+	if !b.known[provinceID] {
+		return repo.ErrNotFound
+	}
+	return nil
+}
+
+// flush writes the buffered moves, they are kept for the next flush if writing fails
+func (b *moveBuffer) flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	return b.flushLocked(ctx)
+}
+
+func (b *moveBuffer) flushLocked(ctx context.Context) error {
+	b.mu.Lock()
+	pending, records := b.pending, b.records
+	b.pending, b.records = make(map[string]*model.CountDelta), nil
+	b.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	deltas := make([]model.CountDelta, 0, len(pending))
+	for _, delta := range pending {
+		deltas = append(deltas, *delta)
+	}
+
+	if err := b.repo.ApplyCountDeltas(ctx, deltas); err != nil {
+		b.mu.Lock()
+		for _, delta := range deltas {
+			merged, ok := b.pending[delta.ProvinceID]
+			if !ok {
+				merged = &model.CountDelta{ProvinceID: delta.ProvinceID}
+				b.pending[delta.ProvinceID] = merged
+			}
+			merged.Attacks += delta.Attacks
+			merged.Supports += delta.Supports
+		}
+		b.records = append(records, b.records...)
+		b.mu.Unlock()
+		return err
+	}
+
+	// The counts are written, moves that can't be recorded are only missing from the log
+	if len(records) > 0 {
+		b.log.RecordMoves(ctx, records)
+	}
+	b.written(ctx, deltas)
+
+	return nil
+}
+
+// hold flushes the buffered moves and keeps later ones buffered until release is
+// called, release is nil when the flush failed
+func (b *moveBuffer) hold(ctx context.Context) (release func(), err error) {
+	b.flushMu.Lock()
+
+	if err := b.flushLocked(ctx); err != nil {
+		b.flushMu.Unlock()
+		return nil, err
+	}
+
+	return b.flushMu.Unlock, nil
+}
+
+// --------------------------------------------------------------------
+
+// SetMoveFlushInterval buffers attacks and supports in memory and writes them in bulk
+// every interval and before every nuke round of this service, instead of one write per
+// move. A nuke run by another process can't see them, so the rounds must be run here.
+// RunMoveFlusher must run for them to be written. A zero interval writes every move at
+// once, the default
+func (ps *ProvinceService) SetMoveFlushInterval(interval time.Duration) {
+	if interval <= 0 {
+		ps.moves = nil
+		return
+	}
+
+	ps.moves = newMoveBuffer(ps.repo, ps.moveLog, interval, ps.shieldSupported)
+}
+
+// RunMoveFlusher writes the buffered moves every flush interval until ctx is done, then
+// one last time. It returns at once when moves aren't buffered
+func (ps *ProvinceService) RunMoveFlusher(ctx context.Context) {
+	if ps.moves == nil {
+		return
+	}
+
+	ticker := time.NewTicker(ps.moves.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ps.FlushMoves(ctx)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+			defer cancel()
+
+			ps.FlushMoves(flushCtx)
+			return
+		}
+	}
+}
+
+// FlushMoves writes the buffered moves now, failures are logged and the moves kept for
+// the next flush
+func (ps *ProvinceService) FlushMoves(ctx context.Context) error {
+	if ps.moves == nil {
+		return nil
+	}
+
+	ctx, span := ps.tracer.Start(ctx, "ProvinceService.FlushMoves")
+	defer span.End()
+
+	if err := ps.moves.flush(ctx); err != nil {
+		slog.ErrorContext(ctx, "Flushing buffered moves failed, keeping them for the next flush", logging.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// holdMoves flushes the buffered moves and keeps the next ones out of the repository
+// until release is called, for a nuke round reading and resetting the counts
+func (ps *ProvinceService) holdMoves(ctx context.Context) (release func(), err error) {
+	if ps.moves == nil {
+		return func() {}, nil
+	}
+
+	return ps.moves.hold(ctx)
+}
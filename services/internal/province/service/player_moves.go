@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	auth_model "services/internal/auth/model"
+	auth_repo "services/internal/auth/repo"
+	"services/internal/core/logging"
+	"services/internal/core/response"
+	"services/internal/core/tracing"
+	"services/internal/core/validation"
+	"services/internal/game"
+	"services/internal/province/model"
+	"services/internal/province/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errNotLoggedIn is returned for a move sent with a token that isn't valid, such a move
+// is rejected instead of counted as anonymous
+var errNotLoggedIn = errors.New("invalid or expired token")
+
+// errAttackNeedsPlayer rejects anonymous attacks while only adjacent provinces can be
+// attacked, there is no home province to be adjacent to
+var errAttackNeedsPlayer = errors.New("attacks need a logged in player")
+
+// NotAdjacentError rejects an attack on a province out of the player's reach
+type NotAdjacentError struct {
+	Province string // name of the attacked province
+}
+
+func (e *NotAdjacentError) Error() string {
+	return e.Province + " is not adjacent to your home province or to a province you supported this round"
+}
+
+// SetRules weighs moves by rules instead of game.DefaultRules
+func (ps *ProvinceService) SetRules(rules game.Rules) {
+	ps.rules = rules
+}
+
+// SetPlayers weighs the moves of logged in players by their streak, home province and
+// items, read from and saved to players. Every move gets the base weight by default
+func (ps *ProvinceService) SetPlayers(players PlayerRepository) {
+	ps.players = players
+}
+
+// SetMoveLog records every counted move in moves, nothing is recorded by default
+func (ps *ProvinceService) SetMoveLog(moves MoveRepository) {
+	ps.moveLog = moves
+	if ps.moves != nil {
+		ps.moves.log = moves
+	}
+}
+
+// makeMove weighs a move on a province for whoever sent r, counts and records it
+func (ps *ProvinceService) makeMove(ctx context.Context, r *http.Request, kind, provinceID, item string) (model.Move, error) {
+	round, err := ps.GetCurrentRound(ctx)
+	if err != nil {
+		return model.Move{}, err
+	}
+
+	userID, err := ps.moverID(r)
+	if err != nil {
+		return model.Move{}, err
+	}
+
+	// A player's state is read, checked and saved in one go, or two moves could use the
+	// last item or save over each other's streak and supported provinces
+	if !userID.IsZero() {
+		unlock := ps.movers.lock(userID)
+		defer unlock()
+	}
+
+	user, err := ps.mover(ctx, userID)
+	if err != nil {
+		return model.Move{}, err
+	}
+
+	player := game.Player{}
+	if user != nil {
+		if player, err = ps.playerAt(ctx, user, round); err != nil {
+			return model.Move{}, err
+		}
+	}
+
+	if user != nil {
+		alliance, err := ps.allyOf(ctx, user, provinceID)
+		if err != nil {
+			return model.Move{}, err
+		}
+		if alliance != nil && kind == game.MoveAttack {
+			return model.Move{}, &AllyAttackError{Alliance: alliance.Name}
+		}
+		player.Ally = alliance != nil
+	}
+
+	if kind == game.MoveAttack && ps.rules.Attacks.AdjacentOnly {
+		if err := ps.checkReach(ctx, user, round, provinceID); err != nil {
+			return model.Move{}, err
+		}
+	}
+
+	weight, err := ps.rules.Moves.Weigh(kind, item, player)
+	if err != nil {
+		return model.Move{}, err
+	}
+
+	move := model.Move{
+		ID:         primitive.NewObjectID(),
+		ProvinceID: provinceID,
+		Kind:       kind,
+		Item:       item,
+		Weight:     weight.Value,
+		Modifiers:  weight.Modifiers,
+		Round:      round,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if user != nil {
+		move.UserID = user.ID.Hex()
+	}
+
+	if err := ps.countMove(ctx, move); err != nil {
+		return model.Move{}, err
+	}
+
+	// The move is counted already, a player state that couldn't be saved only costs
+	// the streak or gives an item back
+	if user != nil {
+		if err := ps.players.PutPlayerState(ctx, user.ID, afterMove(user.Player, move)); err != nil {
+			slog.WarnContext(ctx, "Saving the player state after a move failed", logging.Err(err))
+		}
+	}
+
+	return move, nil
+}
+
+// moverID reads the ID of the logged in player sending r, the zero ID for anonymous moves
+// or when players aren't tracked
+func (ps *ProvinceService) moverID(r *http.Request) (primitive.ObjectID, error) {
+	if ps.players == nil || r.Header.Get("Authorization") == "" {
+		return primitive.NilObjectID, nil
+	}
+
+	userID, err := ExtractUserIDFromRequest(r)
+	if err != nil {
+		return primitive.NilObjectID, errNotLoggedIn
+	}
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, errNotLoggedIn
+	}
+
+	return objectID, nil
+}
+
+// mover reads the player with the ID moverID read, nil for the zero ID
+func (ps *ProvinceService) mover(ctx context.Context, userID primitive.ObjectID) (*auth_model.User, error) {
+	if userID.IsZero() {
+		return nil, nil
+	}
+
+	user, err := ps.players.GetUserByID(ctx, userID)
+	if errors.Is(err, auth_repo.ErrNotFound) {
+		return nil, errNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// playerLocks serializes the moves of each player, players share one of a fixed set of
+// locks so there is nothing to clean up
+type playerLocks [64]sync.Mutex
+
+// lock locks the moves of the player with id and returns the unlock
+func (l *playerLocks) lock(id primitive.ObjectID) func() {
+	h := fnv.New32a()
+	h.Write(id[:])
+	mu := &l[h.Sum32()%uint32(len(l))]
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// playerAt tells how a player stands in round, before their move is counted
+func (ps *ProvinceService) playerAt(ctx context.Context, user *auth_model.User, round int) (game.Player, error) {
+	player := game.Player{
+		Known:  true,
+		Streak: nextStreak(user.Player, round),
+	}
+	if user.Player.ItemRound == round {
+		player.ItemsUsed = user.Player.ItemUses
+	}
+
+	if user.HomeProvinceID != "" {
+		home, err := ps.repo.GetByID(ctx, user.HomeProvinceID)
+		switch {
+		case errors.Is(err, repo.ErrNotFound):
+		case err != nil:
+			return game.Player{}, err
+		default:
+			player.Fallen = home.DestroymentRound != -1
+		}
+	}
+
+	return player, nil
+}
+
+// checkReach tells whether a player may attack a province, it must neighbor their home
+// province or a province they supported in round
+func (ps *ProvinceService) checkReach(ctx context.Context, user *auth_model.User, round int, provinceID string) error {
+	if user == nil {
+		return errAttackNeedsPlayer
+	}
+
+	target, err := ps.repo.GetByID(ctx, provinceID)
+	if err != nil {
+		return err
+	}
+
+	var reach []string
+	if user.HomeProvinceID != "" {
+		reach = append(reach, user.HomeProvinceID)
+	}
+	if user.Player.LastMoveRound == round {
+		reach = append(reach, user.Player.Supported...)
+	}
+
+	for _, id := range reach {
+		if slices.Contains(target.Neighbors, id) {
+			return nil
+		}
+	}
+
+	return &NotAdjacentError{Province: target.ProvinceName}
+}
+
+// nextStreak is a player's streak once they move in round
+func nextStreak(state auth_model.PlayerState, round int) int {
+	switch state.LastMoveRound {
+	case round:
+		return max(state.Streak, 1)
+	case round - 1:
+		return state.Streak + 1
+	default:
+		return 1
+	}
+}
+
+// afterMove is a player's state once their move was counted
+func afterMove(state auth_model.PlayerState, move model.Move) auth_model.PlayerState {
+	next := auth_model.PlayerState{
+		Streak:        nextStreak(state, move.Round),
+		LastMoveRound: move.Round,
+		ItemUses:      map[string]int{},
+		ItemRound:     move.Round,
+	}
+	if state.ItemRound == move.Round {
+		maps.Copy(next.ItemUses, state.ItemUses)
+	}
+	if move.Item != "" {
+		next.ItemUses[move.Item]++
+	}
+
+	if state.LastMoveRound == move.Round {
+		next.Supported = slices.Clone(state.Supported)
+	}
+	if move.Kind == game.MoveSupport && !slices.Contains(next.Supported, move.ProvinceID) {
+		next.Supported = append(next.Supported, move.ProvinceID)
+	}
+
+	return next
+}
+
+// recordMoves saves counted moves to the move log, a failure is logged by the log and
+// doesn't undo the moves
+func (ps *ProvinceService) recordMoves(ctx context.Context, moves ...model.Move) {
+	if ps.moveLog == nil {
+		return
+	}
+
+	ps.moveLog.RecordMoves(ctx, moves)
+}
+
+// writeMoveError answers a move that makeMove rejected or failed to count
+func writeMoveError(w http.ResponseWriter, r *http.Request, span trace.Span, err error) {
+	var notAdjacent *NotAdjacentError
+	var allyAttack *AllyAttackError
+
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "Province not found")
+	case errors.Is(err, errNotLoggedIn), errors.Is(err, game.ErrItemNeedsAccount):
+		response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
+	case errors.Is(err, errAttackNeedsPlayer):
+		response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Log in to attack, only provinces next to your home province or to one you supported this round can be attacked")
+	case errors.As(err, &notAdjacent):
+		response.Error(w, r, http.StatusForbidden, response.CodeNotAdjacent, err.Error())
+	case errors.As(err, &allyAttack):
+		response.Error(w, r, http.StatusForbidden, response.CodeAllyAttack, err.Error())
+	case errors.Is(err, game.ErrUnknownItem), errors.Is(err, game.ErrItemNotForMove):
+		validation.WriteError(w, r, validation.FieldErrors{{
+			Field:   "item",
+			Rule:    "item",
+			Message: err.Error(),
+		}})
+	case errors.Is(err, game.ErrItemUsedUp):
+		response.Error(w, r, http.StatusConflict, response.CodeItemUnavailable, "Item already used up this round")
+	default:
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to update province")
+	}
+}
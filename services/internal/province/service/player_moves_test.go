@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kahlery/pkg/go/auth/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	auth_model "services/internal/auth/model"
+	auth_repo "services/internal/auth/repo"
+	"services/internal/core/response"
+	"services/internal/game"
+	"services/internal/province/model"
+	"services/internal/province/repo"
+)
+
+// moveAs sends a move with an optional item, authorized by bearer unless it is empty
+func moveAs(service *ProvinceService, bearer, kind, provinceID, item string) *httptest.ResponseRecorder {
+	handler := service.AttackProvince
+	if kind == game.MoveSupport {
+		handler = service.SupportProvince
+	}
+
+	body := `{"province_id": "` + provinceID + `"`
+	if item != "" {
+		body += `, "item": "` + item + `"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/province/"+kind, strings.NewReader(body+"}"))
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// playerRules turn on the streak bonus, the fallen penalty and the default items, all
+// off by default
+func playerRules() game.Rules {
+	rules := game.DefaultRules()
+	rules.Moves.Streak.Bonus = 1
+	rules.Moves.FallenPenalty = 1
+	rules.Moves.Items = game.DefaultItems()
+	return rules
+}
+
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body response.ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	return body.Error.Code
+}
+
+func TestMoves_WeighedByPlayer(t *testing.T) {
+	ctx := context.Background()
+	target, home := primitive.NewObjectID(), primitive.NewObjectID()
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ID: target, ProvinceName: "Zartistan", DestroymentRound: -1},
+		model.Province{ID: home, ProvinceName: "Nukeland", DestroymentRound: 1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	service.SetRules(playerRules())
+	round, _ := service.GetCurrentRound(ctx)
+
+	// Five rounds in a row so far, the sixth one earns a streak bonus of 2
+	player := auth_model.User{ID: primitive.NewObjectID(), Username: "zart", HomeProvinceID: home.Hex(), Player: auth_model.PlayerState{Streak: 5, LastMoveRound: round - 1}}
+	players := auth_repo.NewMemoryUserRepo(player)
+	moveLog := repo.NewMemoryMoveRepo()
+	service.SetPlayers(players)
+	service.SetMoveLog(moveLog)
+
+	bearer, err := token.GenerateToken(player.ID.Hex())
+	require.NoError(t, err)
+
+	// 1 + 2 for the streak - 1 for the nuked home province, doubled
+	rr := moveAs(service, bearer, game.MoveAttack, target.Hex(), game.ItemDoubleStrike)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var body model.AttackProvinceResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, 4, body.Move.Weight)
+	assert.Equal(t, []string{game.ModifierStreak, game.ModifierFallen, game.ItemDoubleStrike}, body.Move.Modifiers)
+	assert.Equal(t, player.ID.Hex(), body.Move.UserID)
+
+	rr = moveAs(service, bearer, game.MoveAttack, target.Hex(), game.ItemDoubleStrike)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, response.CodeItemUnavailable, errorCode(t, rr))
+
+	rr = moveAs(service, bearer, game.MoveSupport, target.Hex(), game.ItemDoubleStrike)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, response.CodeValidationFailed, errorCode(t, rr))
+
+	rr = moveAs(service, bearer, game.MoveAttack, target.Hex(), "orbital_laser")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Anonymous moves count the base weight and can't use items
+	assert.Equal(t, http.StatusOK, moveAs(service, "", game.MoveSupport, target.Hex(), "").Code)
+	assert.Equal(t, http.StatusUnauthorized, moveAs(service, "", game.MoveSupport, target.Hex(), game.ItemShield).Code)
+	assert.Equal(t, http.StatusUnauthorized, moveAs(service, "not-a-token", game.MoveSupport, target.Hex(), "").Code)
+
+	province, err := provinceRepo.GetByID(ctx, target.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, 4, province.AttackCount)
+	assert.Equal(t, 1, province.SupportCount)
+
+	saved, err := players.GetUserByID(ctx, player.ID)
+	require.NoError(t, err)
+	assert.Equal(t, auth_model.PlayerState{Streak: 6, LastMoveRound: round, ItemUses: map[string]int{game.ItemDoubleStrike: 1}, ItemRound: round}, saved.Player)
+
+	moves, err := moveLog.GetMovesByRound(ctx, round)
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	assert.Equal(t, body.Move, moves[0])
+	assert.Empty(t, moves[1].UserID)
+	assert.Equal(t, 1, moves[1].Weight)
+}
+
+// slowPlayers answers reads of players late, so moves sent together read the same state
+// unless they take turns
+type slowPlayers struct {
+	PlayerRepository
+}
+
+func (sp slowPlayers) GetUserByID(ctx context.Context, id primitive.ObjectID) (*auth_model.User, error) {
+	user, err := sp.PlayerRepository.GetUserByID(ctx, id)
+	time.Sleep(5 * time.Millisecond)
+	return user, err
+}
+
+func TestMoves_ParallelMovesKeepThePlayerState(t *testing.T) {
+	ctx := context.Background()
+	target := model.Province{ID: primitive.NewObjectID(), ProvinceName: "Zartistan", DestroymentRound: -1}
+	provinces := []model.Province{target}
+	var supported []string
+	for i := 0; i < 10; i++ {
+		p := model.Province{ID: primitive.NewObjectID(), ProvinceName: "Province " + strconv.Itoa(i), DestroymentRound: -1}
+		provinces = append(provinces, p)
+		supported = append(supported, p.ID.Hex())
+	}
+	service := NewProvinceService(repo.NewMemoryProvinceRepo(provinces...), time.Now().Add(-48*time.Hour))
+	service.SetRules(playerRules())
+	round, _ := service.GetCurrentRound(ctx)
+
+	player := auth_model.User{ID: primitive.NewObjectID(), Username: "zart"}
+	players := auth_repo.NewMemoryUserRepo(player)
+	service.SetPlayers(slowPlayers{players})
+
+	bearer, err := token.GenerateToken(player.ID.Hex())
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	codes := map[int]int{}
+	var wg sync.WaitGroup
+	move := func(kind, provinceID, item string) {
+		defer wg.Done()
+		rr := moveAs(service, bearer, kind, provinceID, item)
+
+		mu.Lock()
+		codes[rr.Code]++
+		mu.Unlock()
+	}
+	for _, id := range supported {
+		wg.Add(2)
+		go move(game.MoveSupport, id, "")
+		go move(game.MoveAttack, target.ID.Hex(), game.ItemDoubleStrike)
+	}
+	wg.Wait()
+
+	// The double strike is used once, and no move saved over the provinces another supported
+	assert.Equal(t, map[int]int{http.StatusOK: 11, http.StatusConflict: 9}, codes)
+
+	saved, err := players.GetUserByID(ctx, player.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{game.ItemDoubleStrike: 1}, saved.Player.ItemUses)
+	assert.ElementsMatch(t, supported, saved.Player.Supported)
+	assert.Equal(t, auth_model.PlayerState{Streak: 1, LastMoveRound: round, ItemUses: saved.Player.ItemUses, ItemRound: round, Supported: saved.Player.Supported}, saved.Player)
+}
+
+func TestMoves_StreakAndItemsRenewed(t *testing.T) {
+	state := auth_model.PlayerState{Streak: 4, LastMoveRound: 7, ItemUses: map[string]int{game.ItemShield: 1}, ItemRound: 7}
+
+	assert.Equal(t, 4, nextStreak(state, 7))
+	assert.Equal(t, 5, nextStreak(state, 8))
+	assert.Equal(t, 1, nextStreak(state, 9))
+	assert.Equal(t, 1, nextStreak(auth_model.PlayerState{}, 1))
+
+	state.Supported = []string{"ankara"}
+
+	assert.Equal(t,
+		auth_model.PlayerState{Streak: 4, LastMoveRound: 7, ItemUses: map[string]int{game.ItemShield: 2}, ItemRound: 7, Supported: []string{"ankara", "izmir"}},
+		afterMove(state, model.Move{Kind: game.MoveSupport, ProvinceID: "izmir", Item: game.ItemShield, Round: 7}))
+	assert.Equal(t,
+		auth_model.PlayerState{Streak: 4, LastMoveRound: 7, ItemUses: map[string]int{game.ItemShield: 1}, ItemRound: 7, Supported: []string{"ankara"}},
+		afterMove(state, model.Move{Kind: game.MoveSupport, ProvinceID: "ankara", Round: 7}))
+	assert.Equal(t,
+		auth_model.PlayerState{Streak: 5, LastMoveRound: 8, ItemUses: map[string]int{}, ItemRound: 8},
+		afterMove(state, model.Move{Kind: game.MoveAttack, ProvinceID: "izmir", Round: 8}))
+	assert.Equal(t, 1, state.ItemUses[game.ItemShield])
+	assert.Equal(t, []string{"ankara"}, state.Supported)
+}
+
+func TestBufferedMoves_RecordedOnFlush(t *testing.T) {
+	ctx := context.Background()
+	provinceID := primitive.NewObjectID()
+	service := NewProvinceService(repo.NewMemoryProvinceRepo(model.Province{ID: provinceID, DestroymentRound: -1}), time.Now())
+	moveLog := repo.NewMemoryMoveRepo()
+	service.SetMoveFlushInterval(time.Hour)
+	service.SetMoveLog(moveLog)
+	round, _ := service.GetCurrentRound(ctx)
+
+	assert.Equal(t, http.StatusOK, move(service, game.MoveAttack, provinceID.Hex()))
+	moves, _ := moveLog.GetMovesByRound(ctx, round)
+	assert.Empty(t, moves)
+
+	require.NoError(t, service.FlushMoves(ctx))
+	moves, _ = moveLog.GetMovesByRound(ctx, round)
+	assert.Len(t, moves, 1)
+}
+
+func TestAttacks_AdjacentOnly(t *testing.T) {
+	ctx := context.Background()
+	home, border, inland, faraway := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ID: home, ProvinceName: "Ankara", DestroymentRound: -1, Neighbors: []string{border.Hex()}},
+		model.Province{ID: border, ProvinceName: "Konya", DestroymentRound: -1, Neighbors: []string{home.Hex(), inland.Hex()}},
+		model.Province{ID: inland, ProvinceName: "Antalya", DestroymentRound: -1, Neighbors: []string{border.Hex()}},
+		model.Province{ID: faraway, ProvinceName: "Van", DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now())
+	rules := game.DefaultRules()
+	rules.Attacks.AdjacentOnly = true
+	service.SetRules(rules)
+
+	player := auth_model.User{ID: primitive.NewObjectID(), Username: "zart", HomeProvinceID: home.Hex()}
+	service.SetPlayers(auth_repo.NewMemoryUserRepo(player))
+	bearer, err := token.GenerateToken(player.ID.Hex())
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, moveAs(service, bearer, game.MoveAttack, border.Hex(), "").Code)
+
+	rr := moveAs(service, bearer, game.MoveAttack, inland.Hex(), "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, response.CodeNotAdjacent, errorCode(t, rr))
+	assert.Contains(t, rr.Body.String(), "Antalya is not adjacent")
+
+	// Supporting Konya brings its neighbors in reach for the rest of the round
+	assert.Equal(t, http.StatusOK, moveAs(service, bearer, game.MoveSupport, border.Hex(), "").Code)
+	assert.Equal(t, http.StatusOK, moveAs(service, bearer, game.MoveAttack, inland.Hex(), "").Code)
+	assert.Equal(t, http.StatusForbidden, moveAs(service, bearer, game.MoveAttack, faraway.Hex(), "").Code)
+
+	// Anonymous players have no home, supports are still open to them
+	rr = moveAs(service, "", game.MoveAttack, border.Hex(), "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Log in to attack")
+	assert.Equal(t, http.StatusOK, moveAs(service, "", game.MoveSupport, faraway.Hex(), "").Code)
+
+	assert.Equal(t, http.StatusNotFound, moveAs(service, bearer, game.MoveAttack, primitive.NewObjectID().Hex(), "").Code)
+
+	province, err := provinceRepo.GetByID(ctx, inland.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, 1, province.AttackCount)
+}
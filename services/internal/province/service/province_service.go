@@ -2,188 +2,361 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
+	auth_model "services/internal/auth/model"
+	"services/internal/core/logging"
+	"services/internal/core/metrics"
+	"services/internal/core/response"
+	"services/internal/core/tracing"
+	"services/internal/core/validation"
+	"services/internal/game"
 	"services/internal/province/model"
 	"services/internal/province/repo"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/kahlery/pkg/go/auth/token"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ProvinceRepository is the storage the province service needs, implemented by
+// repo.ProvinceRepo (MongoDB) and repo.MemoryProvinceRepo. Implementations log their
+// own failures with the context they are given
+type ProvinceRepository interface {
+	GetAll(ctx context.Context) ([]model.Province, error)
+	GetByID(ctx context.Context, id string) (*model.Province, error)
+	UpdateProvinceByID(ctx context.Context, id string, isAttackNorSupport bool, weight int) error
+	ApplyCountDeltas(ctx context.Context, deltas []model.CountDelta) error
+	ShieldProvince(ctx context.Context, id string, minSupports int) (shielded bool, err error)
+	NukeProvinces(ctx context.Context, roundCount int, ids []string) error
+	SetPreviousScores(ctx context.Context, scores map[string]float64) error
+	ResetAllProvinceCounts(ctx context.Context) error
+	CarryProvinceCounts(ctx context.Context, decay float64) error
+}
+
+// PlayerRepository is where the players moves are weighed for are read and saved,
+// implemented by the auth user repos
+type PlayerRepository interface {
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (*auth_model.User, error)
+	PutPlayerState(ctx context.Context, id primitive.ObjectID, state auth_model.PlayerState) error
+}
+
+// MoveRepository keeps every counted move, implemented by repo.MoveRepo and its memory
+// and SQL siblings
+type MoveRepository interface {
+	RecordMoves(ctx context.Context, moves []model.Move) error
+}
+
+// AllianceRepository is where alliances are kept, implemented by repo.AllianceRepo and its
+// memory and SQL siblings
+type AllianceRepository interface {
+	GetAll(ctx context.Context) ([]model.Alliance, error)
+	GetByID(ctx context.Context, id string) (*model.Alliance, error)
+	GetByProvinceID(ctx context.Context, provinceID string) (*model.Alliance, error)
+	InsertAlliance(ctx context.Context, alliance *model.Alliance) error
+	UpdateAlliance(ctx context.Context, alliance model.Alliance) error
+	DeleteAlliance(ctx context.Context, id string) error
+	DissolveAlliancesOf(ctx context.Context, provinceIDs []string) ([]model.Alliance, error)
+}
+
+// resetTimeout bounds the count reset that finishes a round even when it is being aborted
+const resetTimeout = 5 * time.Second
+
 type ProvinceService struct {
-	repo      *repo.ProvinceRepo
-	startDate time.Time // Game start date
+	repo        ProvinceRepository
+	startDate   time.Time     // Game start date
+	roundLength time.Duration // 0 for daily rounds ending at 14:00 UTC
+	metrics     *metrics.Metrics
+	tracer      trace.Tracer
+	cache       *responseCache // province list and standings
+	moves       *moveBuffer    // nil unless moves are written in bulk
+
+	rules   game.Rules
+	players PlayerRepository // nil weighs every move as anonymous
+	moveLog MoveRepository   // nil records no moves
+	movers  playerLocks      // serializes the moves of each player with the state they save
+
+	alliances  AllianceRepository // nil turns alliances off
+	allianceMu sync.Mutex         // serializes membership checks with the writes they allow
 }
 
-func NewProvinceService(repo *repo.ProvinceRepo, startDate time.Time) *ProvinceService {
+func NewProvinceService(repo ProvinceRepository, startDate time.Time) *ProvinceService {
 	return &ProvinceService{
 		repo:      repo,
 		startDate: startDate,
+		tracer:    tracing.Tracer(nil),
+		cache:     newResponseCache(0),
+		rules:     game.DefaultRules(),
 	}
 }
 
+// SetMetrics records moves and nuke rounds on m, nothing is recorded by default
+func (ps *ProvinceService) SetMetrics(m *metrics.Metrics) {
+	ps.metrics = m
+}
+
+// SetTracerProvider records spans on tp, nothing is recorded by default
+func (ps *ProvinceService) SetTracerProvider(tp trace.TracerProvider) {
+	ps.tracer = tracing.Tracer(tp)
+}
+
+// SetCacheTTL serves the province list and standings from memory for up to ttl after
+// they were read, moves show once it is over. Nothing is cached by default
+func (ps *ProvinceService) SetCacheTTL(ttl time.Duration) {
+	ps.cache = newResponseCache(ttl)
+}
+
+// SetRoundLength makes every round last d from the start date on, for games nuked on
+// their own schedule. Rounds last a day and end at 14:00 UTC by default
+func (ps *ProvinceService) SetRoundLength(d time.Duration) {
+	ps.roundLength = d
+}
+
 // --------------------------------------------------------------------
 func (ps *ProvinceService) GetAllProvinces(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.GetAllProvinces")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	provinces, err := ps.repo.GetAll(ctx)
+	cached, hit, err := ps.cache.get(ctx, cacheKeyProvinces, func(ctx context.Context) (any, error) {
+		provinces, err := ps.repo.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Wrap the provinces in the DTO
+		return model.GetAllProvinceResponse{
+			ProvinceList: provinces,
+		}, nil
+	})
+	ps.metrics.CacheLookup(cacheKeyProvinces, hit)
 	if err != nil {
-		http.Error(w, "Failed to get all provinces", http.StatusInternalServerError)
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to get all provinces")
 		return
 	}
 
-	// Wrap the provinces in the DTO
-	response := model.GetAllProvinceResponse{
-		ProvinceList: provinces,
+	response.Cached(w, r, cached.body, cached.etag)
+}
+
+// GetProvince returns a single province by ID
+func (ps *ProvinceService) GetProvince(w http.ResponseWriter, r *http.Request) {
+	req := model.GetProvinceRequest{ID: r.PathValue("id")}
+	if err := validation.Struct(req); err != nil {
+		validation.WriteError(w, r, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.GetProvince", trace.WithAttributes(attribute.String(tracing.KeyProvinceID, req.ID)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(logging.With(ctx, logging.ProvinceID(req.ID)), 5*time.Second)
+	defer cancel()
+
+	province, err := ps.repo.GetByID(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			response.Error(w, r, http.StatusNotFound, response.CodeNotFound, "Province not found")
+			return
+		}
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to get province")
+		return
 	}
+
+	response.JSON(w, http.StatusOK, model.GetProvinceResponse{
+		Province: *province,
+	})
 }
 
-// GetTopProvinces returns the top 5 provinces by score difference (attackCount - supportCount)
+// GetTopProvinces returns the top 5 living provinces by the game's scoring rule, the
+// ranking the nuke uses
 func (ps *ProvinceService) GetTopProvinces(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.GetTopProvinces")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// Get provinces sorted by score difference
-	provinces, err := ps.repo.GetProvincesByScoreDifference(ctx)
+	cached, hit, err := ps.cache.get(ctx, cacheKeyTop, func(ctx context.Context) (any, error) {
+		// Rank the living provinces like the nuke does
+		all, err := ps.repo.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		provinces := ps.living(all)
+
+		// Take only the top 5 (or less if there are fewer than 5 provinces)
+		topCount := 5
+		if len(provinces) < topCount {
+			topCount = len(provinces)
+		}
+		topProvinces := provinces[:topCount]
+
+		// Wrap in response object
+		return model.GetTopProvincesResponse{
+			Provinces: topProvinces,
+		}, nil
+	})
+	ps.metrics.CacheLookup(cacheKeyTop, hit)
 	if err != nil {
-		http.Error(w, "Failed to get top provinces", http.StatusInternalServerError)
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to get top provinces")
 		return
 	}
 
-	// Take only the top 5 (or less if there are fewer than 5 provinces)
-	topCount := 5
-	if len(provinces) < topCount {
-		topCount = len(provinces)
-	}
-	topProvinces := provinces[:topCount]
+	response.Cached(w, r, cached.body, cached.etag)
+}
 
-	// Wrap in response object
-	response := struct {
-		Provinces []model.Province `json:"provinces"`
-	}{
-		Provinces: topProvinces,
-	}
+// GetProjection returns the provinces the nuke would take if the round ended now
+func (ps *ProvinceService) GetProjection(w http.ResponseWriter, r *http.Request) {
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.GetProjection")
+	defer span.End()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-// AttackProvince increases attack count by 1
-func (ps *ProvinceService) AttackProvince(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	cached, hit, err := ps.cache.get(ctx, cacheKeyProjected, func(ctx context.Context) (any, error) {
+		provinces, err := ps.repo.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return model.GetProjectionResponse{
+			Round:   ps.nukeRound(),
+			Scoring: ps.scoringName(),
+			Victims: ps.victims(provinces),
+		}, nil
+	})
+	ps.metrics.CacheLookup(cacheKeyProjected, hit)
+	if err != nil {
+		tracing.Fail(span, err)
+		response.Internal(w, r, "Failed to get the nuke projection")
 		return
 	}
 
+	response.Cached(w, r, cached.body, cached.etag)
+}
+
+// AttackProvince increases attack count by the weight of the move
+func (ps *ProvinceService) AttackProvince(w http.ResponseWriter, r *http.Request) {
 	// This is for making sure if a logged in user is attacking
 	/*	_, err := ExtractUserIDFromRequest(r)
 		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
 			return
 		} */
 
-	// Parse request body
+	// Parse and validate request body, the province ID must be a valid ObjectID
 	var req model.AttackProvinceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
 		return
 	}
 
-	// Validate province ID
-	if req.ProvinceID == "" {
-		http.Error(w, "Province ID is required", http.StatusBadRequest)
-		return
-	}
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.AttackProvince", trace.WithAttributes(attribute.String(tracing.KeyProvinceID, req.ProvinceID)))
+	defer span.End()
 
-	// Validate ObjectID format
-	if _, err := primitive.ObjectIDFromHex(req.ProvinceID); err != nil {
-		http.Error(w, "Invalid province ID format", http.StatusBadRequest)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(logging.With(ctx, logging.ProvinceID(req.ProvinceID)), 5*time.Second)
 	defer cancel()
 
-	// Update province attack count
-	if err := ps.repo.UpdateProvinceByID(ctx, req.ProvinceID, true); err != nil {
-		http.Error(w, "Failed to update province", http.StatusInternalServerError)
+	// Weigh and count the move
+	move, err := ps.makeMove(ctx, r, game.MoveAttack, req.ProvinceID, req.Item)
+	if err != nil {
+		writeMoveError(w, r, span, err)
 		return
 	}
+	ps.metrics.ProvinceMove(req.ProvinceID, metrics.MoveAttack)
 
-	response := model.AttackProvinceResponse{
+	response.JSON(w, http.StatusOK, model.AttackProvinceResponse{
 		IsSuccess: true,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+		Move:      move,
+	})
 }
 
 // --------------------------------------------------------------------
-// SupportProvince increases support count by 1
+// SupportProvince increases support count by the weight of the move
 func (ps *ProvinceService) SupportProvince(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST method
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// This is for making sure if a logged in user is supporting
 	// Extract user ID from JWT token
 	/*	_, err := ExtractUserIDFromRequest(r)
 		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Error(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
 			return
 		} */
 
-	// Parse request body
+	// Parse and validate request body, the province ID must be a valid ObjectID
 	var req model.SupportProvinceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		validation.WriteError(w, r, err)
 		return
 	}
 
-	// Validate province ID
-	if req.ProvinceID == "" {
-		http.Error(w, "Province ID is required", http.StatusBadRequest)
+	ctx, span := ps.tracer.Start(r.Context(), "ProvinceService.SupportProvince", trace.WithAttributes(attribute.String(tracing.KeyProvinceID, req.ProvinceID)))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(logging.With(ctx, logging.ProvinceID(req.ProvinceID)), 5*time.Second)
+	defer cancel()
+
+	// Weigh and count the move
+	move, err := ps.makeMove(ctx, r, game.MoveSupport, req.ProvinceID, req.Item)
+	if err != nil {
+		writeMoveError(w, r, span, err)
 		return
 	}
+	ps.metrics.ProvinceMove(req.ProvinceID, metrics.MoveSupport)
 
-	// Validate ObjectID format
-	if _, err := primitive.ObjectIDFromHex(req.ProvinceID); err != nil {
-		http.Error(w, "Invalid province ID format", http.StatusBadRequest)
-		return
+	response.JSON(w, http.StatusOK, model.SupportProvinceResponse{
+		IsSuccess: true,
+		Move:      move,
+	})
+}
+
+// countMove applies a move to a province and records it, or buffers both when moves are
+// written in bulk
+func (ps *ProvinceService) countMove(ctx context.Context, move model.Move) error {
+	if ps.moves != nil {
+		return ps.moves.add(ctx, move)
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	isAttack := move.Kind == game.MoveAttack
+	if err := ps.repo.UpdateProvinceByID(ctx, move.ProvinceID, isAttack, move.Weight); err != nil {
+		return err
+	}
+	ps.recordMoves(ctx, move)
+	if !isAttack {
+		ps.shieldSupported(ctx, []model.CountDelta{{ProvinceID: move.ProvinceID, Supports: move.Weight}})
+	}
+	return nil
+}
 
-	// Update province support count
-	if err := ps.repo.UpdateProvinceByID(ctx, req.ProvinceID, false); err != nil {
-		http.Error(w, "Failed to update province", http.StatusInternalServerError)
+// shieldSupported shields the provinces among deltas whose supports reached the shield
+// threshold this round. Failures are logged by the repository, the next support retries
+func (ps *ProvinceService) shieldSupported(ctx context.Context, deltas []model.CountDelta) {
+	threshold := ps.rules.Shields.SupportThreshold
+	if threshold <= 0 {
 		return
 	}
 
-	response := model.SupportProvinceResponse{
-		IsSuccess: true,
-	}
+	for _, delta := range deltas {
+		if delta.Supports == 0 {
+			continue
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+		shielded, err := ps.repo.ShieldProvince(ctx, delta.ProvinceID, threshold)
+		if err != nil || !shielded {
+			continue
+		}
+		slog.InfoContext(ctx, "Province shielded from the next nuke", logging.ProvinceID(delta.ProvinceID))
+	}
 }
 
 // --------------------------------------------------------------------
@@ -192,59 +365,151 @@ func (ps *ProvinceService) UpdateDestroymentRound(w http.ResponseWriter, r *http
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	// Calculate round count (days passed since start date)
-	currentTime := time.Now()
-	daysPassed := int(currentTime.Sub(ps.startDate).Hours() / 24)
-	roundCount := daysPassed
+	roundCount := ps.nukeRound()
+
+	// Buffered moves count towards this round, the ones made meanwhile towards the next
+	release, err := ps.holdMoves(ctx)
+	if err != nil {
+		response.Internal(w, r, "Failed to write buffered moves")
+		return
+	}
+	defer release()
 
-	// Update destroyment round of the worst province (highest attackCount - supportCount)
-	err := ps.repo.UpdateDestroymentRoundOfTheWorstProvince(ctx, roundCount)
+	// Update destroyment round of the worst provinces by the game's scoring rule
+	victims, scores, err := ps.nukeWorst(ctx, roundCount)
+	ps.cache.invalidate()
 	if err != nil {
-		http.Error(w, "Failed to update destroyment round", http.StatusInternalServerError)
+		response.Internal(w, r, "Failed to update destroyment round")
 		return
 	}
 
 	// Reset all provinces' attack and support counts
-	err = ps.repo.ResetAllProvinceCounts(ctx)
+	err = ps.endRound(ctx, scores)
+	ps.cache.invalidate()
 	if err != nil {
-		http.Error(w, "Failed to reset province counts", http.StatusInternalServerError)
+		response.Internal(w, r, "Failed to reset province counts")
 		return
 	}
 
-	response := struct {
-		Message    string `json:"message"`
-		RoundCount int    `json:"round_count"`
-	}{
+	response.JSON(w, http.StatusOK, model.UpdateDestroymentRoundResponse{
 		Message:    "Destroyment round updated and counts reset successfully",
 		RoundCount: roundCount,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+		Victims:    victims,
+	})
 }
 
 // --------------------------------------------------------------------
-// ExecuteDestroymentRound performs the nuke operation without HTTP context (for cron jobs)
-func (ps *ProvinceService) ExecuteDestroymentRound(ctx context.Context) (int, error) {
-	// Calculate round count (days passed since start date)
-	currentTime := time.Now()
-	daysPassed := int(currentTime.Sub(ps.startDate).Hours() / 24)
-	roundCount := daysPassed
-
-	// Update destroyment round of the worst province (highest attackCount - supportCount)
-	err := ps.repo.UpdateDestroymentRoundOfTheWorstProvince(ctx, roundCount)
+// ExecuteDestroymentRound performs the nuke operation without HTTP context (for cron jobs).
+// A cancelled context aborts the round before the next step starts.
+func (ps *ProvinceService) ExecuteDestroymentRound(ctx context.Context) (model.RoundResult, error) {
+	ctx, span := ps.tracer.Start(ctx, "ProvinceService.ExecuteDestroymentRound")
+	defer span.End()
+
+	start := time.Now()
+	result, err := ps.executeDestroymentRound(ctx)
+	ps.cache.invalidate()
+	ps.metrics.ObserveNukeRound(time.Since(start), err)
+	tracing.Fail(span, err)
+
+	return result, err
+}
+
+func (ps *ProvinceService) executeDestroymentRound(ctx context.Context) (model.RoundResult, error) {
+	if err := ctx.Err(); err != nil {
+		return model.RoundResult{}, err
+	}
+
+	roundCount := ps.nukeRound()
+	ctx = logging.With(ctx, logging.Round(roundCount))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int(tracing.KeyRound, roundCount))
+
+	// Buffered moves count towards this round, the ones made meanwhile towards the next
+	release, err := ps.holdMoves(ctx)
 	if err != nil {
-		return 0, err
+		return model.RoundResult{}, err
 	}
+	defer release()
 
-	// Reset all provinces' attack and support counts
-	err = ps.repo.ResetAllProvinceCounts(ctx)
+	// Update destroyment round of the worst provinces by the game's scoring rule
+	victims, scores, err := ps.nukeWorst(ctx, roundCount)
+	if err != nil {
+		return model.RoundResult{}, err
+	}
+	names := make([]string, len(victims))
+	for i, p := range victims {
+		names[i] = p.ProvinceName
+	}
+	slog.InfoContext(ctx, "Worst provinces nuked, resetting counts", "victims", names)
+
+	// Reset all provinces' attack and support counts. This step is not skipped on abort,
+	// a nuked province with leftover counts would be picked again by the next round
+	resetCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resetTimeout)
+	defer cancel()
+
+	err = ps.endRound(resetCtx, scores)
+	if err != nil {
+		return model.RoundResult{}, err
+	}
+
+	return model.RoundResult{
+		Round:   roundCount,
+		Victims: victims,
+	}, nil
+}
+
+// nukeWorst nukes the worst living provinces, as many as the rules ask for with this
+// many of them alive. scores are what the living provinces ended the round with
+func (ps *ProvinceService) nukeWorst(ctx context.Context, roundCount int) (victims []model.Province, scores map[string]float64, err error) {
+	provinces, err := ps.repo.GetAll(ctx)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
+	}
+
+	ranked := ps.victims(provinces)
+	ids := make([]string, len(ranked))
+	victims = make([]model.Province, len(ranked))
+	for i, p := range ranked {
+		ids[i] = p.ID.Hex()
+		victims[i] = p.Province
+		victims[i].DestroymentRound = roundCount
+	}
+
+	if err := ps.repo.NukeProvinces(ctx, roundCount, ids); err != nil {
+		return nil, nil, err
+	}
+	ps.dissolveAlliances(ctx, ids)
+
+	return victims, ps.scores(provinces), nil
+}
+
+// endRound keeps the scores the round ended with for the next one, then resets the counts,
+// carrying their decayed share over if the rules ask for it
+func (ps *ProvinceService) endRound(ctx context.Context, scores map[string]float64) error {
+	if err := ps.repo.SetPreviousScores(ctx, scores); err != nil {
+		return err
+	}
+
+	if decay := ps.rules.Carry.Decay; decay > 0 {
+		return ps.repo.CarryProvinceCounts(ctx, decay)
+	}
+	return ps.repo.ResetAllProvinceCounts(ctx)
+}
+
+// nukeRound is the round a nuke run now stamps, the days passed since the start date or
+// the rounds of the set length
+func (ps *ProvinceService) nukeRound() int {
+	if ps.roundLength > 0 {
+		return int(time.Since(ps.startDate) / ps.roundLength)
 	}
+	return int(time.Since(ps.startDate).Hours() / 24)
+}
 
-	return roundCount, nil
+// scoringName is the name of the game's scoring rule
+func (ps *ProvinceService) scoringName() string {
+	if ps.rules.Scoring.Rule == "" {
+		return game.ScoringDifference
+	}
+	return ps.rules.Scoring.Rule
 }
 
 // --------------------------------------------------------------------
@@ -252,6 +517,9 @@ func (ps *ProvinceService) ExecuteDestroymentRound(ctx context.Context) (int, er
 func (ps *ProvinceService) GetCurrentRound(ctx context.Context) (int, error) {
 	// Calculate current round based on start date
 	now := time.Now().UTC()
+	if ps.roundLength > 0 {
+		return int(now.Sub(ps.startDate)/ps.roundLength) + 1, nil
+	}
 	daysSinceStart := int(now.Sub(ps.startDate).Hours() / 24)
 
 	// If it's past 14:00 UTC today, we're in the next round
@@ -264,22 +532,18 @@ func (ps *ProvinceService) GetCurrentRound(ctx context.Context) (int, error) {
 }
 
 func (ps *ProvinceService) GetCurrentRoundHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	ctx := r.Context()
 
 	roundCount, err := ps.GetCurrentRound(ctx)
 	if err != nil {
-		http.Error(w, "Failed to get current round", http.StatusInternalServerError)
+		response.Internal(w, r, "Failed to get current round")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	response := fmt.Sprintf(`{"round": %d, "success": true}`, roundCount)
-	w.Write([]byte(response))
+	response.JSON(w, http.StatusOK, model.GetCurrentRoundResponse{
+		Round:   roundCount,
+		Success: true,
+	})
 }
 
 // ExtractUserIDFromRequest extracts user ID from the Authorization header
@@ -314,7 +578,8 @@ func ExtractUserIDFromRequest(r *http.Request) (string, error) {
 		return "", errors.New("invalid token claims")
 	}
 
-	userID, ok := claims["userID"].(string)
+	// token.GenerateToken stores the user ID under the "userName" claim
+	userID, ok := claims["userName"].(string)
 	if !ok || userID == "" {
 		return "", errors.New("userID not found in token")
 	}
@@ -9,7 +9,10 @@ import (
 
 	// Testing related packages
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	// Testify
 	"github.com/stretchr/testify/assert"
@@ -18,7 +21,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	// Internal dependencies
+	"services/internal/game"
 	"services/internal/province/model"
+	"services/internal/province/repo"
 )
 
 // Create a test-specific interface that matches the methods we need to mock
@@ -316,3 +321,471 @@ func TestSupportProvince_UpdateError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestExecuteDestroymentRound_AbortedBeforeStart(t *testing.T) {
+	provinceRepo := repo.NewMemoryProvinceRepo(model.Province{ProvinceName: "Zartistan", AttackCount: 5, DestroymentRound: -1})
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := service.ExecuteDestroymentRound(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	provinces, _ := provinceRepo.GetAll(context.Background())
+	assert.Equal(t, -1, provinces[0].DestroymentRound)
+	assert.Equal(t, 5, provinces[0].AttackCount)
+}
+
+func TestExecuteDestroymentRound(t *testing.T) {
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ProvinceName: "Zartistan", AttackCount: 5, SupportCount: 1, DestroymentRound: -1},
+		model.Province{ProvinceName: "Zortistan", AttackCount: 2, SupportCount: 7, DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+
+	result, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Round)
+	if assert.Len(t, result.Victims, 1) {
+		assert.Equal(t, "Zartistan", result.Victims[0].ProvinceName)
+	}
+
+	provinces, _ := provinceRepo.GetAll(context.Background())
+	assert.Equal(t, 2, provinces[0].DestroymentRound)
+	assert.Equal(t, -1, provinces[1].DestroymentRound)
+	assert.Zero(t, provinces[0].AttackCount+provinces[1].SupportCount)
+}
+
+func TestExecuteDestroymentRound_ScaledNukes(t *testing.T) {
+	var provinces []model.Province
+	for i := range 7 {
+		provinces = append(provinces, model.Province{ProvinceName: string(rune('A' + i)), AttackCount: i, DestroymentRound: -1})
+	}
+	provinces[0].DestroymentRound = 1 // already nuked, doesn't count as living
+	provinceRepo := repo.NewMemoryProvinceRepo(provinces...)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	rules := game.DefaultRules()
+	rules.Nukes = game.NukeRules{LivingPerNuke: 4}
+	service.SetRules(rules)
+
+	// 6 living provinces, one nuke for every 4 of them
+	result, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	var names []string
+	for _, p := range result.Victims {
+		assert.Equal(t, 2, p.DestroymentRound)
+		names = append(names, p.ProvinceName)
+	}
+	assert.Equal(t, []string{"G", "F"}, names)
+
+	// One a round from 4 living on, the last survivor is never nuked
+	for range 3 {
+		result, err = service.ExecuteDestroymentRound(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, result.Victims, 1)
+	}
+	result, err = service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, result.Victims)
+
+	all, _ := provinceRepo.GetAll(context.Background())
+	living := 0
+	for _, p := range all {
+		if p.DestroymentRound == -1 {
+			living++
+		}
+	}
+	assert.Equal(t, 1, living)
+}
+
+func TestScoringRule_SharedByNukeTopAndProjection(t *testing.T) {
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ProvinceName: "Swarmed", AttackCount: 30, SupportCount: 25, DestroymentRound: -1},
+		model.Province{ProvinceName: "Forgotten", AttackCount: 3, DestroymentRound: -1},
+		model.Province{ProvinceName: "Loved", SupportCount: 9, DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	rules := game.DefaultRules()
+	rules.Scoring = game.ScoringRules{Rule: game.ScoringAttackShare}
+	service.SetRules(rules)
+
+	// By difference Swarmed would go, by attack share it's the province nobody defends
+	var top model.GetTopProvincesResponse
+	assert.NoError(t, json.NewDecoder(get(service.GetTopProvinces, "/api/province/top", "").Body).Decode(&top))
+	var projection model.GetProjectionResponse
+	assert.NoError(t, json.NewDecoder(get(service.GetProjection, "/api/province/projection", "").Body).Decode(&projection))
+
+	assert.Equal(t, game.ScoringAttackShare, projection.Scoring)
+	assert.Equal(t, 2, projection.Round)
+	assert.Len(t, projection.Victims, 1)
+	assert.Equal(t, "Forgotten", projection.Victims[0].ProvinceName)
+	assert.Equal(t, 1.0, projection.Victims[0].Score)
+	assert.Equal(t, top.Provinces[0], projection.Victims[0])
+
+	result, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result.Victims, 1)
+	assert.Equal(t, "Forgotten", result.Victims[0].ProvinceName)
+}
+
+func TestScoringRule_WeightedDecayCarriesPastRounds(t *testing.T) {
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ProvinceName: "Old grudge", AttackCount: 10, DestroymentRound: -1},
+		model.Province{ProvinceName: "Fresh target", SupportCount: 1, DestroymentRound: -1},
+		model.Province{ProvinceName: "Bystander", DestroymentRound: -1},
+		model.Province{ProvinceName: "Survivor", DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	rules := game.DefaultRules()
+	rules.Scoring = game.ScoringRules{Rule: game.ScoringWeightedDecay, Decay: 0.5}
+	rules.Nukes = game.NukeRules{PerRound: 1}
+	service.SetRules(rules)
+
+	// Shield the round's worst so it survives with its score
+	provinces, _ := provinceRepo.GetAll(context.Background())
+	grudge, fresh := provinces[0].ID.Hex(), provinces[1].ID.Hex()
+	_, err := provinceRepo.ShieldProvince(context.Background(), grudge, 0)
+	assert.NoError(t, err)
+	result, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Bystander", result.Victims[0].ProvinceName)
+
+	carried, err := provinceRepo.GetByID(context.Background(), grudge)
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, carried.PreviousScore)
+	assert.Zero(t, carried.AttackCount)
+
+	// 4 fresh attacks lose to half of the 10 carried over
+	assert.NoError(t, provinceRepo.UpdateProvinceByID(context.Background(), fresh, true, 4))
+	result, err = service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result.Victims, 1)
+	assert.Equal(t, "Old grudge", result.Victims[0].ProvinceName)
+}
+
+func TestExecuteDestroymentRound_CarriesDecayedCounts(t *testing.T) {
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ProvinceName: "Besieged", AttackCount: 8, DestroymentRound: -1},
+		model.Province{ProvinceName: "Fortress", AttackCount: 6, SupportCount: 10, DestroymentRound: -1},
+		model.Province{ProvinceName: "Quiet", AttackCount: 5, DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	rules := game.DefaultRules()
+	rules.Carry = game.CarryRules{Decay: 0.5}
+	service.SetRules(rules)
+
+	result, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Besieged", result.Victims[0].ProvinceName)
+
+	provinces, _ := provinceRepo.GetAll(context.Background())
+	fortress, quiet := provinces[1], provinces[2]
+	assert.Zero(t, fortress.AttackCount)
+	assert.Equal(t, 3.0, fortress.CarriedAttacks)
+	assert.Equal(t, 5.0, fortress.CarriedSupports)
+	assert.Equal(t, 2.5, quiet.CarriedAttacks)
+
+	// The support Fortress built last round still outweighs a fresh attack
+	assert.NoError(t, provinceRepo.UpdateProvinceByID(context.Background(), fortress.ID.Hex(), true, 2))
+	var projection model.GetProjectionResponse
+	assert.NoError(t, json.NewDecoder(get(service.GetProjection, "/api/province/projection", "").Body).Decode(&projection))
+	assert.Equal(t, "Quiet", projection.Victims[0].ProvinceName)
+
+	// Without carry-over every count is wiped
+	service.SetRules(game.DefaultRules())
+	_, err = service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+	provinces, _ = provinceRepo.GetAll(context.Background())
+	assert.Zero(t, provinces[1].CarriedAttacks)
+	assert.Zero(t, provinces[1].CarriedSupports)
+}
+
+// countingRepo counts the reads reaching the repository behind it
+type countingRepo struct {
+	ProvinceRepository
+	reads int
+}
+
+func (cr *countingRepo) GetAll(ctx context.Context) ([]model.Province, error) {
+	cr.reads++
+	return cr.ProvinceRepository.GetAll(ctx)
+}
+
+func get(handler http.HandlerFunc, target, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestGetAllProvinces_CachedForTheTTL(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	provinceRepo := &countingRepo{ProvinceRepository: repo.NewMemoryProvinceRepo(
+		model.Province{ID: provinceID, ProvinceName: "Zartistan", DestroymentRound: -1},
+	)}
+	service := NewProvinceService(provinceRepo, time.Now())
+	service.SetCacheTTL(100 * time.Millisecond)
+
+	first := get(service.GetAllProvinces, "/api/province", "")
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rr := httptest.NewRecorder()
+	service.AttackProvince(rr, httptest.NewRequest(http.MethodPost, "/api/province/attack", strings.NewReader(`{"province_id": "`+provinceID.Hex()+`"}`)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Polling with the tag is answered from the cache, moves don't drop it
+	revalidated := get(service.GetAllProvinces, "/api/province", etag)
+	assert.Equal(t, http.StatusNotModified, revalidated.Code)
+	assert.Equal(t, 1, provinceRepo.reads)
+
+	// The move shows once the ttl is over
+	time.Sleep(100 * time.Millisecond)
+	changed := get(service.GetAllProvinces, "/api/province", etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+	assert.Equal(t, 2, provinceRepo.reads)
+
+	var body model.GetAllProvinceResponse
+	assert.NoError(t, json.Unmarshal(changed.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.ProvinceList[0].AttackCount)
+}
+
+// blockingRepo holds every read of the provinces until release is closed, a read whose
+// context ended meanwhile fails
+type blockingRepo struct {
+	ProvinceRepository
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (br *blockingRepo) GetAll(ctx context.Context) ([]model.Province, error) {
+	br.reads.Add(1)
+	<-br.release
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return br.ProvinceRepository.GetAll(ctx)
+}
+
+func TestGetAllProvinces_ConcurrentMissesShareALoad(t *testing.T) {
+	provinceRepo := &blockingRepo{
+		ProvinceRepository: repo.NewMemoryProvinceRepo(model.Province{ProvinceName: "Zartistan", DestroymentRound: -1}),
+		release:            make(chan struct{}),
+	}
+	service := NewProvinceService(provinceRepo, time.Now())
+
+	codes := make(chan int, 10)
+	for range 10 {
+		go func() { codes <- get(service.GetAllProvinces, "/api/province", "").Code }()
+	}
+
+	// Every request arrives while the first load is still reading
+	assert.Eventually(t, func() bool { return provinceRepo.reads.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(provinceRepo.release)
+
+	for range 10 {
+		assert.Equal(t, http.StatusOK, <-codes)
+	}
+	assert.Equal(t, int32(1), provinceRepo.reads.Load())
+}
+
+func TestGetAllProvinces_SharedLoadOutlivesTheFirstCaller(t *testing.T) {
+	provinceRepo := &blockingRepo{
+		ProvinceRepository: repo.NewMemoryProvinceRepo(model.Province{ProvinceName: "Zartistan", DestroymentRound: -1}),
+		release:            make(chan struct{}),
+	}
+	service := NewProvinceService(provinceRepo, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan int, 1)
+	go func() {
+		rr := httptest.NewRecorder()
+		service.GetAllProvinces(rr, httptest.NewRequest(http.MethodGet, "/api/province", nil).WithContext(ctx))
+		leader <- rr.Code
+	}()
+	assert.Eventually(t, func() bool { return provinceRepo.reads.Load() == 1 }, time.Second, time.Millisecond)
+
+	joined := make(chan int, 1)
+	go func() { joined <- get(service.GetAllProvinces, "/api/province", "").Code }()
+	time.Sleep(50 * time.Millisecond)
+
+	// The first client goes away, the one that joined its load still gets the provinces
+	cancel()
+	assert.Equal(t, http.StatusInternalServerError, <-leader)
+	close(provinceRepo.release)
+
+	assert.Equal(t, http.StatusOK, <-joined)
+	assert.Equal(t, int32(1), provinceRepo.reads.Load())
+}
+
+func TestGetTopProvinces_CacheDroppedByNuke(t *testing.T) {
+	provinceRepo := &countingRepo{ProvinceRepository: repo.NewMemoryProvinceRepo(
+		model.Province{ProvinceName: "Zartistan", AttackCount: 5, DestroymentRound: -1},
+		model.Province{ProvinceName: "Zortistan", AttackCount: 2, DestroymentRound: -1},
+	)}
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	service.SetCacheTTL(time.Minute)
+
+	etag := get(service.GetTopProvinces, "/api/province/top", "").Header().Get("ETag")
+	assert.Equal(t, http.StatusNotModified, get(service.GetTopProvinces, "/api/province/top", etag).Code)
+	assert.Equal(t, 1, provinceRepo.reads)
+
+	_, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, get(service.GetTopProvinces, "/api/province/top", etag).Code)
+}
+
+func TestGetAllProvinces_NotCachedByDefault(t *testing.T) {
+	provinceRepo := &countingRepo{ProvinceRepository: repo.NewMemoryProvinceRepo()}
+	service := NewProvinceService(provinceRepo, time.Now())
+
+	etag := get(service.GetAllProvinces, "/api/province", "").Header().Get("ETag")
+	assert.Equal(t, http.StatusNotModified, get(service.GetAllProvinces, "/api/province", etag).Code)
+	assert.Equal(t, 2, provinceRepo.reads)
+}
+
+// flakyRepo fails the next bulk writes
+type flakyRepo struct {
+	ProvinceRepository
+	failures int
+}
+
+func (fr *flakyRepo) ApplyCountDeltas(ctx context.Context, deltas []model.CountDelta) error {
+	if fr.failures > 0 {
+		fr.failures--
+		return errors.New("connection reset")
+	}
+	return fr.ProvinceRepository.ApplyCountDeltas(ctx, deltas)
+}
+
+func move(service *ProvinceService, kind, provinceID string) int {
+	handler := service.AttackProvince
+	if kind == "support" {
+		handler = service.SupportProvince
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/api/province/"+kind, strings.NewReader(`{"province_id": "`+provinceID+`"}`)))
+	return rr.Code
+}
+
+func TestBufferedMoves_WrittenOnFlush(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	provinceRepo := repo.NewMemoryProvinceRepo(model.Province{ID: provinceID, ProvinceName: "Zartistan", DestroymentRound: -1})
+	service := NewProvinceService(provinceRepo, time.Now())
+	service.SetMoveFlushInterval(time.Hour)
+
+	assert.Equal(t, http.StatusOK, move(service, "attack", provinceID.Hex()))
+	assert.Equal(t, http.StatusOK, move(service, "attack", provinceID.Hex()))
+	assert.Equal(t, http.StatusOK, move(service, "support", provinceID.Hex()))
+	assert.Equal(t, http.StatusNotFound, move(service, "attack", primitive.NewObjectID().Hex()))
+
+	province, _ := provinceRepo.GetByID(context.Background(), provinceID.Hex())
+	assert.Zero(t, province.AttackCount)
+
+	assert.NoError(t, service.FlushMoves(context.Background()))
+
+	province, _ = provinceRepo.GetByID(context.Background(), provinceID.Hex())
+	assert.Equal(t, 2, province.AttackCount)
+	assert.Equal(t, 1, province.SupportCount)
+}
+
+func TestBufferedMoves_KeptWhenFlushFails(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	memoryRepo := repo.NewMemoryProvinceRepo(model.Province{ID: provinceID, ProvinceName: "Zartistan", DestroymentRound: -1})
+	service := NewProvinceService(&flakyRepo{ProvinceRepository: memoryRepo, failures: 1}, time.Now())
+	service.SetMoveFlushInterval(time.Hour)
+
+	assert.Equal(t, http.StatusOK, move(service, "attack", provinceID.Hex()))
+	assert.Error(t, service.FlushMoves(context.Background()))
+	assert.Equal(t, http.StatusOK, move(service, "attack", provinceID.Hex()))
+	assert.NoError(t, service.FlushMoves(context.Background()))
+
+	province, _ := memoryRepo.GetByID(context.Background(), provinceID.Hex())
+	assert.Equal(t, 2, province.AttackCount)
+}
+
+func TestBufferedMoves_FlushedBeforeNuke(t *testing.T) {
+	zartistan, zortistan := primitive.NewObjectID(), primitive.NewObjectID()
+	provinceRepo := repo.NewMemoryProvinceRepo(
+		model.Province{ID: zartistan, ProvinceName: "Zartistan", AttackCount: 1, DestroymentRound: -1},
+		model.Province{ID: zortistan, ProvinceName: "Zortistan", DestroymentRound: -1},
+	)
+	service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+	service.SetMoveFlushInterval(time.Hour)
+
+	// Only buffered so far, the nuke must still see them
+	assert.Equal(t, http.StatusOK, move(service, "attack", zortistan.Hex()))
+	assert.Equal(t, http.StatusOK, move(service, "attack", zortistan.Hex()))
+
+	_, err := service.ExecuteDestroymentRound(context.Background())
+	assert.NoError(t, err)
+
+	province, _ := provinceRepo.GetByID(context.Background(), zortistan.Hex())
+	assert.Equal(t, 2, province.DestroymentRound)
+	assert.Zero(t, province.AttackCount)
+}
+
+func TestShields_SkippedByNextNuke(t *testing.T) {
+	for _, buffered := range []bool{false, true} {
+		zartistan, zortistan := primitive.NewObjectID(), primitive.NewObjectID()
+		provinceRepo := repo.NewMemoryProvinceRepo(
+			model.Province{ID: zartistan, ProvinceName: "Zartistan", AttackCount: 9, DestroymentRound: -1},
+			model.Province{ID: zortistan, ProvinceName: "Zortistan", AttackCount: 3, DestroymentRound: -1},
+		)
+		service := NewProvinceService(provinceRepo, time.Now().Add(-48*time.Hour))
+		rules := game.DefaultRules()
+		rules.Shields.SupportThreshold = 2
+		service.SetRules(rules)
+		if buffered {
+			service.SetMoveFlushInterval(time.Hour)
+		}
+
+		assert.Equal(t, http.StatusOK, move(service, "support", zartistan.Hex()))
+		province, _ := provinceRepo.GetByID(context.Background(), zartistan.Hex())
+		assert.False(t, province.Shielded)
+
+		// Buffered supports shield the province when they are written, at the latest
+		// right before the nuke
+		assert.Equal(t, http.StatusOK, move(service, "support", zartistan.Hex()))
+		province, _ = provinceRepo.GetByID(context.Background(), zartistan.Hex())
+		assert.Equal(t, !buffered, province.Shielded)
+
+		_, err := service.ExecuteDestroymentRound(context.Background())
+		assert.NoError(t, err)
+
+		provinces, _ := provinceRepo.GetAll(context.Background())
+		assert.Equal(t, -1, provinces[0].DestroymentRound, "buffered: %t", buffered)
+		assert.Equal(t, 2, provinces[1].DestroymentRound, "buffered: %t", buffered)
+		assert.False(t, provinces[0].Shielded)
+	}
+}
+
+func TestRunMoveFlusher_FlushesOnStop(t *testing.T) {
+	provinceID := primitive.NewObjectID()
+	provinceRepo := repo.NewMemoryProvinceRepo(model.Province{ID: provinceID, ProvinceName: "Zartistan", DestroymentRound: -1})
+	service := NewProvinceService(provinceRepo, time.Now())
+	service.SetMoveFlushInterval(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.RunMoveFlusher(ctx)
+	}()
+
+	assert.Equal(t, http.StatusOK, move(service, "support", provinceID.Hex()))
+	cancel()
+	<-done
+
+	province, _ := provinceRepo.GetByID(context.Background(), provinceID.Hex())
+	assert.Equal(t, 1, province.SupportCount)
+}
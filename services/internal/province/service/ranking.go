@@ -0,0 +1,77 @@
+package service
+
+import (
+	"services/internal/game"
+	"services/internal/province/model"
+	"sort"
+)
+
+// rank scores provinces with the game's scoring rule and orders them highest score
+// first, ties by ID so every reader gets the same order
+func (ps *ProvinceService) rank(provinces []model.Province) []model.RankedProvince {
+	rule := ps.rules.Scoring.ScoringRule()
+
+	ranked := make([]model.RankedProvince, len(provinces))
+	for i, p := range provinces {
+		ranked[i] = model.RankedProvince{Province: p, Score: rule.Score(tally(p))}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID.Hex() < ranked[j].ID.Hex()
+	})
+
+	return ranked
+}
+
+// living ranks the provinces still in the game
+func (ps *ProvinceService) living(provinces []model.Province) []model.RankedProvince {
+	alive := []model.Province{}
+	for _, p := range provinces {
+		if p.DestroymentRound == -1 {
+			alive = append(alive, p)
+		}
+	}
+
+	return ps.rank(alive)
+}
+
+// victims picks the provinces a nuke takes, worst first: the highest ranked living,
+// unshielded ones, as many as the rules ask for with this many provinces alive
+func (ps *ProvinceService) victims(provinces []model.Province) []model.RankedProvince {
+	ranked := ps.living(provinces)
+	count := ps.rules.Nukes.Count(len(ranked))
+
+	victims := []model.RankedProvince{}
+	for _, p := range ranked {
+		if len(victims) == count {
+			break
+		}
+		if !p.Shielded {
+			victims = append(victims, p)
+		}
+	}
+
+	return victims
+}
+
+// scores are the scores of the living provinces by ID
+func (ps *ProvinceService) scores(provinces []model.Province) map[string]float64 {
+	scores := map[string]float64{}
+	for _, p := range ps.living(provinces) {
+		scores[p.ID.Hex()] = p.Score
+	}
+
+	return scores
+}
+
+// tally is what p is scored on, the counts of this round plus the ones carried over
+func tally(p model.Province) game.Tally {
+	return game.Tally{
+		Attacks:       float64(p.AttackCount) + p.CarriedAttacks,
+		Supports:      float64(p.SupportCount) + p.CarriedSupports,
+		PreviousScore: p.PreviousScore,
+	}
+}
@@ -0,0 +1,180 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"services/api"
+	auth_service "services/internal/auth/service"
+	"services/internal/core/health"
+	"services/internal/core/metrics"
+	"services/internal/core/response"
+	lobby_service "services/internal/lobby/service"
+	province_service "services/internal/province/service"
+)
+
+// Route is a single API endpoint, registered on the mux as "Method Path"
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+}
+
+// Pattern returns the ServeMux pattern of the route
+func (r Route) Pattern() string {
+	return r.Method + " " + r.Path
+}
+
+// Routes returns every API route, api/openapi.json documents exactly this list
+func Routes(authService *auth_service.AuthService, provinceService *province_service.ProvinceService, lobbyService *lobby_service.LobbyService, checker *health.Checker, m *metrics.Metrics) []Route {
+	routes := []Route{
+		// Public Auth routes
+		{http.MethodPost, "/api/auth/register", authService.Register},
+		{http.MethodPost, "/api/auth/login", authService.Login},
+
+		// Province routes
+		{http.MethodGet, "/api/province", provinceService.GetAllProvinces},
+		{http.MethodGet, "/api/province/top", provinceService.GetTopProvinces},
+		{http.MethodGet, "/api/province/projection", provinceService.GetProjection},
+		{http.MethodPost, "/api/province/attack", provinceService.AttackProvince},
+		{http.MethodPost, "/api/province/support", provinceService.SupportProvince},
+		{http.MethodGet, "/api/province/round", provinceService.GetCurrentRoundHandler},
+		{http.MethodGet, "/api/province/{id}", provinceService.GetProvince},
+
+		// Alliance routes
+		{http.MethodGet, "/api/alliances", provinceService.GetAlliances},
+		{http.MethodPost, "/api/alliances", provinceService.CreateAlliance},
+		{http.MethodGet, "/api/alliances/{id}", provinceService.GetAlliance},
+		{http.MethodPut, "/api/alliances/{id}", provinceService.UpdateAlliance},
+		{http.MethodDelete, "/api/alliances/{id}", provinceService.DeleteAlliance},
+		{http.MethodPost, "/api/alliances/{id}/accept", provinceService.AcceptAlliance},
+
+		// Private lobby routes
+		{http.MethodGet, "/api/lobbies", lobbyService.GetLobbies},
+		{http.MethodPost, "/api/lobbies", lobbyService.CreateLobby},
+		{http.MethodPost, "/api/lobbies/join", lobbyService.JoinLobby},
+		{http.MethodGet, "/api/lobbies/{id}", lobbyService.GetLobby},
+		{http.MethodPost, "/api/lobbies/{id}/bots", lobbyService.AddBots},
+		{http.MethodGet, "/api/lobbies/{id}/province", lobbyService.GetProvinces},
+		{http.MethodGet, "/api/lobbies/{id}/province/top", lobbyService.GetTopProvinces},
+		{http.MethodGet, "/api/lobbies/{id}/province/projection", lobbyService.GetProjection},
+		{http.MethodPost, "/api/lobbies/{id}/province/attack", lobbyService.AttackProvince},
+		{http.MethodPost, "/api/lobbies/{id}/province/support", lobbyService.SupportProvince},
+		{http.MethodGet, "/api/lobbies/{id}/province/round", lobbyService.GetCurrentRound},
+		{http.MethodGet, "/api/lobbies/{id}/user/cooldown", lobbyService.GetCooldownLeft},
+
+		// Gaming mechanics routes
+		{http.MethodPost, "/api/user/update-move-date", authService.UpdateMoveDate},
+		{http.MethodGet, "/api/user/cooldown", authService.GetCooldownLeft},
+
+		// Documentation
+		{http.MethodGet, "/api/openapi.json", api.ServeSpec},
+	}
+
+	return append(routes, OpsRoutes(checker, m)...)
+}
+
+// OpsRoutes returns the probe and metrics routes, also served alone by the nuke timer
+func OpsRoutes(checker *health.Checker, m *metrics.Metrics) []Route {
+	return []Route{
+		{http.MethodGet, "/healthz", checker.Healthz},
+		{http.MethodGet, "/readyz", checker.Readyz},
+		{http.MethodGet, "/version", health.Version},
+		{http.MethodGet, "/metrics", m.Serve},
+	}
+}
+
+// Setup registers every route on the mux
+func Setup(mux *http.ServeMux, authService *auth_service.AuthService, provinceService *province_service.ProvinceService, lobbyService *lobby_service.LobbyService, checker *health.Checker, m *metrics.Metrics) {
+	register(mux, Routes(authService, provinceService, lobbyService, checker, m))
+}
+
+// SetupOps registers only the probe and metrics routes on the mux
+func SetupOps(mux *http.ServeMux, checker *health.Checker, m *metrics.Metrics) {
+	register(mux, OpsRoutes(checker, m))
+}
+
+func register(mux *http.ServeMux, routes []Route) {
+	methods := map[string][]string{}
+	for _, route := range routes {
+		mux.HandleFunc(route.Pattern(), route.Handler)
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	// A route with a wildcard would take the other methods of a literal path next to it,
+	// GET /api/province/{id} those of /api/province/attack. They are answered with 405 like
+	// on any other route
+	for path, allowed := range methods {
+		if strings.Contains(path, "{") {
+			continue
+		}
+		for _, route := range routes {
+			if slices.Contains(allowed, route.Method) {
+				continue
+			}
+			if _, pattern := mux.Handler(&http.Request{Method: route.Method, URL: &url.URL{Path: path}}); pattern == route.Pattern() {
+				mux.HandleFunc(route.Method+" "+path, methodNotAllowed(allowed))
+			}
+		}
+	}
+}
+
+// methodNotAllowed answers with 405 and the methods a path is served with
+func methodNotAllowed(methods []string) http.HandlerFunc {
+	allow := slices.Clone(methods)
+	if slices.Contains(allow, http.MethodGet) {
+		allow = append(allow, http.MethodHead)
+	}
+	slices.Sort(allow)
+	allow = slices.Compact(allow)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		response.MethodNotAllowed(w, r)
+	}
+}
+
+// WithJSONErrors replaces the plain text 404 and 405 answers of the mux with the
+// JSON error envelope, the Allow header set by the mux on 405 is kept
+func WithJSONErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		mux.ServeHTTP(&muxErrorWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// muxErrorWriter intercepts the error written by the mux when no route matches
+type muxErrorWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	intercepted bool
+}
+
+func (mew *muxErrorWriter) WriteHeader(status int) {
+	switch status {
+	case http.StatusNotFound:
+		mew.intercepted = true
+		mew.Header().Del("X-Content-Type-Options")
+		response.Error(mew.ResponseWriter, mew.r, status, response.CodeNotFound, "Route not found")
+	case http.StatusMethodNotAllowed:
+		mew.intercepted = true
+		mew.Header().Del("X-Content-Type-Options")
+		response.MethodNotAllowed(mew.ResponseWriter, mew.r)
+	default:
+		mew.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (mew *muxErrorWriter) Write(b []byte) (int, error) {
+	if mew.intercepted {
+		return len(b), nil
+	}
+
+	return mew.ResponseWriter.Write(b)
+}
@@ -0,0 +1,408 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/kahlery/pkg/go/auth/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"services/api"
+	auth_model "services/internal/auth/model"
+	auth_repo "services/internal/auth/repo"
+	auth_service "services/internal/auth/service"
+	"services/internal/bot"
+	"services/internal/core/health"
+	"services/internal/core/metrics"
+	"services/internal/core/requestid"
+	lobby_model "services/internal/lobby/model"
+	lobby_repo "services/internal/lobby/repo"
+	lobby_service "services/internal/lobby/service"
+	province_model "services/internal/province/model"
+	province_repo "services/internal/province/repo"
+	province_service "services/internal/province/service"
+)
+
+var (
+	testUserID     = primitive.NewObjectID()
+	testFriendID   = primitive.NewObjectID()
+	testProvinceID = primitive.NewObjectID()
+	testAllyID     = primitive.NewObjectID()
+)
+
+func loadSpec(t *testing.T) (*openapi3.T, routers.Router) {
+	t.Helper()
+
+	doc, err := openapi3.NewLoader().LoadFromData(api.Spec)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	specRouter, err := legacy.NewRouter(doc)
+	require.NoError(t, err)
+
+	return doc, specRouter
+}
+
+func newTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+
+	hashedPassword, err := token.HashPassword("hunter22")
+	require.NoError(t, err)
+
+	userRepo := auth_repo.NewMemoryUserRepo(auth_model.User{
+		ID:             testUserID,
+		Username:       "nuke_lord",
+		Email:          "lord@nuky.io",
+		Password:       hashedPassword,
+		LastMoveDate:   time.Now().Add(-2 * auth_service.MoveCooldown),
+		HomeProvinceID: testProvinceID.Hex(),
+	}, auth_model.User{
+		ID:             testFriendID,
+		Username:       "fallout_kid",
+		Email:          "kid@nuky.io",
+		LastMoveDate:   time.Now().Add(-2 * auth_service.MoveCooldown),
+		HomeProvinceID: testAllyID.Hex(),
+	})
+
+	provinces := []province_model.Province{
+		{ID: testProvinceID, ProvinceName: "Ankara", ProvinceColorHex: "#ff0000", DestroymentRound: -1},
+		{ID: testAllyID, ProvinceName: "Izmir", ProvinceColorHex: "#0000ff", DestroymentRound: -1},
+	}
+	for _, name := range []string{"Bursa", "Antalya", "Konya", "Adana"} {
+		provinces = append(provinces, province_model.Province{ProvinceName: name, ProvinceColorHex: "#00ff00", AttackCount: len(name), DestroymentRound: -1})
+	}
+	provinceRepo := province_repo.NewMemoryProvinceRepo(provinces...)
+
+	checker := health.NewChecker()
+	checker.Add("store", func(ctx context.Context) error { return nil })
+
+	provinceService := province_service.NewProvinceService(provinceRepo, time.Now().Add(-72*time.Hour))
+	provinceService.SetPlayers(userRepo)
+	provinceService.SetAlliances(province_repo.NewMemoryAllianceRepo())
+
+	lobbyProvinces := map[string]*province_repo.MemoryProvinceRepo{}
+	lobbyService := lobby_service.NewLobbyService(lobby_repo.NewMemoryLobbyRepo(), provinceRepo, func(lobbyID string) lobby_service.ProvinceRepository {
+		if _, ok := lobbyProvinces[lobbyID]; !ok {
+			lobbyProvinces[lobbyID] = province_repo.NewMemoryProvinceRepo()
+		}
+		return lobbyProvinces[lobbyID]
+	})
+
+	mux := http.NewServeMux()
+	Setup(mux,
+		auth_service.NewAuthService(userRepo),
+		provinceService,
+		lobbyService,
+		checker,
+		metrics.New(),
+	)
+
+	// Seated bots are enlisted but never play, the fleet isn't run
+	lobbyService.SetBots(bot.NewFleet(bot.NewHandlerClient(mux), bot.NewStoreAccounts(userRepo)))
+
+	return requestid.Middleware(WithJSONErrors(mux))
+}
+
+// TestSpecMatchesRoutes makes sure every route is documented and every documented operation is routed
+func TestSpecMatchesRoutes(t *testing.T) {
+	doc, _ := loadSpec(t)
+
+	routed := make(map[string]bool)
+	for _, route := range Routes(&auth_service.AuthService{}, &province_service.ProvinceService{}, &lobby_service.LobbyService{}, health.NewChecker(), metrics.New()) {
+		key := route.Method + " " + route.Path
+		routed[key] = true
+
+		pathItem := doc.Paths.Find(route.Path)
+		if assert.NotNil(t, pathItem, "route %s is missing from the spec", key) {
+			assert.NotNil(t, pathItem.GetOperation(route.Method), "route %s is missing from the spec", key)
+		}
+	}
+
+	for path, pathItem := range doc.Paths.Map() {
+		for method := range pathItem.Operations() {
+			assert.True(t, routed[method+" "+path], "spec documents %s %s which isn't routed", method, path)
+		}
+	}
+}
+
+type contractCase struct {
+	name    string
+	method  string
+	path    string
+	body    string
+	headers map[string]string
+	status  int
+
+	// specMethod is the documented method to validate against when method is deliberately wrong
+	specMethod string
+
+	// invalidRequest marks requests that deliberately break the spec, they are not validated
+	invalidRequest bool
+}
+
+func (c contractCase) run(t *testing.T, handler http.Handler, specRouter routers.Router) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+	if c.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	specReq := req
+	if c.specMethod != "" {
+		specReq = req.Clone(req.Context())
+		specReq.Method = c.specMethod
+	}
+
+	route, pathParams, err := specRouter.FindRoute(specReq)
+	require.NoError(t, err, "no spec operation for %s %s", specReq.Method, c.path)
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+		MultiError:            true,
+	}
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    specReq,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    options,
+	}
+
+	if !c.invalidRequest {
+		require.NoError(t, openapi3filter.ValidateRequest(context.Background(), requestInput), "test request doesn't match the spec")
+	}
+
+	// The request body may have been consumed by the validator
+	req.Body = io.NopCloser(strings.NewReader(c.body))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, c.status, rr.Code, rr.Body.String())
+
+	err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 rr.Code,
+		Header:                 rr.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rr.Body.Bytes())),
+		Options:                options,
+	})
+	assert.NoError(t, err, "response doesn't match the spec: %s", rr.Body.String())
+
+	return rr
+}
+
+func TestContract(t *testing.T) {
+	_, specRouter := loadSpec(t)
+	handler := newTestHandler(t)
+
+	provinceID := testProvinceID.Hex()
+	unknownID := primitive.NewObjectID().Hex()
+
+	userToken, err := token.GenerateToken(testUserID.Hex())
+	require.NoError(t, err)
+	unknownUserToken, err := token.GenerateToken(primitive.NewObjectID().Hex())
+	require.NoError(t, err)
+
+	allyID := testAllyID.Hex()
+	bearer := map[string]string{"Authorization": "Bearer " + userToken}
+	alliance := `{"name": "Aegean", "pact": "Back to back", "province_ids": ["` + provinceID + `", "` + allyID + `"]}`
+
+	cases := []contractCase{
+		// Auth
+		{name: "register", method: http.MethodPost, path: "/api/auth/register", body: `{"username": "new_player", "email": "new@nuky.io", "password": "secret123"}`, status: http.StatusCreated},
+		{name: "register invalid", method: http.MethodPost, path: "/api/auth/register", body: `{"username": "a b", "email": "x", "password": "short"}`, status: http.StatusBadRequest, invalidRequest: true},
+		{name: "register unknown field", method: http.MethodPost, path: "/api/auth/register", body: `{"username": "other", "email": "o@nuky.io", "password": "secret123", "admin": true}`, status: http.StatusBadRequest, invalidRequest: true},
+		{name: "register taken email", method: http.MethodPost, path: "/api/auth/register", body: `{"username": "other", "email": "lord@nuky.io", "password": "secret123"}`, status: http.StatusConflict},
+		{name: "login by email", method: http.MethodPost, path: "/api/auth/login", body: `{"email": "lord@nuky.io", "username": null, "password": "hunter22"}`, status: http.StatusOK},
+		{name: "login by username", method: http.MethodPost, path: "/api/auth/login", body: `{"username": "nuke_lord", "password": "hunter22"}`, status: http.StatusOK},
+		{name: "login wrong password", method: http.MethodPost, path: "/api/auth/login", body: `{"email": "lord@nuky.io", "password": "wrong"}`, status: http.StatusUnauthorized},
+		{name: "login wrong method", method: http.MethodGet, path: "/api/auth/login", specMethod: http.MethodPost, status: http.StatusMethodNotAllowed, invalidRequest: true},
+
+		// Province
+		{name: "all provinces", method: http.MethodGet, path: "/api/province", status: http.StatusOK},
+		{name: "top provinces", method: http.MethodGet, path: "/api/province/top", status: http.StatusOK},
+		{name: "nuke projection", method: http.MethodGet, path: "/api/province/projection", status: http.StatusOK},
+		{name: "current round", method: http.MethodGet, path: "/api/province/round", status: http.StatusOK},
+		{name: "single province", method: http.MethodGet, path: "/api/province/" + provinceID, status: http.StatusOK},
+		{name: "single province invalid id", method: http.MethodGet, path: "/api/province/invalidid", status: http.StatusBadRequest, invalidRequest: true},
+		{name: "single province unknown", method: http.MethodGet, path: "/api/province/" + unknownID, status: http.StatusNotFound},
+		{name: "single province wrong method", method: http.MethodDelete, path: "/api/province/" + provinceID, specMethod: http.MethodGet, status: http.StatusMethodNotAllowed, invalidRequest: true},
+		{name: "attack wrong method", method: http.MethodGet, path: "/api/province/attack", specMethod: http.MethodPost, status: http.StatusMethodNotAllowed, invalidRequest: true},
+		{name: "attack", method: http.MethodPost, path: "/api/province/attack", body: `{"province_id": "` + provinceID + `"}`, status: http.StatusOK},
+		{name: "attack invalid id", method: http.MethodPost, path: "/api/province/attack", body: `{"province_id": "invalidid"}`, status: http.StatusBadRequest, invalidRequest: true},
+		{name: "attack unknown province", method: http.MethodPost, path: "/api/province/attack", body: `{"province_id": "` + unknownID + `"}`, status: http.StatusNotFound},
+		{name: "support", method: http.MethodPost, path: "/api/province/support", body: `{"province_id": "` + provinceID + `"}`, status: http.StatusOK},
+		{name: "support too large", method: http.MethodPost, path: "/api/province/support", body: `{"province_id": "` + strings.Repeat("a", 20000) + `"}`, status: http.StatusRequestEntityTooLarge, invalidRequest: true},
+
+		// Alliance
+		{name: "alliances", method: http.MethodGet, path: "/api/alliances", status: http.StatusOK},
+		{name: "form alliance without token", method: http.MethodPost, path: "/api/alliances", body: alliance, status: http.StatusUnauthorized},
+		{name: "form alliance too short a name", method: http.MethodPost, path: "/api/alliances", body: `{"name": "AI", "province_ids": ["` + provinceID + `", "` + allyID + `"]}`, headers: bearer, status: http.StatusBadRequest, invalidRequest: true},
+		{name: "form alliance", method: http.MethodPost, path: "/api/alliances", body: alliance, headers: bearer, status: http.StatusCreated},
+		{name: "form alliance member taken", method: http.MethodPost, path: "/api/alliances", body: alliance, headers: bearer, status: http.StatusConflict},
+		{name: "single alliance unknown", method: http.MethodGet, path: "/api/alliances/" + unknownID, status: http.StatusNotFound},
+		{name: "update alliance unknown", method: http.MethodPut, path: "/api/alliances/" + unknownID, body: alliance, headers: bearer, status: http.StatusNotFound},
+		{name: "dissolve alliance unknown", method: http.MethodDelete, path: "/api/alliances/" + unknownID, headers: bearer, status: http.StatusNotFound},
+		{name: "accept alliance unknown", method: http.MethodPost, path: "/api/alliances/" + unknownID + "/accept", headers: bearer, status: http.StatusNotFound},
+
+		// User
+		{name: "update move date without token", method: http.MethodPost, path: "/api/user/update-move-date", status: http.StatusUnauthorized},
+		{name: "cooldown", method: http.MethodGet, path: "/api/user/cooldown", headers: map[string]string{"Authorization": "Bearer " + userToken}, status: http.StatusOK},
+		{name: "cooldown with invalid token", method: http.MethodGet, path: "/api/user/cooldown", headers: map[string]string{"Authorization": "Bearer not-a-jwt"}, status: http.StatusUnauthorized},
+		{name: "cooldown unknown user", method: http.MethodGet, path: "/api/user/cooldown", headers: map[string]string{"Authorization": "Bearer " + unknownUserToken}, status: http.StatusNotFound},
+		{name: "cooldown wrong method", method: http.MethodPost, path: "/api/user/cooldown", specMethod: http.MethodGet, status: http.StatusMethodNotAllowed, invalidRequest: true},
+		{name: "update move date", method: http.MethodPost, path: "/api/user/update-move-date", headers: map[string]string{"Authorization": "Bearer " + userToken}, status: http.StatusNoContent},
+		{name: "update move date in cooldown", method: http.MethodPost, path: "/api/user/update-move-date", headers: map[string]string{"Authorization": "Bearer " + userToken}, status: http.StatusTooManyRequests},
+
+		// Health
+		{name: "healthz", method: http.MethodGet, path: "/healthz", status: http.StatusOK},
+		{name: "readyz", method: http.MethodGet, path: "/readyz", status: http.StatusOK},
+		{name: "version", method: http.MethodGet, path: "/version", status: http.StatusOK},
+		{name: "metrics", method: http.MethodGet, path: "/metrics", status: http.StatusOK},
+
+		// Documentation
+		{name: "openapi", method: http.MethodGet, path: "/api/openapi.json", status: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, handler, specRouter)
+		})
+	}
+}
+
+func TestContract_Alliances(t *testing.T) {
+	_, specRouter := loadSpec(t)
+	handler := newTestHandler(t)
+
+	tokenOf := func(id primitive.ObjectID) map[string]string {
+		userToken, err := token.GenerateToken(id.Hex())
+		require.NoError(t, err)
+		return map[string]string{"Authorization": "Bearer " + userToken}
+	}
+	founder, ally := tokenOf(testUserID), tokenOf(testFriendID)
+
+	form := contractCase{method: http.MethodPost, path: "/api/alliances", headers: founder, status: http.StatusCreated,
+		body: `{"name": "Aegean", "pact": "Back to back", "province_ids": ["` + testProvinceID.Hex() + `", "` + testAllyID.Hex() + `"]}`}
+	var formed province_model.AllianceResponse
+	require.NoError(t, json.Unmarshal(form.run(t, handler, specRouter).Body.Bytes(), &formed))
+	accept := "/api/alliances/" + formed.Alliance.ID.Hex() + "/accept"
+	attackAlly := `{"province_id": "` + testAllyID.Hex() + `"}`
+
+	cases := []contractCase{
+		{name: "attack invited province", method: http.MethodPost, path: "/api/province/attack", body: attackAlly, headers: founder, status: http.StatusOK},
+		{name: "accept alliance without token", method: http.MethodPost, path: accept, status: http.StatusUnauthorized},
+		{name: "accept alliance not invited", method: http.MethodPost, path: accept, headers: founder, status: http.StatusForbidden},
+		{name: "accept alliance", method: http.MethodPost, path: accept, headers: ally, status: http.StatusOK},
+		{name: "accept alliance again", method: http.MethodPost, path: accept, headers: ally, status: http.StatusForbidden},
+		{name: "attack ally", method: http.MethodPost, path: "/api/province/attack", body: attackAlly, headers: founder, status: http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, handler, specRouter)
+		})
+	}
+}
+
+func TestContract_LoginThrottled(t *testing.T) {
+	_, specRouter := loadSpec(t)
+	handler := newTestHandler(t)
+
+	wrongPassword := contractCase{method: http.MethodPost, path: "/api/auth/login", body: `{"email": "lord@nuky.io", "password": "wrong"}`, status: http.StatusUnauthorized}
+	for i := 0; i < auth_service.DefaultAccountLoginPolicy.FreeAttempts+1; i++ {
+		wrongPassword.run(t, handler, specRouter)
+	}
+
+	throttled := wrongPassword
+	throttled.status = http.StatusTooManyRequests
+	rr := throttled.run(t, handler, specRouter)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
+
+func TestContract_Lobbies(t *testing.T) {
+	_, specRouter := loadSpec(t)
+	handler := newTestHandler(t)
+
+	tokenOf := func(id primitive.ObjectID) map[string]string {
+		userToken, err := token.GenerateToken(id.Hex())
+		require.NoError(t, err)
+		return map[string]string{"Authorization": "Bearer " + userToken}
+	}
+	owner, friend, stranger := tokenOf(testUserID), tokenOf(testFriendID), tokenOf(primitive.NewObjectID())
+
+	create := contractCase{method: http.MethodPost, path: "/api/lobbies", headers: owner, status: http.StatusCreated,
+		body: `{"name": "Class 9B", "max_players": 2, "nuke_every_minutes": 60, "province_ids": ["` + testProvinceID.Hex() + `", "` + testAllyID.Hex() + `"]}`}
+	var created lobby_model.LobbyResponse
+	require.NoError(t, json.Unmarshal(create.run(t, handler, specRouter).Body.Bytes(), &created))
+
+	lobby := "/api/lobbies/" + created.Lobby.ID.Hex()
+	join := `{"invite_code": "` + created.Lobby.InviteCode + `"}`
+
+	var provinces province_model.GetAllProvinceResponse
+	rr := contractCase{method: http.MethodGet, path: lobby + "/province", headers: owner, status: http.StatusOK}.run(t, handler, specRouter)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &provinces))
+	require.Len(t, provinces.ProvinceList, 2)
+	move := `{"province_id": "` + provinces.ProvinceList[0].ID.Hex() + `"}`
+
+	var botLobby lobby_model.LobbyResponse
+	rr = contractCase{method: http.MethodPost, path: "/api/lobbies", headers: owner, status: http.StatusCreated,
+		body: `{"name": "Quiet room", "max_players": 3, "nuke_every_minutes": 30, "provinces": [{"province_name": "Archenland", "province_color_hex": "#aa0000"}, {"province_name": "Calormen", "province_color_hex": "#00aa00"}]}`}.run(t, handler, specRouter)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &botLobby))
+	bots := "/api/lobbies/" + botLobby.Lobby.ID.Hex() + "/bots"
+
+	cases := []contractCase{
+		{name: "open custom lobby", method: http.MethodPost, path: "/api/lobbies", headers: owner, status: http.StatusCreated,
+			body: `{"name": "Narnia", "max_players": 10, "nuke_every_minutes": 5, "provinces": [{"province_name": "Archenland", "province_color_hex": "#aa0000"}, {"province_name": "Calormen", "province_color_hex": "#00aa00"}]}`},
+		{name: "open lobby without token", method: http.MethodPost, path: "/api/lobbies", body: create.body, status: http.StatusUnauthorized},
+		{name: "open lobby on unknown provinces", method: http.MethodPost, path: "/api/lobbies", headers: owner, status: http.StatusBadRequest,
+			body: `{"name": "Class 9B", "max_players": 2, "nuke_every_minutes": 60, "province_ids": ["` + primitive.NewObjectID().Hex() + `", "` + testAllyID.Hex() + `"]}`},
+		{name: "open lobby too short a round", method: http.MethodPost, path: "/api/lobbies", headers: owner, status: http.StatusBadRequest, invalidRequest: true,
+			body: `{"name": "Class 9B", "max_players": 2, "nuke_every_minutes": 1, "province_ids": ["` + testProvinceID.Hex() + `", "` + testAllyID.Hex() + `"]}`},
+		{name: "lobby as stranger", method: http.MethodGet, path: lobby, headers: stranger, status: http.StatusNotFound},
+		{name: "join lobby", method: http.MethodPost, path: "/api/lobbies/join", body: join, headers: friend, status: http.StatusOK},
+		{name: "join lobby again", method: http.MethodPost, path: "/api/lobbies/join", body: join, headers: friend, status: http.StatusOK},
+		{name: "join full lobby", method: http.MethodPost, path: "/api/lobbies/join", body: join, headers: stranger, status: http.StatusConflict},
+		{name: "join unknown lobby", method: http.MethodPost, path: "/api/lobbies/join", body: `{"invite_code": "AAAAAAAA"}`, headers: stranger, status: http.StatusNotFound},
+		{name: "my lobbies", method: http.MethodGet, path: "/api/lobbies", headers: friend, status: http.StatusOK},
+		{name: "lobby", method: http.MethodGet, path: lobby, headers: friend, status: http.StatusOK},
+		{name: "lobby invalid id", method: http.MethodGet, path: "/api/lobbies/invalidid", headers: friend, status: http.StatusBadRequest, invalidRequest: true},
+		{name: "lobby top provinces", method: http.MethodGet, path: lobby + "/province/top", headers: friend, status: http.StatusOK},
+		{name: "lobby nuke projection", method: http.MethodGet, path: lobby + "/province/projection", headers: friend, status: http.StatusOK},
+		{name: "lobby round", method: http.MethodGet, path: lobby + "/province/round", headers: friend, status: http.StatusOK},
+		{name: "lobby cooldown", method: http.MethodGet, path: lobby + "/user/cooldown", headers: friend, status: http.StatusOK},
+		{name: "lobby attack", method: http.MethodPost, path: lobby + "/province/attack", body: move, headers: friend, status: http.StatusOK},
+		{name: "lobby support in cooldown", method: http.MethodPost, path: lobby + "/province/support", body: move, headers: friend, status: http.StatusTooManyRequests},
+		{name: "lobby attack as stranger", method: http.MethodPost, path: lobby + "/province/attack", body: move, headers: stranger, status: http.StatusNotFound},
+		{name: "seat bots", method: http.MethodPost, path: bots, body: `{"strategy": "pile-on-leader", "count": 2}`, headers: owner, status: http.StatusCreated},
+		{name: "seat bots over the player cap", method: http.MethodPost, path: bots, body: `{"strategy": "random", "count": 1}`, headers: owner, status: http.StatusConflict},
+		{name: "seat bots of unknown strategy", method: http.MethodPost, path: bots, body: `{"strategy": "sniper", "count": 1}`, headers: owner, status: http.StatusBadRequest, invalidRequest: true},
+		{name: "seat bots as member", method: http.MethodPost, path: lobby + "/bots", body: `{"strategy": "random", "count": 1}`, headers: friend, status: http.StatusForbidden},
+		{name: "seat bots as stranger", method: http.MethodPost, path: bots, body: `{"strategy": "random", "count": 1}`, headers: stranger, status: http.StatusNotFound},
+		{name: "lobby attack on a public province", method: http.MethodPost, path: lobby + "/province/attack", body: `{"province_id": "` + testProvinceID.Hex() + `"}`, headers: owner, status: http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, handler, specRouter)
+		})
+	}
+}
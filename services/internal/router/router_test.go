@@ -0,0 +1,64 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"services/internal/core/response"
+)
+
+func serve(handler http.Handler, method, path string) (*httptest.ResponseRecorder, response.ErrorResponse) {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+
+	var body response.ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	return rr, body
+}
+
+func TestWithJSONErrors_MethodNotAllowed(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rr, body := serve(handler, http.MethodPost, "/api/province")
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "GET, HEAD", rr.Header().Get("Allow"))
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, response.CodeMethodNotAllowed, body.Error.Code)
+	assert.NotEmpty(t, body.Error.RequestID)
+
+	rr, _ = serve(handler, http.MethodGet, "/api/auth/register")
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "POST", rr.Header().Get("Allow"))
+}
+
+func TestWithJSONErrors_NotFound(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rr, body := serve(handler, http.MethodGet, "/api/nothing-here")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, response.CodeNotFound, body.Error.Code)
+}
+
+func TestWithJSONErrors_HeadOnGetRoute(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rr, _ := serve(handler, http.MethodHead, "/api/province/round")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestWithJSONErrors_MethodNotAllowedNextToAWildcard(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rr, body := serve(handler, http.MethodGet, "/api/province/attack")
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "POST", rr.Header().Get("Allow"))
+	assert.Equal(t, response.CodeMethodNotAllowed, body.Error.Code)
+
+	rr, _ = serve(handler, http.MethodGet, "/api/lobbies/join")
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "POST", rr.Header().Get("Allow"))
+}